@@ -2,29 +2,42 @@ package main
 
 import (
 	"book-and-rate/pkg/config"
-	"book-and-rate/pkg/db"
-	"book-and-rate/pkg/routes"
-	httpSwagger "github.com/swaggo/http-swagger"
+	"book-and-rate/pkg/server"
+	"context"
 	"log"
-	"net/http"
-
-	"github.com/gorilla/mux"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	cfg := config.LoadConfig("config/config.json")
-	db.Connect(cfg.MongoDbUrl)
-	db.InitializeCollections()
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(logger, os.Args[2:]); err != nil && !isHelp(err) {
+			logger.Fatal(err)
+		}
+		return
+	}
+
+	cfg, err := config.Load(os.Args[0], os.Args[1:], os.Getenv, os.Stderr)
+	if isHelp(err) {
+		os.Exit(0)
+	}
+	if err != nil {
+		logger.Fatal(err)
+	}
 
-	router := mux.NewRouter()
-	router.PathPrefix("/swagger").Handler(httpSwagger.WrapHandler)
+	// SIGTERM is what the orchestrator sends before replacing an instance during a rolling deployment
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	routes.UserRoutes(router)
-	routes.RestaurantRoutes(router)
-	routes.BookingRoutes(router)
-	routes.RateRoutes(router)
-	routes.RefreshTokenRoutes(router)
+	srv, err := server.Connect(ctx, *cfg, logger)
+	if err != nil {
+		logger.Fatal("Cannot connect to MongoDB: ", err)
+	}
 
-	log.Println("Server is running on port 8080")
-	log.Fatal(http.ListenAndServe(":8080", router))
+	if err := srv.Run(ctx); err != nil {
+		logger.Fatal(err)
+	}
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"book-and-rate/pkg/config"
+	"book-and-rate/pkg/db"
+	"book-and-rate/pkg/migrations"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+const migrateUsage = "usage: %s migrate up|down|status [flags]\n"
+
+// migrate runs the migrate subcommand: up applies the pending migrations, down reverts the last applied one
+// and status lists them all. It takes the same configuration flags as the server.
+func migrate(logger *log.Logger, args []string) error {
+	if len(args) == 0 || (args[0] != "up" && args[0] != "down" && args[0] != "status") {
+		return fmt.Errorf(migrateUsage, os.Args[0])
+	}
+	command := args[0]
+
+	cfg, err := config.Load(os.Args[0]+" migrate "+command, args[1:], os.Getenv, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client, err := db.Connect(ctx, cfg.MongoDbUrl)
+	if err != nil {
+		return fmt.Errorf("cannot connect to MongoDB: %w", err)
+	}
+	defer client.Disconnect(ctx)
+	migrator := migrations.NewMigrator(client.Database(cfg.DatabaseName))
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			logger.Printf("Applied migration %d: %s", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			logger.Println("No pending migrations")
+		}
+		return err
+
+	case "down":
+		reverted, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if reverted == nil {
+			logger.Println("No applied migrations")
+			return nil
+		}
+		logger.Printf("Reverted migration %d: %s", reverted.Version, reverted.Name)
+		return nil
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(os.Stdout, statuses)
+	}
+	return nil
+}
+
+func printStatus(w io.Writer, statuses []migrations.Status) {
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%4d  %-28s  %s\n", status.Version, applied, status.Name)
+	}
+}
+
+// isHelp reports whether err only means the usage was printed on request
+func isHelp(err error) bool {
+	return errors.Is(err, flag.ErrHelp)
+}
@@ -0,0 +1,149 @@
+// Package apierror writes the JSON error answers of the API. Every error carries a stable code clients can branch on
+// and the request ID, and failures of the server itself are reported without their internal details.
+package apierror
+
+import (
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/repository"
+	"book-and-rate/pkg/requestid"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The codes of the error answers
+const (
+	CodeBadRequest       = "bad_request"
+	CodeInvalid          = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeTooLarge         = "payload_too_large"
+	CodeTooManyRequests  = "too_many_requests"
+	CodeInternal         = "internal_error"
+	CodeUpstream         = "upstream_failed"
+	CodeUnavailable      = "service_unavailable"
+)
+
+// ErrTrailingData is returned by decoders that find anything but whitespace after the JSON value of a request body,
+// Invalid answers it like any other malformed body
+var ErrTrailingData = errors.New("unexpected data after the JSON value")
+
+// Write answers with the error envelope
+func Write(w http.ResponseWriter, r *http.Request, status int, code, message string, fields ...dto.FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(dto.ErrorResponse{
+		Code:      code,
+		Message:   message,
+		Fields:    fields,
+		RequestID: requestid.FromContext(r.Context()),
+	})
+}
+
+// BadRequest answers 400 for a request that cannot be understood
+func BadRequest(w http.ResponseWriter, r *http.Request, message string) {
+	Write(w, r, http.StatusBadRequest, CodeBadRequest, message)
+}
+
+// InvalidField answers 400 for a request field that is not acceptable
+func InvalidField(w http.ResponseWriter, r *http.Request, field, message string) {
+	Write(w, r, http.StatusBadRequest, CodeInvalid, field+" "+message, dto.FieldError{Field: field, Message: message})
+}
+
+// Invalid answers 400 for a request body that could not be decoded or failed validation,
+// naming the offending fields when the error tells which ones they are, and 413 for a body over the size limit
+func Invalid(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErrs models.FieldErrors
+	var fieldErr models.FieldError
+	var tooLarge *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	var timeErr *time.ParseError
+	switch {
+	case errors.As(err, &fieldErrs):
+		fields := make([]dto.FieldError, 0, len(fieldErrs))
+		for _, e := range fieldErrs {
+			fields = append(fields, dto.FieldError{Field: e.Field, Message: e.Message})
+		}
+		Write(w, r, http.StatusBadRequest, CodeInvalid, fieldErrs.Error(), fields...)
+	case errors.As(err, &fieldErr):
+		InvalidField(w, r, fieldErr.Field, fieldErr.Message)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		InvalidField(w, r, typeErr.Field, "must be of type "+typeErr.Type.String())
+	case errors.As(err, &tooLarge):
+		Write(w, r, http.StatusRequestEntityTooLarge, CodeTooLarge, fmt.Sprintf("The request body must not be larger than %d bytes", tooLarge.Limit))
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, ErrTrailingData):
+		BadRequest(w, r, "The request body is not valid JSON")
+	case errors.As(err, &timeErr):
+		BadRequest(w, r, "Times must be RFC 3339 timestamps, such as 2024-05-01T19:00:00Z")
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		InvalidField(w, r, field, "is not a known field")
+	default:
+		Write(w, r, http.StatusBadRequest, CodeInvalid, err.Error())
+	}
+}
+
+// InvalidID answers 400 for a malformed ID in the path or query
+func InvalidID(w http.ResponseWriter, r *http.Request, name string) {
+	InvalidField(w, r, name, "must be a 24 character hexadecimal ID")
+}
+
+// Unauthorized answers 401 for a request without valid credentials
+func Unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	Write(w, r, http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+// Forbidden answers 403 for a caller that may not do what it asked
+func Forbidden(w http.ResponseWriter, r *http.Request, message string) {
+	Write(w, r, http.StatusForbidden, CodeForbidden, message)
+}
+
+// NotFound answers 404
+func NotFound(w http.ResponseWriter, r *http.Request, message string) {
+	Write(w, r, http.StatusNotFound, CodeNotFound, message)
+}
+
+// Conflict answers 409 for a request the current state of the resource does not allow
+func Conflict(w http.ResponseWriter, r *http.Request, message string) {
+	Write(w, r, http.StatusConflict, CodeConflict, message)
+}
+
+// TooManyRequests answers 429
+func TooManyRequests(w http.ResponseWriter, r *http.Request, message string) {
+	Write(w, r, http.StatusTooManyRequests, CodeTooManyRequests, message)
+}
+
+// Upstream answers 502 when a service the request depends on failed
+func Upstream(w http.ResponseWriter, r *http.Request, message string) {
+	Write(w, r, http.StatusBadGateway, CodeUpstream, message)
+}
+
+// Internal answers 503 when the storage cannot be reached and 500 for any other failure,
+// in both cases without the error itself, which is for the logs only
+func Internal(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, repository.ErrUnavailable) {
+		Write(w, r, http.StatusServiceUnavailable, CodeUnavailable, "The service is temporarily unavailable, try again later")
+		return
+	}
+	Write(w, r, http.StatusInternalServerError, CodeInternal, "Something went wrong on our side")
+}
+
+// Lookup answers 404 with the message when err means the resource does not exist, and like Internal otherwise
+func Lookup(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if errors.Is(err, repository.ErrNotFound) {
+		NotFound(w, r, message)
+		return
+	}
+	Internal(w, r, err)
+}
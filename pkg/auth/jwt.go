@@ -2,53 +2,157 @@ package auth
 
 import (
 	"book-and-rate/pkg/config"
+	"errors"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
+// Issuer is the iss claim of every token signed by this service
+const Issuer = "book-and-rate"
+
+// PrincipalType tells which kind of account a token was issued to
+type PrincipalType string
+
+const (
+	PrincipalUser       PrincipalType = "user"
+	PrincipalRestaurant PrincipalType = "restaurant"
+	PrincipalAdmin      PrincipalType = "admin"
+)
+
+const (
+	RoleUser       = "user"
+	RoleRestaurant = "restaurant"
+	RoleAdmin      = "admin"
+)
+
+// Values of the use claim, they keep access and refresh tokens from standing in for each other
+const (
+	UseAccess  = "access"
+	UseRefresh = "refresh"
+)
+
+// audiences maps each principal type to the client its tokens are meant for
+var audiences = map[PrincipalType]string{
+	PrincipalUser:       "consumer-app",
+	PrincipalRestaurant: "restaurant-portal",
+	PrincipalAdmin:      "admin-console",
+}
+
+var roles = map[PrincipalType][]string{
+	PrincipalUser:       {RoleUser},
+	PrincipalRestaurant: {RoleRestaurant},
+	PrincipalAdmin:      {RoleAdmin, RoleUser},
+}
+
 // Claims struct
 type Claims struct {
-	UserId string `json:"userId"`
+	UserId string        `json:"userId"`
+	Type   PrincipalType `json:"principalType"`
+	Roles  []string      `json:"roles,omitempty"`
+	Use    string        `json:"use"`
 	jwt.StandardClaims
 }
 
-func GenerateToken(userID string, cfg config.Config) (string, error) {
-	expirationTime := time.Now().Add(1 * time.Hour)
+// HasRole reports whether the claims grant the role
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
 
-	claims := &Claims{
-		UserId: userID,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+func (c *Claims) Valid() error {
+	if err := c.StandardClaims.Valid(); err != nil {
+		return err
 	}
+	if c.UserId == "" {
+		return errors.New("token has no subject")
+	}
+	if c.Use != UseAccess && c.Use != UseRefresh {
+		return errors.New("token has an unknown use")
+	}
+	if c.Use == UseRefresh && c.Id == "" {
+		return errors.New("refresh token has no ID")
+	}
+	audience, ok := audiences[c.Type]
+	if !ok {
+		return errors.New("token has an unknown principal type")
+	}
+	if !c.VerifyIssuer(Issuer, true) {
+		return errors.New("token has an invalid issuer")
+	}
+	if !c.VerifyAudience(audience, true) {
+		return errors.New("token has an invalid audience")
+	}
+	return nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(cfg.JwtSecret))
+// TokenService signs and validates the tokens of the service, it is built once from the configuration
+type TokenService struct {
+	secret []byte
+	config config.Config
+}
 
-	return tokenString, err
+func NewTokenService(cfg config.Config) *TokenService {
+	return &TokenService{secret: []byte(cfg.JwtSecret), config: cfg}
 }
 
-func GenerateRefreshToken(userID string, cfg config.Config) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour * 14)
+// RefreshTokenLifetime is how long the refresh tokens issued now stay valid
+func (s *TokenService) RefreshTokenLifetime() time.Duration {
+	return time.Duration(s.config.RefreshTokenLifetime)
+}
+
+// IsAdmin reports whether the account is listed as an administrator, its tokens then carry the admin principal type
+func (s *TokenService) IsAdmin(id string) bool {
+	return s.config.IsAdmin(id)
+}
+
+func (s *TokenService) GenerateToken(userID string, principalType PrincipalType) (string, error) {
+	return s.generate(userID, principalType, UseAccess, "", time.Now().Add(time.Duration(s.config.AccessTokenLifetime)))
+}
+
+// GenerateRefreshToken signs a refresh token, tokenID is its jti and must be persisted to allow rotation and revocation
+func (s *TokenService) GenerateRefreshToken(userID string, principalType PrincipalType, tokenID string, expirationTime time.Time) (string, error) {
+	return s.generate(userID, principalType, UseRefresh, tokenID, expirationTime)
+}
+
+func (s *TokenService) generate(userID string, principalType PrincipalType, use string, tokenID string, expirationTime time.Time) (string, error) {
+	audience, ok := audiences[principalType]
+	if !ok {
+		return "", errors.New("unknown principal type")
+	}
 
 	claims := &Claims{
 		UserId: userID,
+		Type:   principalType,
+		Roles:  roles[principalType],
+		Use:    use,
 		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
 			ExpiresAt: expirationTime.Unix(),
+			IssuedAt:  time.Now().Unix(),
+			Issuer:    Issuer,
+			Audience:  audience,
+			Subject:   userID,
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.JwtSecret))
+	return token.SignedString(s.secret)
 }
 
-// ValidateToken validates the JWT token
-func ValidateToken(tokenString string, cfg config.Config) (*jwt.Token, error) {
+// ValidateToken validates the JWT token, including its issuer, audience and principal type
+func (s *TokenService) ValidateToken(tokenString string) (*jwt.Token, error) {
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(cfg.JwtSecret), nil
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return s.secret, nil
 	})
 
 	return token, err
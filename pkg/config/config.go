@@ -2,27 +2,217 @@ package config
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
+// MinJwtSecretLength is the shortest JwtSecret the server accepts, 32 bytes matches the HS256 key size
+const MinJwtSecretLength = 32
+
+// DefaultConfigFile is read when no config file is given, the server also starts without it
+const DefaultConfigFile = "config/config.json"
+
 type Config struct {
-	MongoDbUrl string `json:"MongoDbUrl"`
-	JwtSecret  string `json:"JwtSecret"`
+	MongoDbUrl   string       `json:"MongoDbUrl"`
+	DatabaseName string       `json:"DatabaseName"`
+	JwtSecret    string       `json:"JwtSecret"`
+	Admins       []string     `json:"Admins"`
+	Server       ServerConfig `json:"Server"`
+	// AccessTokenLifetime and RefreshTokenLifetime are how long newly issued tokens stay valid
+	AccessTokenLifetime  Duration `json:"AccessTokenLifetime"`
+	RefreshTokenLifetime Duration `json:"RefreshTokenLifetime"`
+	// BcryptCost is the work factor of new password hashes
+	BcryptCost int `json:"BcryptCost"`
+	// CORSOrigins are the browser origins allowed to call the API, "*" allows any
+	CORSOrigins []string `json:"CORSOrigins"`
+	// TokenPurgeInterval is how often expired refresh tokens are deleted
+	TokenPurgeInterval Duration `json:"TokenPurgeInterval"`
+	// Verification controls the codes sent to confirm phone numbers
+	Verification VerificationConfig `json:"Verification"`
+	// PasswordReset controls the tokens sent to reset a forgotten password
+	PasswordReset PasswordResetConfig `json:"PasswordReset"`
+	// SMS selects how text messages are delivered
+	SMS SMSConfig `json:"SMS"`
+}
+
+// PasswordResetConfig limits how password reset tokens are issued
+type PasswordResetConfig struct {
+	// TokenLifetime is how long a sent token can reset the password
+	TokenLifetime Duration `json:"TokenLifetime"`
+	// ResendCooldown is how long an account waits before another token is sent
+	ResendCooldown Duration `json:"ResendCooldown"`
+}
+
+// VerificationConfig limits how phone verification codes are used
+type VerificationConfig struct {
+	// CodeLifetime is how long a sent code can be confirmed
+	CodeLifetime Duration `json:"CodeLifetime"`
+	// MaxAttempts is how many wrong codes a user may try before they must request a new one
+	MaxAttempts int `json:"MaxAttempts"`
+	// ResendCooldown is how long a user waits before another code is sent
+	ResendCooldown Duration `json:"ResendCooldown"`
+	// MaxSendsPerDay is how many codes a user is sent in any 24 hours
+	MaxSendsPerDay int `json:"MaxSendsPerDay"`
+}
+
+// SMS senders, "log" writes messages to the server log and "file" appends them to SMSConfig.File.
+// Neither reaches a phone, they stand in for a provider during development and tests.
+const (
+	SMSSenderLog  = "log"
+	SMSSenderFile = "file"
+)
+
+type SMSConfig struct {
+	Sender string `json:"Sender"`
+	File   string `json:"File"`
+}
+
+// ServerConfig controls the HTTP listener, TLS is enabled when both the certificate and key files are set
+type ServerConfig struct {
+	Address      string   `json:"Address"`
+	ReadTimeout  Duration `json:"ReadTimeout"`
+	WriteTimeout Duration `json:"WriteTimeout"`
+	IdleTimeout  Duration `json:"IdleTimeout"`
+	// ShutdownTimeout bounds how long in-flight requests may run once the server is asked to stop
+	ShutdownTimeout Duration `json:"ShutdownTimeout"`
+	// MaxBodyBytes is the largest request body accepted, larger ones are refused with 413
+	MaxBodyBytes int    `json:"MaxBodyBytes"`
+	TLSCertFile  string `json:"TLSCertFile"`
+	TLSKeyFile   string `json:"TLSKeyFile"`
+}
+
+// TLS reports whether the server should serve HTTPS
+func (s ServerConfig) TLS() bool {
+	return s.TLSCertFile != "" && s.TLSKeyFile != ""
+}
+
+// Duration is a time.Duration written as a string such as "15s" in the config file
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("duration must be a string such as \"15s\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Default returns the configuration used for every setting the file, environment and flags leave out
+func Default() Config {
+	return Config{
+		MongoDbUrl:   "mongodb://localhost:27017",
+		DatabaseName: "bookandrate",
+		Server: ServerConfig{
+			Address:         ":8080",
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(15 * time.Second),
+			IdleTimeout:     Duration(60 * time.Second),
+			ShutdownTimeout: Duration(30 * time.Second),
+			MaxBodyBytes:    1 << 20,
+		},
+		AccessTokenLifetime:  Duration(time.Hour),
+		RefreshTokenLifetime: Duration(14 * 24 * time.Hour),
+		BcryptCost:           14,
+		TokenPurgeInterval:   Duration(time.Hour),
+		Verification: VerificationConfig{
+			CodeLifetime:   Duration(10 * time.Minute),
+			MaxAttempts:    5,
+			ResendCooldown: Duration(time.Minute),
+			MaxSendsPerDay: 5,
+		},
+		PasswordReset: PasswordResetConfig{
+			TokenLifetime:  Duration(30 * time.Minute),
+			ResendCooldown: Duration(time.Minute),
+		},
+		SMS: SMSConfig{Sender: SMSSenderLog},
+	}
+}
+
+// IsAdmin reports whether the account ID is listed as an administrator
+func (c Config) IsAdmin(id string) bool {
+	for _, admin := range c.Admins {
+		if admin == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the settings that would otherwise only fail, or silently weaken the service, once it is running
+func (c Config) Validate() error {
+	var problems []string
+	check := func(ok bool, problem string) {
+		if !ok {
+			problems = append(problems, problem)
+		}
+	}
+
+	check(c.MongoDbUrl != "", "MongoDbUrl is required")
+	check(c.DatabaseName != "", "DatabaseName is required")
+	check(len(c.JwtSecret) >= MinJwtSecretLength, fmt.Sprintf("JwtSecret must be at least %d characters", MinJwtSecretLength))
+	check(len(c.JwtSecret) < MinJwtSecretLength || !weakSecret(c.JwtSecret), "JwtSecret is too repetitive, generate a random one")
+	check(c.Server.Address != "", "Server.Address is required")
+	check(c.Server.ReadTimeout > 0 && c.Server.WriteTimeout > 0 && c.Server.IdleTimeout > 0, "Server timeouts must be positive")
+	check(c.Server.ShutdownTimeout > 0, "Server.ShutdownTimeout must be positive")
+	check(c.Server.MaxBodyBytes > 0, "Server.MaxBodyBytes must be positive")
+	check((c.Server.TLSCertFile == "") == (c.Server.TLSKeyFile == ""), "Server.TLSCertFile and Server.TLSKeyFile must be set together")
+	check(c.AccessTokenLifetime > 0, "AccessTokenLifetime must be positive")
+	check(c.RefreshTokenLifetime > c.AccessTokenLifetime, "RefreshTokenLifetime must be longer than AccessTokenLifetime")
+	check(c.BcryptCost >= bcrypt.MinCost && c.BcryptCost <= bcrypt.MaxCost, fmt.Sprintf("BcryptCost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	check(c.TokenPurgeInterval > 0, "TokenPurgeInterval must be positive")
+	check(c.Verification.CodeLifetime > 0, "Verification.CodeLifetime must be positive")
+	check(c.Verification.MaxAttempts > 0, "Verification.MaxAttempts must be positive")
+	check(c.Verification.ResendCooldown >= 0, "Verification.ResendCooldown must not be negative")
+	check(c.Verification.MaxSendsPerDay > 0, "Verification.MaxSendsPerDay must be positive")
+	check(c.PasswordReset.TokenLifetime > 0, "PasswordReset.TokenLifetime must be positive")
+	check(c.PasswordReset.ResendCooldown >= 0, "PasswordReset.ResendCooldown must not be negative")
+	check(c.SMS.Sender == SMSSenderLog || c.SMS.Sender == SMSSenderFile, fmt.Sprintf("SMS.Sender must be %q or %q", SMSSenderLog, SMSSenderFile))
+	check(c.SMS.Sender != SMSSenderFile || c.SMS.File != "", "SMS.File is required by the file sender")
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
 }
 
-func LoadConfig(configFileName string) *Config {
-	var config Config
+// minJwtSecretAlphabet is the fewest distinct characters a JwtSecret may use,
+// it rejects repeated placeholders such as "secretsecret..." that are long enough but trivially guessed
+const minJwtSecretAlphabet = 10
 
+func weakSecret(secret string) bool {
+	distinct := make(map[rune]bool)
+	for _, r := range secret {
+		distinct[r] = true
+	}
+	return len(distinct) < minJwtSecretAlphabet
+}
+
+// loadFile reads the config file over c, fields the file leaves out keep their value
+func loadFile(c *Config, configFileName string) error {
 	configFile, err := os.Open(configFileName)
 	if err != nil {
-		log.Fatal("Cannot open config file: ", err)
-		panic(err)
+		return fmt.Errorf("cannot open config file: %w", err)
 	}
 	defer configFile.Close()
 
 	jsonParser := json.NewDecoder(configFile)
-	jsonParser.Decode(&config)
-
-	return &config
+	jsonParser.DisallowUnknownFields()
+	if err := jsonParser.Decode(c); err != nil {
+		return fmt.Errorf("cannot parse config file %s: %w", configFileName, err)
+	}
+	return nil
 }
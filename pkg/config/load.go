@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix starts the name of every environment variable the configuration reads
+const EnvPrefix = "BOOKANDRATE_"
+
+// setting is a configuration field that can be overridden from the environment and the command line
+type setting struct {
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+// env is the environment variable of the setting, the flag name in upper snake case
+func (s setting) env() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(s.flag, "-", "_"))
+}
+
+func stringSetting(name, usage string, field func(c *Config) *string) setting {
+	return setting{flag: name, usage: usage, set: func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}}
+}
+
+func listSetting(name, usage string, field func(c *Config) *[]string) setting {
+	return setting{flag: name, usage: usage + ", comma separated", set: func(c *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}}
+}
+
+func durationSetting(name, usage string, field func(c *Config) *Duration) setting {
+	return setting{flag: name, usage: usage + ", such as 15s", set: func(c *Config, value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = Duration(parsed)
+		return nil
+	}}
+}
+
+func intSetting(name, usage string, field func(c *Config) *int) setting {
+	return setting{flag: name, usage: usage, set: func(c *Config, value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}}
+}
+
+var settings = []setting{
+	stringSetting("mongo-url", "MongoDB connection string", func(c *Config) *string { return &c.MongoDbUrl }),
+	stringSetting("database", "MongoDB database name", func(c *Config) *string { return &c.DatabaseName }),
+	stringSetting("jwt-secret", "secret signing the tokens, at least 32 characters", func(c *Config) *string { return &c.JwtSecret }),
+	listSetting("admins", "IDs of the administrator accounts", func(c *Config) *[]string { return &c.Admins }),
+	stringSetting("address", "address the server listens on", func(c *Config) *string { return &c.Server.Address }),
+	durationSetting("read-timeout", "longest time to read a request", func(c *Config) *Duration { return &c.Server.ReadTimeout }),
+	durationSetting("write-timeout", "longest time to write a response", func(c *Config) *Duration { return &c.Server.WriteTimeout }),
+	durationSetting("idle-timeout", "longest time to keep an idle connection open", func(c *Config) *Duration { return &c.Server.IdleTimeout }),
+	durationSetting("shutdown-timeout", "longest time to drain requests on shutdown", func(c *Config) *Duration { return &c.Server.ShutdownTimeout }),
+	intSetting("max-body-bytes", "largest request body accepted", func(c *Config) *int { return &c.Server.MaxBodyBytes }),
+	stringSetting("tls-cert-file", "TLS certificate, enables HTTPS along with the key", func(c *Config) *string { return &c.Server.TLSCertFile }),
+	stringSetting("tls-key-file", "TLS private key", func(c *Config) *string { return &c.Server.TLSKeyFile }),
+	durationSetting("access-token-lifetime", "how long access tokens stay valid", func(c *Config) *Duration { return &c.AccessTokenLifetime }),
+	durationSetting("refresh-token-lifetime", "how long refresh tokens stay valid", func(c *Config) *Duration { return &c.RefreshTokenLifetime }),
+	intSetting("bcrypt-cost", "work factor of new password hashes", func(c *Config) *int { return &c.BcryptCost }),
+	listSetting("cors-origins", "browser origins allowed to call the API, * for any", func(c *Config) *[]string { return &c.CORSOrigins }),
+	durationSetting("token-purge-interval", "how often expired refresh tokens are deleted", func(c *Config) *Duration { return &c.TokenPurgeInterval }),
+	durationSetting("verification-code-lifetime", "how long phone verification codes stay valid", func(c *Config) *Duration { return &c.Verification.CodeLifetime }),
+	intSetting("verification-max-attempts", "wrong codes allowed before a new one must be requested", func(c *Config) *int { return &c.Verification.MaxAttempts }),
+	durationSetting("verification-resend-cooldown", "wait before another verification code is sent", func(c *Config) *Duration { return &c.Verification.ResendCooldown }),
+	intSetting("verification-max-sends", "verification codes sent to a user in any 24 hours", func(c *Config) *int { return &c.Verification.MaxSendsPerDay }),
+	durationSetting("password-reset-token-lifetime", "how long password reset tokens stay valid", func(c *Config) *Duration { return &c.PasswordReset.TokenLifetime }),
+	durationSetting("password-reset-cooldown", "wait before another password reset token is sent", func(c *Config) *Duration { return &c.PasswordReset.ResendCooldown }),
+	stringSetting("sms-sender", "how text messages are delivered, log or file", func(c *Config) *string { return &c.SMS.Sender }),
+	stringSetting("sms-file", "file the file sender appends text messages to", func(c *Config) *string { return &c.SMS.File }),
+}
+
+// Load builds the configuration in layers, each overriding the previous one: the defaults, the config file,
+// the BOOKANDRATE_* environment variables and finally the command line flags. The result is validated.
+// The file is given by -config or BOOKANDRATE_CONFIG, DefaultConfigFile is read when it exists.
+func Load(name string, args []string, getenv func(string) string, output io.Writer) (*Config, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(output)
+
+	configFile := flags.String("config", "", "config file, "+EnvPrefix+"CONFIG")
+	type override struct {
+		setting setting
+		value   string
+	}
+	var overrides []override
+	for _, s := range settings {
+		s := s
+		flags.Func(s.flag, s.usage+", "+s.env(), func(value string) error {
+			overrides = append(overrides, override{s, value})
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	config := Default()
+
+	file, explicit := *configFile, true
+	if file == "" {
+		file = getenv(EnvPrefix + "CONFIG")
+	}
+	if file == "" {
+		file, explicit = DefaultConfigFile, false
+	}
+	if err := loadFile(&config, file); err != nil && (explicit || !errors.Is(err, os.ErrNotExist)) {
+		return nil, err
+	}
+
+	for _, s := range settings {
+		if value, ok := lookup(getenv, s.env()); ok {
+			if err := s.set(&config, value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", s.env(), err)
+			}
+		}
+	}
+
+	for _, o := range overrides {
+		if err := o.setting.set(&config, o.value); err != nil {
+			return nil, fmt.Errorf("invalid -%s: %w", o.setting.flag, err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return &config, nil
+}
+
+// lookup treats an empty variable as unset, so blank entries of an env file do not wipe out the file settings
+func lookup(getenv func(string) string, name string) (string, bool) {
+	value := getenv(name)
+	return value, value != ""
+}
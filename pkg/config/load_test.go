@@ -0,0 +1,118 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const strongSecret = "k3Jx9Qw2Lm7Pz4Rt8Vb1Nc6Yh5Gd0Fs2"
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestLoadLayers(t *testing.T) {
+	file := writeConfig(t, `{"JwtSecret": "`+strongSecret+`", "DatabaseName": "from-file", "BcryptCost": 10, "Server": {"Address": ":9000"}}`)
+
+	cfg, err := Load("server", []string{"-config", file, "-address", ":9100"}, env(map[string]string{
+		"BOOKANDRATE_DATABASE":     "from-env",
+		"BOOKANDRATE_ADDRESS":      ":9050",
+		"BOOKANDRATE_CORS_ORIGINS": "https://a.example, https://b.example",
+		"BOOKANDRATE_BCRYPT_COST":  "",
+	}), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.DatabaseName != "from-env" {
+		t.Errorf("the environment should override the file, got database %q", cfg.DatabaseName)
+	}
+	if cfg.Server.Address != ":9100" {
+		t.Errorf("flags should override the environment, got address %q", cfg.Server.Address)
+	}
+	if cfg.BcryptCost != 10 {
+		t.Errorf("an empty variable should leave the file setting, got bcrypt cost %d", cfg.BcryptCost)
+	}
+	if time.Duration(cfg.Server.ReadTimeout) != 15*time.Second {
+		t.Errorf("settings left out should keep their default, got read timeout %v", time.Duration(cfg.Server.ReadTimeout))
+	}
+	if len(cfg.CORSOrigins) != 2 || cfg.CORSOrigins[1] != "https://b.example" {
+		t.Errorf("unexpected CORS origins %q", cfg.CORSOrigins)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Run("default file is optional", func(t *testing.T) {
+		dir, _ := os.Getwd()
+		t.Cleanup(func() { os.Chdir(dir) })
+		os.Chdir(t.TempDir())
+
+		cfg, err := Load("server", []string{"-jwt-secret", strongSecret}, env(nil), io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.DatabaseName != "bookandrate" {
+			t.Errorf("expected the default database, got %q", cfg.DatabaseName)
+		}
+	})
+
+	t.Run("explicit file must exist", func(t *testing.T) {
+		_, err := Load("server", nil, env(map[string]string{"BOOKANDRATE_CONFIG": "missing.json", "BOOKANDRATE_JWT_SECRET": strongSecret}), io.Discard)
+		if err == nil {
+			t.Fatal("expected an error for a missing config file")
+		}
+	})
+
+	t.Run("unknown fields are rejected", func(t *testing.T) {
+		file := writeConfig(t, `{"JwtSecret": "`+strongSecret+`", "MongoUrl": "mongodb://typo"}`)
+		if _, err := Load("server", []string{"-config", file}, env(nil), io.Discard); err == nil {
+			t.Fatal("expected an error for a misspelt field")
+		}
+	})
+
+	t.Run("malformed file", func(t *testing.T) {
+		file := writeConfig(t, `{"JwtSecret": `)
+		if _, err := Load("server", []string{"-config", file}, env(nil), io.Discard); err == nil {
+			t.Fatal("expected an error for malformed JSON")
+		}
+	})
+}
+
+func TestLoadRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		problem string
+	}{
+		{"missing secret", nil, "JwtSecret must be at least"},
+		{"short secret", []string{"-jwt-secret", "s3cr3t"}, "JwtSecret must be at least"},
+		{"repetitive secret", []string{"-jwt-secret", strings.Repeat("secret", 6)}, "JwtSecret is too repetitive"},
+		{"bcrypt cost", []string{"-jwt-secret", strongSecret, "-bcrypt-cost", "40"}, "BcryptCost"},
+		{"token lifetimes", []string{"-jwt-secret", strongSecret, "-refresh-token-lifetime", "30m"}, "RefreshTokenLifetime"},
+		{"half TLS", []string{"-jwt-secret", strongSecret, "-tls-cert-file", "cert.pem"}, "TLSKeyFile"},
+		{"malformed duration", []string{"-jwt-secret", strongSecret, "-read-timeout", "soon"}, "-read-timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeConfig(t, `{}`)
+			_, err := Load("server", append([]string{"-config", file}, tt.args...), env(nil), io.Discard)
+			if err == nil || !strings.Contains(err.Error(), tt.problem) {
+				t.Fatalf("expected an error mentioning %q, got %v", tt.problem, err)
+			}
+		})
+	}
+}
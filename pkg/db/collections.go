@@ -1,19 +1,14 @@
 package db
 
-import "go.mongodb.org/mongo-driver/mongo"
-
-var (
-	UserCollection       *mongo.Collection
-	RestaurantCollection *mongo.Collection
-	BookingCollection    *mongo.Collection
-	RateCollection       *mongo.Collection
+const (
+	UserCollection              = "users"
+	RestaurantCollection        = "restaurants"
+	TableCollection             = "tables"
+	BookingCollection           = "bookings"
+	RateCollection              = "rates"
+	RefreshTokenCollection      = "refresh_tokens"
+	MigrationCollection         = "migrations"
+	PhoneVerificationCollection = "phone_verifications"
+	PasswordResetCollection     = "password_resets"
+	TableReservationCollection  = "table_reservations"
 )
-
-var db = Client.Database("bookandrate")
-
-func InitializeCollections() {
-	UserCollection = db.Collection("users")
-	RestaurantCollection = db.Collection("restaurants")
-	BookingCollection = db.Collection("bookings")
-	RateCollection = db.Collection("rates")
-}
@@ -2,21 +2,21 @@ package db
 
 import (
 	"context"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
 )
 
-var Client *mongo.Client
-
-func Connect(connectionString string) {
-	var err error
-
-	Client, err = mongo.Connect(context.TODO(), options.Client().ApplyURI(connectionString))
+// Connect opens a client to MongoDB and makes sure the server answers
+func Connect(ctx context.Context, connectionString string) (*mongo.Client, error) {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(connectionString))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	err = Client.Ping(context.TODO(), nil)
-	log.Println("Connected to MongoDB!")
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(ctx)
+		return nil, err
+	}
+	return client, nil
 }
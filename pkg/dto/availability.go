@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"book-and-rate/pkg/scheduling"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SlotResponse is a bookable time. The tables that would seat the party are left out, they are allocated on booking.
+type SlotResponse struct {
+	Time time.Time `json:"time"`
+}
+
+func NewSlotResponses(slots []scheduling.Slot) []SlotResponse {
+	responses := make([]SlotResponse, 0, len(slots))
+	for _, slot := range slots {
+		responses = append(responses, SlotResponse{Time: slot.Time})
+	}
+	return responses
+}
+
+// RestaurantAvailabilityResponse is a restaurant with free slots near the searched time
+type RestaurantAvailabilityResponse struct {
+	RestaurantID primitive.ObjectID `json:"restaurantId"`
+	Name         string             `json:"name"`
+	Slots        []SlotResponse     `json:"slots"`
+}
+
+// RestaurantAvailabilityPage is one page of the restaurants searched for free slots, Next links to the following page and
+// is left out on the last one. A page only holds the restaurants with free slots, so it may be short or empty before the last.
+type RestaurantAvailabilityPage struct {
+	Items []RestaurantAvailabilityResponse `json:"items"`
+	Next  string                           `json:"next,omitempty"`
+}
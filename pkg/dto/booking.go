@@ -0,0 +1,187 @@
+package dto
+
+import (
+	"book-and-rate/pkg/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BookingRequest is the body of the create and update booking endpoints.
+// Tables are allocated and the status is moved by the server, so neither can be set.
+type BookingRequest struct {
+	UserID          primitive.ObjectID `json:"userId" validate:"required"`
+	RestaurantID    primitive.ObjectID `json:"restaurantId" validate:"required"`
+	Date            time.Time          `json:"date" validate:"required,future"`
+	EndDate         time.Time          `json:"endDate" validate:"future"`
+	PartySize       int                `json:"partySize" validate:"required"`
+	SpecialRequests string             `json:"specialRequests"`
+	Occasion        string             `json:"occasion"`
+	DietaryNotes    string             `json:"dietaryNotes"`
+	ContactPhone    string             `json:"contactPhone"`
+}
+
+// Validate checks the details against the limits of the booking model
+func (b BookingRequest) Validate() error {
+	if err := models.ValidatePartySize(b.PartySize); err != nil {
+		return err
+	}
+	if err := models.ValidateBookingNote("specialRequests", b.SpecialRequests); err != nil {
+		return err
+	}
+	if err := models.ValidateBookingNote("dietaryNotes", b.DietaryNotes); err != nil {
+		return err
+	}
+	return models.ValidateOccasion(b.Occasion)
+}
+
+func (b BookingRequest) Model() models.Booking {
+	return models.Booking{
+		UserID:          b.UserID,
+		RestaurantID:    b.RestaurantID,
+		Date:            b.Date,
+		EndDate:         b.EndDate,
+		PartySize:       b.PartySize,
+		SpecialRequests: b.SpecialRequests,
+		Occasion:        b.Occasion,
+		DietaryNotes:    b.DietaryNotes,
+		ContactPhone:    b.ContactPhone,
+	}
+}
+
+// BookingPatch is the body of the partial booking update, fields left out keep their value.
+// The guest and restaurant of a booking never change.
+type BookingPatch struct {
+	Date            *time.Time `json:"date" validate:"future"`
+	EndDate         *time.Time `json:"endDate" validate:"future"`
+	PartySize       *int       `json:"partySize"`
+	SpecialRequests *string    `json:"specialRequests"`
+	Occasion        *string    `json:"occasion"`
+	DietaryNotes    *string    `json:"dietaryNotes"`
+	ContactPhone    *string    `json:"contactPhone"`
+}
+
+// Immutable lists the booking fields a partial update may not name
+func (BookingPatch) Immutable() []string {
+	return []string{"id", "userId", "restaurantId", "tableIds", "status", "history"}
+}
+
+func (p BookingPatch) Validate() error {
+	if p.Date != nil && p.Date.IsZero() {
+		return models.FieldError{Field: "date", Message: "cannot be empty"}
+	}
+	if p.PartySize != nil {
+		if err := models.ValidatePartySize(*p.PartySize); err != nil {
+			return err
+		}
+	}
+	if p.SpecialRequests != nil {
+		if err := models.ValidateBookingNote("specialRequests", *p.SpecialRequests); err != nil {
+			return err
+		}
+	}
+	if p.DietaryNotes != nil {
+		if err := models.ValidateBookingNote("dietaryNotes", *p.DietaryNotes); err != nil {
+			return err
+		}
+	}
+	if p.Occasion != nil {
+		return models.ValidateOccasion(*p.Occasion)
+	}
+	return nil
+}
+
+// Apply copies the supplied fields onto the booking. A booking moved without a new end date keeps its length.
+func (p BookingPatch) Apply(booking *models.Booking) {
+	if p.Date != nil {
+		if !booking.EndDate.IsZero() {
+			booking.EndDate = p.Date.Add(booking.EndDate.Sub(booking.Date))
+		}
+		booking.Date = *p.Date
+	}
+	if p.EndDate != nil {
+		booking.EndDate = *p.EndDate
+	}
+	if p.PartySize != nil {
+		booking.PartySize = *p.PartySize
+	}
+	if p.SpecialRequests != nil {
+		booking.SpecialRequests = *p.SpecialRequests
+	}
+	if p.Occasion != nil {
+		booking.Occasion = *p.Occasion
+	}
+	if p.DietaryNotes != nil {
+		booking.DietaryNotes = *p.DietaryNotes
+	}
+	if p.ContactPhone != nil {
+		booking.ContactPhone = *p.ContactPhone
+	}
+}
+
+type BookingResponse struct {
+	ID              primitive.ObjectID   `json:"id"`
+	UserID          primitive.ObjectID   `json:"userId"`
+	RestaurantID    primitive.ObjectID   `json:"restaurantId"`
+	Date            time.Time            `json:"date"`
+	EndDate         time.Time            `json:"endDate"`
+	PartySize       int                  `json:"partySize"`
+	TableIDs        []primitive.ObjectID `json:"tableIds"`
+	SpecialRequests string               `json:"specialRequests,omitempty"`
+	Occasion        string               `json:"occasion,omitempty"`
+	DietaryNotes    string               `json:"dietaryNotes,omitempty"`
+	ContactPhone    string               `json:"contactPhone,omitempty"`
+	Status          models.BookingStatus `json:"status"`
+	History         []StatusChange       `json:"history"`
+}
+
+// StatusChange is a transition of a booking and who made it
+type StatusChange struct {
+	From      models.BookingStatus `json:"from,omitempty"`
+	To        models.BookingStatus `json:"to"`
+	At        time.Time            `json:"at"`
+	ActorID   string               `json:"actorId"`
+	ActorType string               `json:"actorType"`
+}
+
+func NewBookingResponse(booking models.Booking) BookingResponse {
+	response := BookingResponse{
+		ID:              booking.ID,
+		UserID:          booking.UserID,
+		RestaurantID:    booking.RestaurantID,
+		Date:            booking.Date,
+		EndDate:         booking.EndDate,
+		PartySize:       booking.PartySize,
+		TableIDs:        append([]primitive.ObjectID{}, booking.TableIDs...),
+		SpecialRequests: booking.SpecialRequests,
+		Occasion:        booking.Occasion,
+		DietaryNotes:    booking.DietaryNotes,
+		ContactPhone:    booking.ContactPhone,
+		Status:          booking.Status,
+		History:         make([]StatusChange, 0, len(booking.History)),
+	}
+	for _, change := range booking.History {
+		response.History = append(response.History, StatusChange{
+			From:      change.From,
+			To:        change.To,
+			At:        change.At,
+			ActorID:   change.ActorID,
+			ActorType: change.ActorType,
+		})
+	}
+	return response
+}
+
+// BookingPage is one page of a list of bookings, Next links to the following page and is left out on the last one
+type BookingPage struct {
+	Items []BookingResponse `json:"items"`
+	Next  string            `json:"next,omitempty"`
+}
+
+func NewBookingResponses(bookings []models.Booking) []BookingResponse {
+	responses := make([]BookingResponse, 0, len(bookings))
+	for _, booking := range bookings {
+		responses = append(responses, NewBookingResponse(booking))
+	}
+	return responses
+}
@@ -0,0 +1,66 @@
+// Package dto holds the JSON bodies of the API. Requests carry only the fields a client may set
+// and responses only the fields a client may see, so the models can change without changing the API.
+package dto
+
+import (
+	"book-and-rate/pkg/models"
+	"strings"
+	"time"
+)
+
+// LoginRequest is the body of the user and restaurant login endpoints
+type LoginRequest struct {
+	Phone    string `json:"phone" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// TokenResponse is the pair of tokens returned on login and refresh
+type TokenResponse struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// RefreshTokenRequest is the body of the refresh and logout endpoints
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// VerificationStartedResponse tells when the texted code expires and when another can be requested
+type VerificationStartedResponse struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+	ResendAt  time.Time `json:"resendAt"`
+}
+
+// VerificationConfirmRequest is the body of the phone verification confirm endpoint
+type VerificationConfirmRequest struct {
+	Code string `json:"code" validate:"required,max=10"`
+}
+
+// ForgotPasswordRequest is the body of the forgot password endpoints
+type ForgotPasswordRequest struct {
+	Phone string `json:"phone" validate:"required"`
+}
+
+// ResetPasswordRequest is the body of the reset password endpoints
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// notBlank rejects a field that was supplied in a partial update but left empty
+func notBlank(field string, value *string) error {
+	if value != nil && strings.TrimSpace(*value) == "" {
+		return models.FieldError{Field: field, Message: "cannot be empty"}
+	}
+	return nil
+}
+
+// firstError returns the first of the field errors that is not nil
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package dto
+
+// ErrorResponse is the body of every error answer. Code is stable for clients to branch on, Message is for people
+// and may change. Fields lists the request fields that failed validation, and RequestID matches the X-Request-ID header.
+type ErrorResponse struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+}
+
+// FieldError names a request field, by its JSON name, and what is wrong with it
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,86 @@
+package dto
+
+import (
+	"book-and-rate/pkg/models"
+	"time"
+)
+
+// OpeningHours is both the body and the response of the opening hours endpoints, see models.OpeningHours
+type OpeningHours struct {
+	Timezone     string          `json:"timezone" validate:"max=64"`
+	SlotInterval int             `json:"slotInterval" validate:"min=5,max=240"`
+	Weekly       []DayHours      `json:"weekly"`
+	Exceptions   []ExceptionDate `json:"exceptions"`
+}
+
+// DayHours are the service periods of a weekday, 0 being Sunday
+type DayHours struct {
+	Weekday time.Weekday    `json:"weekday" validate:"min=0,max=6"`
+	Periods []ServicePeriod `json:"periods"`
+}
+
+type ExceptionDate struct {
+	Date    string          `json:"date" validate:"required"`
+	Closed  bool            `json:"closed"`
+	Periods []ServicePeriod `json:"periods"`
+	Reason  string          `json:"reason" validate:"max=200"`
+}
+
+type ServicePeriod struct {
+	Open        string `json:"open" validate:"required"`
+	Close       string `json:"close" validate:"required"`
+	LastSeating string `json:"lastSeating,omitempty"`
+}
+
+func NewOpeningHours(hours models.OpeningHours) OpeningHours {
+	response := OpeningHours{
+		Timezone:     hours.Timezone,
+		SlotInterval: hours.SlotInterval,
+		Weekly:       make([]DayHours, 0, len(hours.Weekly)),
+		Exceptions:   make([]ExceptionDate, 0, len(hours.Exceptions)),
+	}
+	for _, day := range hours.Weekly {
+		response.Weekly = append(response.Weekly, DayHours{Weekday: day.Weekday, Periods: newServicePeriods(day.Periods)})
+	}
+	for _, exception := range hours.Exceptions {
+		response.Exceptions = append(response.Exceptions, ExceptionDate{
+			Date:    exception.Date,
+			Closed:  exception.Closed,
+			Periods: newServicePeriods(exception.Periods),
+			Reason:  exception.Reason,
+		})
+	}
+	return response
+}
+
+func (h OpeningHours) Model() models.OpeningHours {
+	hours := models.OpeningHours{Timezone: h.Timezone, SlotInterval: h.SlotInterval}
+	for _, day := range h.Weekly {
+		hours.Weekly = append(hours.Weekly, models.DayHours{Weekday: day.Weekday, Periods: servicePeriodModels(day.Periods)})
+	}
+	for _, exception := range h.Exceptions {
+		hours.Exceptions = append(hours.Exceptions, models.ExceptionDate{
+			Date:    exception.Date,
+			Closed:  exception.Closed,
+			Periods: servicePeriodModels(exception.Periods),
+			Reason:  exception.Reason,
+		})
+	}
+	return hours
+}
+
+func newServicePeriods(periods []models.ServicePeriod) []ServicePeriod {
+	response := make([]ServicePeriod, 0, len(periods))
+	for _, period := range periods {
+		response = append(response, ServicePeriod{Open: period.Open, Close: period.Close, LastSeating: period.LastSeating})
+	}
+	return response
+}
+
+func servicePeriodModels(periods []ServicePeriod) []models.ServicePeriod {
+	var result []models.ServicePeriod
+	for _, period := range periods {
+		result = append(result, models.ServicePeriod{Open: period.Open, Close: period.Close, LastSeating: period.LastSeating})
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package dto
+
+import (
+	"book-and-rate/pkg/models"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RateRequest is the body of the create rate endpoint
+type RateRequest struct {
+	BookingID primitive.ObjectID `json:"bookingId" validate:"required"`
+	Rating    int                `json:"rating" validate:"required,min=1,max=5"`
+	Comment   string             `json:"comment" validate:"max=1000"`
+}
+
+func (r RateRequest) Model() models.Rate {
+	return models.Rate{BookingID: r.BookingID, Rating: r.Rating, Comment: r.Comment}
+}
+
+// RateUpdateRequest is the body of the update rate endpoint, the booking a rate is for never changes
+type RateUpdateRequest struct {
+	Rating  int    `json:"rating" validate:"required,min=1,max=5"`
+	Comment string `json:"comment" validate:"max=1000"`
+}
+
+func (r RateUpdateRequest) Model() models.Rate {
+	return models.Rate{Rating: r.Rating, Comment: r.Comment}
+}
+
+// RatePatch is the body of the partial rate update, fields left out keep their value
+type RatePatch struct {
+	Rating  *int    `json:"rating" validate:"min=1,max=5"`
+	Comment *string `json:"comment" validate:"max=1000"`
+}
+
+// Immutable lists the rate fields a partial update may not name
+func (RatePatch) Immutable() []string {
+	return []string{"id", "userId", "restaurantId", "bookingId", "date"}
+}
+
+func (p RatePatch) Validate() error {
+	if p.Rating != nil && (*p.Rating < models.MinRating || *p.Rating > models.MaxRating) {
+		return models.FieldError{Field: "rating", Message: fmt.Sprintf("must be between %d and %d", models.MinRating, models.MaxRating)}
+	}
+	return nil
+}
+
+// Apply copies the supplied fields onto the rate
+func (p RatePatch) Apply(rate *models.Rate) {
+	if p.Rating != nil {
+		rate.Rating = *p.Rating
+	}
+	if p.Comment != nil {
+		rate.Comment = *p.Comment
+	}
+}
+
+type RateResponse struct {
+	ID           primitive.ObjectID `json:"id"`
+	UserID       primitive.ObjectID `json:"userId"`
+	RestaurantID primitive.ObjectID `json:"restaurantId"`
+	BookingID    primitive.ObjectID `json:"bookingId"`
+	Rating       int                `json:"rating"`
+	Comment      string             `json:"comment"`
+	Date         time.Time          `json:"date"`
+}
+
+func NewRateResponse(rate models.Rate) RateResponse {
+	return RateResponse{
+		ID:           rate.ID,
+		UserID:       rate.UserID,
+		RestaurantID: rate.RestaurantID,
+		BookingID:    rate.BookingID,
+		Rating:       rate.Rating,
+		Comment:      rate.Comment,
+		Date:         rate.Date,
+	}
+}
+
+// RatePage is one page of a list of rates, Next links to the following page and is left out on the last one
+type RatePage struct {
+	Items []RateResponse `json:"items"`
+	Next  string         `json:"next,omitempty"`
+}
+
+func NewRateResponses(rates []models.Rate) []RateResponse {
+	responses := make([]RateResponse, 0, len(rates))
+	for _, rate := range rates {
+		responses = append(responses, NewRateResponse(rate))
+	}
+	return responses
+}
+
+// AverageRatingResponse is the mean rating of a restaurant, zero when it has not been rated
+type AverageRatingResponse struct {
+	RestaurantID  primitive.ObjectID `json:"restaurantId"`
+	AverageRating float64            `json:"averageRating"`
+	Count         int                `json:"count"`
+}
@@ -0,0 +1,172 @@
+package dto
+
+import (
+	"book-and-rate/pkg/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RestaurantRequest is the body of the restaurant sign up endpoint.
+// Opening hours have their own endpoint and the rating summary is maintained by the server.
+type RestaurantRequest struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	Address  string `json:"address" validate:"max=200"`
+	Phone    string `json:"phone" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+func (r RestaurantRequest) Model() models.Restaurant {
+	return models.Restaurant{
+		Name:     r.Name,
+		Address:  r.Address,
+		Phone:    r.Phone,
+		Password: r.Password,
+	}
+}
+
+// RestaurantUpdateRequest is the body of the update restaurant endpoint, an empty password keeps the current one
+type RestaurantUpdateRequest struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	Address  string `json:"address" validate:"max=200"`
+	Phone    string `json:"phone" validate:"required"`
+	Password string `json:"password" validate:"min=8,max=72"`
+}
+
+func (r RestaurantUpdateRequest) Model() models.Restaurant {
+	return RestaurantRequest(r).Model()
+}
+
+// RestaurantPatch is the body of the partial restaurant update, fields left out keep their value
+type RestaurantPatch struct {
+	Name     *string `json:"name" validate:"max=100"`
+	Address  *string `json:"address" validate:"max=200"`
+	Phone    *string `json:"phone"`
+	Password *string `json:"password" validate:"min=8,max=72"`
+}
+
+// Immutable lists the restaurant fields a partial update may not name
+func (RestaurantPatch) Immutable() []string {
+	return []string{"id", "hours", "ratingSummary"}
+}
+
+func (p RestaurantPatch) Validate() error {
+	return firstError(
+		notBlank("name", p.Name),
+		notBlank("phone", p.Phone),
+		notBlank("password", p.Password),
+	)
+}
+
+// Apply copies the supplied fields onto the restaurant, the password is left to the caller to hash
+func (p RestaurantPatch) Apply(restaurant *models.Restaurant) {
+	if p.Name != nil {
+		restaurant.Name = *p.Name
+	}
+	if p.Address != nil {
+		restaurant.Address = *p.Address
+	}
+	if p.Phone != nil {
+		restaurant.Phone = *p.Phone
+	}
+}
+
+type RestaurantResponse struct {
+	ID            primitive.ObjectID     `json:"id"`
+	Name          string                 `json:"name"`
+	Address       string                 `json:"address"`
+	Phone         string                 `json:"phone"`
+	Hours         *OpeningHours          `json:"hours,omitempty"`
+	RatingSummary *RatingSummaryResponse `json:"ratingSummary,omitempty"`
+}
+
+func NewRestaurantResponse(restaurant models.Restaurant) RestaurantResponse {
+	response := RestaurantResponse{
+		ID:      restaurant.ID,
+		Name:    restaurant.Name,
+		Address: restaurant.Address,
+		Phone:   restaurant.Phone,
+	}
+	if restaurant.Hours != nil {
+		hours := NewOpeningHours(*restaurant.Hours)
+		response.Hours = &hours
+	}
+	if restaurant.RatingSummary != nil {
+		summary := NewRatingSummaryResponse(*restaurant.RatingSummary)
+		response.RatingSummary = &summary
+	}
+	return response
+}
+
+// RestaurantPage is one page of the list of restaurants, Next links to the following page and is left out on the last one
+type RestaurantPage struct {
+	Items []RestaurantResponse `json:"items"`
+	Next  string               `json:"next,omitempty"`
+}
+
+func NewRestaurantResponses(restaurants []models.Restaurant) []RestaurantResponse {
+	responses := make([]RestaurantResponse, 0, len(restaurants))
+	for _, restaurant := range restaurants {
+		responses = append(responses, NewRestaurantResponse(restaurant))
+	}
+	return responses
+}
+
+// RatingSummaryResponse is the rating summary of a restaurant, Histogram counts the ratings per star keyed "1" to "5"
+type RatingSummaryResponse struct {
+	Count         int            `json:"count"`
+	Mean          float64        `json:"mean"`
+	BayesianScore float64        `json:"bayesianScore"`
+	Histogram     map[string]int `json:"histogram"`
+	LastRatedAt   *time.Time     `json:"lastRatedAt,omitempty"`
+}
+
+func NewRatingSummaryResponse(summary models.RatingSummary) RatingSummaryResponse {
+	return RatingSummaryResponse{
+		Count:         summary.Count,
+		Mean:          summary.Mean,
+		BayesianScore: summary.BayesianScore,
+		Histogram:     summary.Histogram,
+		LastRatedAt:   summary.LastRatedAt,
+	}
+}
+
+// TableRequest is the body of the create and update table endpoints
+type TableRequest struct {
+	Name       string `json:"name" validate:"max=50"`
+	Seats      int    `json:"seats" validate:"required,min=1,max=50"`
+	Zone       string `json:"zone" validate:"max=50"`
+	Combinable bool   `json:"combinable"`
+}
+
+func (t TableRequest) Model() models.Table {
+	return models.Table{Name: t.Name, Seats: t.Seats, Zone: t.Zone, Combinable: t.Combinable}
+}
+
+type TableResponse struct {
+	ID           primitive.ObjectID `json:"id"`
+	RestaurantID primitive.ObjectID `json:"restaurantId"`
+	Name         string             `json:"name"`
+	Seats        int                `json:"seats"`
+	Zone         string             `json:"zone"`
+	Combinable   bool               `json:"combinable"`
+}
+
+func NewTableResponse(table models.Table) TableResponse {
+	return TableResponse{
+		ID:           table.ID,
+		RestaurantID: table.RestaurantID,
+		Name:         table.Name,
+		Seats:        table.Seats,
+		Zone:         table.Zone,
+		Combinable:   table.Combinable,
+	}
+}
+
+func NewTableResponses(tables []models.Table) []TableResponse {
+	responses := make([]TableResponse, 0, len(tables))
+	for _, table := range tables {
+		responses = append(responses, NewTableResponse(table))
+	}
+	return responses
+}
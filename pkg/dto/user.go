@@ -0,0 +1,91 @@
+package dto
+
+import (
+	"book-and-rate/pkg/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserRequest is the body of the sign up endpoint
+type UserRequest struct {
+	FirstName   string `json:"firstName" validate:"required,max=100"`
+	LastName    string `json:"lastName" validate:"max=100"`
+	PhoneNumber string `json:"phoneNumber" validate:"required"`
+	Password    string `json:"password" validate:"required,min=8,max=72"`
+}
+
+func (u UserRequest) Model() models.User {
+	return models.User{
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		PhoneNumber: u.PhoneNumber,
+		Password:    u.Password,
+	}
+}
+
+// UserUpdateRequest is the body of the update user endpoint, an empty password keeps the current one
+type UserUpdateRequest struct {
+	FirstName   string `json:"firstName" validate:"required,max=100"`
+	LastName    string `json:"lastName" validate:"max=100"`
+	PhoneNumber string `json:"phoneNumber" validate:"required"`
+	Password    string `json:"password" validate:"min=8,max=72"`
+}
+
+func (u UserUpdateRequest) Model() models.User {
+	return UserRequest(u).Model()
+}
+
+// UserPatch is the body of the partial user update, fields left out keep their value
+type UserPatch struct {
+	FirstName   *string `json:"firstName" validate:"max=100"`
+	LastName    *string `json:"lastName" validate:"max=100"`
+	PhoneNumber *string `json:"phoneNumber"`
+	Password    *string `json:"password" validate:"min=8,max=72"`
+}
+
+// Immutable lists the user fields a partial update may not name
+func (UserPatch) Immutable() []string {
+	return []string{"id", "phoneVerified", "phoneVerifiedAt"}
+}
+
+func (p UserPatch) Validate() error {
+	return firstError(
+		notBlank("firstName", p.FirstName),
+		notBlank("phoneNumber", p.PhoneNumber),
+		notBlank("password", p.Password),
+	)
+}
+
+// Apply copies the supplied fields onto the user, the password is left to the caller to hash
+func (p UserPatch) Apply(user *models.User) {
+	if p.FirstName != nil {
+		user.FirstName = *p.FirstName
+	}
+	if p.LastName != nil {
+		user.LastName = *p.LastName
+	}
+	if p.PhoneNumber != nil {
+		user.PhoneNumber = *p.PhoneNumber
+	}
+}
+
+type UserResponse struct {
+	ID              primitive.ObjectID `json:"id"`
+	FirstName       string             `json:"firstName"`
+	LastName        string             `json:"lastName"`
+	PhoneNumber     string             `json:"phoneNumber"`
+	PhoneVerified   bool               `json:"phoneVerified"`
+	PhoneVerifiedAt *time.Time         `json:"phoneVerifiedAt,omitempty"`
+}
+
+func NewUserResponse(user models.User) UserResponse {
+	return UserResponse{
+		ID:              user.ID,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		PhoneNumber:     user.PhoneNumber,
+		PhoneVerified:   user.PhoneVerified(),
+		PhoneVerifiedAt: user.PhoneVerifiedAt,
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"book-and-rate/pkg/apierror"
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/repository"
+	"book-and-rate/pkg/scheduling"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How far from the requested time SearchAvailabilityHandler looks for slots, unless asked to look further
+const (
+	defaultSearchWindow = time.Hour
+	maxSearchWindow     = 12 * time.Hour
+)
+
+// AvailabilityHandler serves the free slots computed from the opening hours, tables and bookings of the restaurants
+type AvailabilityHandler struct {
+	restaurants repository.RestaurantRepository
+	bookings    repository.BookingRepository
+	logger      *log.Logger
+}
+
+func NewAvailabilityHandler(restaurants repository.RestaurantRepository, bookings repository.BookingRepository, logger *log.Logger) *AvailabilityHandler {
+	return &AvailabilityHandler{restaurants: restaurants, bookings: bookings, logger: logger}
+}
+
+// GetAvailabilityHandler lists the bookable slots of a restaurant on a date for a party size
+func (h *AvailabilityHandler) GetAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	restaurantId, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		h.logger.Printf("GetAvailabilityHandler: Error parsing ID: %v", err)
+		apierror.InvalidID(w, r, "id")
+		return
+	}
+
+	partySize, err := parsePartySize(r)
+	if err != nil {
+		h.logger.Printf("GetAvailabilityHandler: Error parsing party size: %v", err)
+		apierror.Invalid(w, r, err)
+		return
+	}
+
+	restaurant, err := h.restaurants.FindByID(r.Context(), restaurantId)
+	if err != nil {
+		h.logger.Printf("GetAvailabilityHandler: Error finding restaurant: %v", err)
+		apierror.Lookup(w, r, err, "Restaurant not found")
+		return
+	}
+
+	if restaurant.Hours == nil {
+		h.logger.Printf("GetAvailabilityHandler: No opening hours for restaurant: %v", restaurantId)
+		json.NewEncoder(w).Encode([]dto.SlotResponse{})
+		return
+	}
+
+	day, err := scheduling.ParseDay(*restaurant.Hours, r.URL.Query().Get("date"))
+	if err != nil {
+		h.logger.Printf("GetAvailabilityHandler: Error parsing date: %v", err)
+		apierror.InvalidField(w, r, "date", "must be formatted as YYYY-MM-DD")
+		return
+	}
+
+	tables, bookings, err := h.loadSchedule(r.Context(), restaurant, day, day)
+	if err != nil {
+		h.logger.Printf("GetAvailabilityHandler: Error computing availability: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+	available, err := scheduling.AvailableSlots(*restaurant.Hours, tables, bookings, day, partySize, time.Now())
+	if err != nil {
+		h.logger.Printf("GetAvailabilityHandler: Error computing availability: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	h.logger.Printf("GetAvailabilityHandler: Successfully computed availability for restaurant: %v", restaurantId)
+	json.NewEncoder(w).Encode(dto.NewSlotResponses(available))
+}
+
+// SearchAvailabilityHandler lists restaurants with a free slot near the requested time. The restaurants are searched a page
+// at a time in the order of the restaurant list, a page leaving out those without a free slot. A restaurant that cannot be
+// searched fails the page, rather than being left out as if it had no free slot.
+func (h *AvailabilityHandler) SearchAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	requested, err := time.Parse(time.RFC3339, query.Get("time"))
+	if err != nil {
+		h.logger.Printf("SearchAvailabilityHandler: Error parsing time: %v", err)
+		apierror.InvalidField(w, r, "time", "must be an RFC 3339 timestamp")
+		return
+	}
+
+	partySize, err := parsePartySize(r)
+	if err != nil {
+		h.logger.Printf("SearchAvailabilityHandler: Error parsing party size: %v", err)
+		apierror.Invalid(w, r, err)
+		return
+	}
+
+	window := defaultSearchWindow
+	if windowQuery := query.Get("window"); windowQuery != "" {
+		minutes, err := strconv.Atoi(windowQuery)
+		if err != nil || minutes <= 0 || time.Duration(minutes)*time.Minute > maxSearchWindow {
+			h.logger.Printf("SearchAvailabilityHandler: Invalid window: %q", windowQuery)
+			apierror.InvalidField(w, r, "window", fmt.Sprintf("must be a number of minutes between 1 and %d", int(maxSearchWindow.Minutes())))
+			return
+		}
+		window = time.Duration(minutes) * time.Minute
+	}
+
+	var errs models.FieldErrors
+	pager := newPager(r, restaurantSorts, repository.Sort{}, &errs)
+	if len(errs) > 0 {
+		h.logger.Printf("SearchAvailabilityHandler: Invalid parameters: %v", errs)
+		apierror.Invalid(w, r, errs)
+		return
+	}
+
+	restaurants, err := h.restaurants.List(r.Context(), repository.RestaurantFilter{WithHours: true, Page: pager.page()})
+	if err != nil {
+		h.logger.Printf("SearchAvailabilityHandler: Error finding restaurants: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	var next string
+	if pager.more(len(restaurants)) {
+		restaurants = restaurants[:len(restaurants)-1]
+		next = pager.next(repository.RestaurantCursor(restaurants[len(restaurants)-1], pager.sort))
+	}
+
+	start, end := requested.Add(-window), requested.Add(window)
+	results := []dto.RestaurantAvailabilityResponse{}
+	for _, restaurant := range restaurants {
+		if restaurant.Hours == nil {
+			continue
+		}
+
+		tables, bookings, err := h.loadSchedule(r.Context(), restaurant, start, end)
+		if err != nil {
+			h.logger.Printf("SearchAvailabilityHandler: Error computing availability for restaurant %v: %v", restaurant.ID, err)
+			apierror.Internal(w, r, err)
+			return
+		}
+		nearby, err := scheduling.AvailableSlotsBetween(*restaurant.Hours, tables, bookings, start, end, partySize, time.Now())
+		if err != nil {
+			h.logger.Printf("SearchAvailabilityHandler: Error computing availability for restaurant %v: %v", restaurant.ID, err)
+			apierror.Internal(w, r, err)
+			return
+		}
+		if len(nearby) > 0 {
+			results = append(results, dto.RestaurantAvailabilityResponse{RestaurantID: restaurant.ID, Name: restaurant.Name, Slots: dto.NewSlotResponses(nearby)})
+		}
+	}
+
+	h.logger.Printf("SearchAvailabilityHandler: Found %d restaurants with availability", len(results))
+	json.NewEncoder(w).Encode(dto.RestaurantAvailabilityPage{Items: results, Next: next})
+}
+
+// loadSchedule fetches the tables of the restaurant and its active bookings around the days from first to last
+func (h *AvailabilityHandler) loadSchedule(ctx context.Context, restaurant models.Restaurant, first, last time.Time) ([]models.Table, []models.Booking, error) {
+	tables, err := h.restaurants.ListTables(ctx, restaurant.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Fetch a generous window around the days so timezone offsets, periods and bookings running over midnight are covered,
+	// the slots only count the bookings overlapping them
+	from := first.Truncate(24 * time.Hour).Add(-48 * time.Hour)
+	to := last.Truncate(24 * time.Hour).Add(72 * time.Hour)
+	bookings, err := h.bookings.List(ctx, repository.BookingFilter{
+		RestaurantID: restaurant.ID,
+		Statuses:     models.ActiveBookingStatuses,
+		DateTo:       to,
+		EndsAfter:    from,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return tables, bookings, nil
+}
+
+// parsePartySize reads the party size of the query, held to the sizes a booking accepts
+func parsePartySize(r *http.Request) (int, error) {
+	partySize, err := strconv.Atoi(r.URL.Query().Get("partySize"))
+	if err != nil {
+		return 0, models.FieldError{Field: "partySize", Message: "must be a number"}
+	}
+	if err := models.ValidatePartySize(partySize); err != nil {
+		return 0, err
+	}
+	return partySize, nil
+}
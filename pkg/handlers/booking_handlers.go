@@ -1,364 +1,722 @@
 package handlers
 
 import (
-    "book-and-rate/pkg/db"
+    "book-and-rate/pkg/apierror"
+    "book-and-rate/pkg/auth"
+    "book-and-rate/pkg/dto"
     "book-and-rate/pkg/models"
+    "book-and-rate/pkg/phone"
+    "book-and-rate/pkg/policy"
+    "book-and-rate/pkg/repository"
+    "book-and-rate/pkg/scheduling"
     "context"
     "encoding/json"
+    "errors"
     "log"
     "net/http"
+    "strings"
     "time"
 
     "github.com/gorilla/mux"
-    "go.mongodb.org/mongo-driver/bson"
     "go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// BookingHandler serves the bookings and their lifecycle
+type BookingHandler struct {
+    bookings    repository.BookingRepository
+    restaurants repository.RestaurantRepository
+    users       repository.UserRepository
+    logger      *log.Logger
+}
+
+func NewBookingHandler(bookings repository.BookingRepository, restaurants repository.RestaurantRepository, users repository.UserRepository, logger *log.Logger) *BookingHandler {
+    return &BookingHandler{bookings: bookings, restaurants: restaurants, users: users, logger: logger}
+}
+
 // CreateBookingHandler handles the creation of a new booking
-func CreateBookingHandler(w http.ResponseWriter, r *http.Request) {
-    var booking models.Booking
-    if err := json.NewDecoder(r.Body).Decode(&booking); err != nil {
-        log.Printf("CreateBookingHandler: Error decoding booking: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+func (h *BookingHandler) CreateBookingHandler(w http.ResponseWriter, r *http.Request) {
+    var request dto.BookingRequest
+    if err := decodeRequest(r, &request); err != nil {
+        h.logger.Printf("CreateBookingHandler: Error decoding booking: %v", err)
+        apierror.Invalid(w, r, err)
+        return
+    }
+    booking := request.Model()
+
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanActAsUser(claims, booking.UserID) && !policy.CanActAsRestaurant(claims, booking.RestaurantID) {
+        h.logger.Printf("CreateBookingHandler: Forbidden booking for user %v at restaurant %v", booking.UserID, booking.RestaurantID)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
+        return
+    }
+
+    user, restaurant, ok := h.findReferences(w, r, "CreateBookingHandler", booking)
+    if !ok {
+        return
+    }
+
+    // Guests prove they own their phone number before booking; restaurants booking for a guest
+    // over the phone and administrators are not held to it
+    if claims.Type == auth.PrincipalUser && !user.PhoneVerified() {
+        h.logger.Printf("CreateBookingHandler: Phone number of user %v is not verified", booking.UserID)
+        apierror.Forbidden(w, r, "Verify your phone number before booking")
         return
     }
 
-    result, err := db.BookingCollection.InsertOne(context.Background(), booking)
+    if booking.ContactPhone != "" {
+        contactPhone, err := phone.Normalize(booking.ContactPhone)
+        if err != nil {
+            h.logger.Printf("CreateBookingHandler: Invalid contact phone: %v", err)
+            apierror.InvalidField(w, r, "contactPhone", "must be in international format, such as +31612345678")
+            return
+        }
+        booking.ContactPhone = contactPhone
+    }
+
+    if err := booking.Validate(); err != nil {
+        h.logger.Printf("CreateBookingHandler: Invalid booking: %v", err)
+        apierror.Invalid(w, r, err)
+        return
+    }
+
+    // Restaurants that have not configured opening hours yet accept any time
+    if restaurant.Hours != nil {
+        if err := scheduling.CheckBookable(*restaurant.Hours, booking.Date); err != nil {
+            h.logger.Printf("CreateBookingHandler: Booking outside opening hours: %v", err)
+            apierror.InvalidField(w, r, "date", "is not bookable: "+err.Error())
+            return
+        }
+    }
+
+    // Restaurants reach the guest on the account's phone number unless another contact was given
+    if booking.ContactPhone == "" {
+        booking.ContactPhone = user.PhoneNumber
+    }
+
+    booking.Status = models.BookingPending
+    booking.History = []models.StatusChange{{
+        To:        models.BookingPending,
+        At:        time.Now(),
+        ActorID:   claims.UserId,
+        ActorType: string(claims.Type),
+    }}
+
+    allocated, err := h.reserveTables(r.Context(), &booking, func() error {
+        return h.bookings.Create(r.Context(), &booking)
+    })
     if err != nil {
-        log.Printf("CreateBookingHandler: Error inserting booking: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        h.logger.Printf("CreateBookingHandler: Error inserting booking: %v", err)
+        apierror.Internal(w, r, err)
+        return
+    }
+    if !allocated {
+        h.logger.Printf("CreateBookingHandler: No table available for restaurant %v", booking.RestaurantID)
+        apierror.Conflict(w, r, "No table available for the requested party size and time")
         return
     }
 
-    log.Printf("CreateBookingHandler: Booking created, ID: %v", result.InsertedID)
-    json.NewEncoder(w).Encode(result)
+    h.logger.Printf("CreateBookingHandler: Booking created, ID: %v", booking.ID)
+    writeCreated(w, "/bookings/"+booking.ID.Hex(), dto.NewBookingResponse(booking))
 }
 
 // GetBookingHandler retrieves a booking by ID
-func GetBookingHandler(w http.ResponseWriter, r *http.Request) {
+func (h *BookingHandler) GetBookingHandler(w http.ResponseWriter, r *http.Request) {
     params := mux.Vars(r)
     bookingId, err := primitive.ObjectIDFromHex(params["id"])
     if err != nil {
-        log.Printf("GetBookingHandler: Error parsing ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("GetBookingHandler: Error parsing ID: %v", err)
+        apierror.InvalidID(w, r, "id")
         return
     }
 
-    var booking models.Booking
-    if err := db.BookingCollection.FindOne(context.Background(), bson.M{"_id": bookingId}).Decode(&booking); err != nil {
-        log.Printf("GetBookingHandler: Error finding booking: %v", err)
-        http.Error(w, err.Error(), http.StatusNotFound)
+    booking, err := h.bookings.FindByID(r.Context(), bookingId)
+    if err != nil {
+        h.logger.Printf("GetBookingHandler: Error finding booking: %v", err)
+        apierror.Lookup(w, r, err, "Booking not found")
         return
     }
 
-    log.Printf("GetBookingHandler: Booking retrieved, ID: %v", bookingId)
-    json.NewEncoder(w).Encode(booking)
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanAccessBooking(claims, booking) {
+        h.logger.Printf("GetBookingHandler: Forbidden access to booking: %v", bookingId)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
+        return
+    }
+
+    h.logger.Printf("GetBookingHandler: Booking retrieved, ID: %v", bookingId)
+    json.NewEncoder(w).Encode(dto.NewBookingResponse(booking))
 }
 
-// UpdateBookingHandler updates a booking's details
-func UpdateBookingHandler(w http.ResponseWriter, r *http.Request) {
+// UpdateBookingHandler replaces a booking's details. Like a partial update it cannot change the guest or restaurant,
+// which were checked when the booking was made, so the request must repeat them.
+func (h *BookingHandler) UpdateBookingHandler(w http.ResponseWriter, r *http.Request) {
     params := mux.Vars(r)
     bookingId, err := primitive.ObjectIDFromHex(params["id"])
     if err != nil {
-        log.Printf("UpdateBookingHandler: Error parsing ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("UpdateBookingHandler: Error parsing ID: %v", err)
+        apierror.InvalidID(w, r, "id")
+        return
+    }
+
+    existing, err := h.bookings.FindByID(r.Context(), bookingId)
+    if err != nil {
+        h.logger.Printf("UpdateBookingHandler: Error finding booking: %v", err)
+        apierror.Lookup(w, r, err, "Booking not found")
         return
     }
 
-    var booking models.Booking
-    if err := json.NewDecoder(r.Body).Decode(&booking); err != nil {
-        log.Printf("UpdateBookingHandler: Error decoding booking: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanAccessBooking(claims, existing) {
+        h.logger.Printf("UpdateBookingHandler: Forbidden access to booking: %v", bookingId)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
         return
     }
 
-    _, err = db.BookingCollection.UpdateOne(context.Background(), bson.M{"_id": bookingId}, bson.M{"$set": booking})
-    if err != nil {
-        log.Printf("UpdateBookingHandler: Error updating booking: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    var request dto.BookingRequest
+    if err := decodeRequest(r, &request); err != nil {
+        h.logger.Printf("UpdateBookingHandler: Error decoding booking: %v", err)
+        apierror.Invalid(w, r, err)
         return
     }
+    booking := request.Model()
 
-    log.Printf("UpdateBookingHandler: Booking updated, ID: %v", bookingId)
-    w.WriteHeader(http.StatusNoContent)
+    var changed models.FieldErrors
+    if booking.UserID != existing.UserID {
+        changed = append(changed, models.FieldError{Field: "userId", Message: "cannot be changed"})
+    }
+    if booking.RestaurantID != existing.RestaurantID {
+        changed = append(changed, models.FieldError{Field: "restaurantId", Message: "cannot be changed"})
+    }
+    if len(changed) > 0 {
+        h.logger.Printf("UpdateBookingHandler: Reassignment of booking %v refused: %v", bookingId, changed)
+        apierror.Invalid(w, r, changed)
+        return
+    }
+
+    h.saveBooking(w, r, "UpdateBookingHandler", existing, booking)
 }
 
-// DeleteBookingHandler deletes a booking by ID
-func DeleteBookingHandler(w http.ResponseWriter, r *http.Request) {
+// PatchBookingHandler changes only the booking details present in the request
+func (h *BookingHandler) PatchBookingHandler(w http.ResponseWriter, r *http.Request) {
     params := mux.Vars(r)
     bookingId, err := primitive.ObjectIDFromHex(params["id"])
     if err != nil {
-        log.Printf("DeleteBookingHandler: Error parsing ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("PatchBookingHandler: Error parsing ID: %v", err)
+        apierror.InvalidID(w, r, "id")
         return
     }
 
-    _, err = db.BookingCollection.DeleteOne(context.Background(), bson.M{"_id": bookingId})
+    existing, err := h.bookings.FindByID(r.Context(), bookingId)
     if err != nil {
-        log.Printf("DeleteBookingHandler: Error deleting booking: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        h.logger.Printf("PatchBookingHandler: Error finding booking: %v", err)
+        apierror.Lookup(w, r, err, "Booking not found")
         return
     }
 
-    log.Printf("DeleteBookingHandler: Booking deleted, ID: %v", bookingId)
-    w.WriteHeader(http.StatusNoContent)
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanAccessBooking(claims, existing) {
+        h.logger.Printf("PatchBookingHandler: Forbidden access to booking: %v", bookingId)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
+        return
+    }
+
+    var request dto.BookingPatch
+    if err := decodePatch(r, &request); err != nil {
+        h.logger.Printf("PatchBookingHandler: Invalid booking patch: %v", err)
+        apierror.Invalid(w, r, err)
+        return
+    }
+
+    booking := existing
+    request.Apply(&booking)
+    h.saveBooking(w, r, "PatchBookingHandler", existing, booking)
 }
 
-// CancelBookingHandler marks a booking as canceled (soft delete)
-func CancelBookingHandler(w http.ResponseWriter, r *http.Request) {
-    params := mux.Vars(r)
-    bookingId, err := primitive.ObjectIDFromHex(params["id"])
+// findReferences loads the guest and restaurant a new booking names, answering 400 for those that do not exist
+func (h *BookingHandler) findReferences(w http.ResponseWriter, r *http.Request, handlerName string, booking models.Booking) (models.User, models.Restaurant, bool) {
+    var missing models.FieldErrors
+    user, err := h.users.FindByID(r.Context(), booking.UserID)
+    if errors.Is(err, repository.ErrNotFound) {
+        missing = append(missing, models.FieldError{Field: "userId", Message: "does not exist"})
+    } else if err != nil {
+        h.logger.Printf("%s: Error finding user: %v", handlerName, err)
+        apierror.Internal(w, r, err)
+        return user, models.Restaurant{}, false
+    }
+
+    restaurant, err := h.restaurants.FindByID(r.Context(), booking.RestaurantID)
+    if errors.Is(err, repository.ErrNotFound) {
+        missing = append(missing, models.FieldError{Field: "restaurantId", Message: "does not exist"})
+    } else if err != nil {
+        h.logger.Printf("%s: Error finding restaurant: %v", handlerName, err)
+        apierror.Internal(w, r, err)
+        return user, restaurant, false
+    }
+
+    if len(missing) > 0 {
+        h.logger.Printf("%s: Unknown references: %v", handlerName, missing)
+        apierror.Invalid(w, r, missing)
+        return user, restaurant, false
+    }
+    return user, restaurant, true
+}
+
+// saveBooking checks the updated booking against the opening hours, finds it a table and writes it over the existing one.
+// The write only applies while the booking is still in the state it was read in.
+func (h *BookingHandler) saveBooking(w http.ResponseWriter, r *http.Request, handlerName string, existing, booking models.Booking) {
+    // The state only changes through the lifecycle endpoints, and only upcoming bookings can be changed
+    if existing.Status != models.BookingPending && existing.Status != models.BookingConfirmed {
+        h.logger.Printf("%s: Booking %v can no longer be changed in state %v", handlerName, existing.ID, existing.Status)
+        apierror.Conflict(w, r, "Only pending or confirmed bookings can be changed")
+        return
+    }
+    booking.Status = existing.Status
+    booking.History = existing.History
+
+    if booking.ContactPhone != "" {
+        contactPhone, err := phone.Normalize(booking.ContactPhone)
+        if err != nil {
+            h.logger.Printf("%s: Invalid contact phone: %v", handlerName, err)
+            apierror.InvalidField(w, r, "contactPhone", "must be in international format, such as +31612345678")
+            return
+        }
+        booking.ContactPhone = contactPhone
+    }
+
+    if err := booking.Validate(); err != nil {
+        h.logger.Printf("%s: Invalid booking: %v", handlerName, err)
+        apierror.Invalid(w, r, err)
+        return
+    }
+
+    restaurant, err := h.restaurants.FindByID(r.Context(), booking.RestaurantID)
     if err != nil {
-        log.Printf("CancelBookingHandler: Error parsing ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("%s: Error finding restaurant: %v", handlerName, err)
+        apierror.Lookup(w, r, err, "Restaurant not found")
         return
     }
 
-    update := bson.M{"$set": bson.M{"cancelled": true}}
-    _, err = db.BookingCollection.UpdateOne(context.Background(), bson.M{"_id": bookingId}, update)
+    // Restaurants that have not configured opening hours yet accept any time
+    if restaurant.Hours != nil {
+        if err := scheduling.CheckBookable(*restaurant.Hours, booking.Date); err != nil {
+            h.logger.Printf("%s: Booking outside opening hours: %v", handlerName, err)
+            apierror.InvalidField(w, r, "date", "is not bookable: "+err.Error())
+            return
+        }
+    }
+
+    booking.ID = existing.ID
+    allocated, err := h.reserveTables(r.Context(), &booking, func() error {
+        return h.bookings.Update(r.Context(), booking)
+    })
+    if errors.Is(err, repository.ErrConflict) {
+        h.logger.Printf("%s: Booking %v changed concurrently", handlerName, booking.ID)
+        apierror.Conflict(w, r, "Booking was changed by another request")
+        return
+    }
     if err != nil {
-        log.Printf("CancelBookingHandler: Error canceling booking: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        h.logger.Printf("%s: Error updating booking: %v", handlerName, err)
+        apierror.Internal(w, r, err)
+        return
+    }
+    if !allocated {
+        h.logger.Printf("%s: No table available for booking %v", handlerName, booking.ID)
+        apierror.Conflict(w, r, "No table available for the requested party size and time")
         return
     }
 
-    log.Printf("CancelBookingHandler: Booking canceled, ID: %v", bookingId)
+    h.logger.Printf("%s: Booking updated, ID: %v", handlerName, booking.ID)
     w.WriteHeader(http.StatusNoContent)
 }
 
-// GetBookingsForRestaurant retrieves all bookings for a specific restaurant
-func GetBookingsForRestaurant(w http.ResponseWriter, r *http.Request) {
+// DeleteBookingHandler deletes a booking by ID
+func (h *BookingHandler) DeleteBookingHandler(w http.ResponseWriter, r *http.Request) {
     params := mux.Vars(r)
-    restaurantId, err := primitive.ObjectIDFromHex(params["restaurantId"])
+    bookingId, err := primitive.ObjectIDFromHex(params["id"])
     if err != nil {
-        log.Printf("GetBookingsForRestaurant: Error parsing restaurant ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("DeleteBookingHandler: Error parsing ID: %v", err)
+        apierror.InvalidID(w, r, "id")
         return
     }
 
-    var bookings []models.Booking
-    cursor, err := db.BookingCollection.Find(context.Background(), bson.M{"restaurantId": restaurantId})
+    existing, err := h.bookings.FindByID(r.Context(), bookingId)
     if err != nil {
-        log.Printf("GetBookingsForRestaurant: Error finding bookings: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        h.logger.Printf("DeleteBookingHandler: Error finding booking: %v", err)
+        apierror.Lookup(w, r, err, "Booking not found")
         return
     }
-    defer cursor.Close(context.Background())
 
-    for cursor.Next(context.Background()) {
-        var booking models.Booking
-        if err := cursor.Decode(&booking); err != nil {
-            log.Printf("GetBookingsForRestaurant: Error decoding booking: %v", err)
-            continue
-        }
-        bookings = append(bookings, booking)
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanAccessBooking(claims, existing) {
+        h.logger.Printf("DeleteBookingHandler: Forbidden access to booking: %v", bookingId)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
+        return
     }
 
-    if err := cursor.Err(); err != nil {
-        log.Printf("GetBookingsForRestaurant: Cursor error: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if err := h.bookings.Delete(r.Context(), bookingId); err != nil {
+        h.logger.Printf("DeleteBookingHandler: Error deleting booking: %v", err)
+        apierror.Internal(w, r, err)
         return
     }
 
-    log.Printf("GetBookingsForRestaurant: Successfully retrieved bookings")
-    json.NewEncoder(w).Encode(bookings)
+    h.logger.Printf("DeleteBookingHandler: Booking deleted, ID: %v", bookingId)
+    w.WriteHeader(http.StatusNoContent)
 }
 
-// GetBookingsForUser retrieves all bookings made by a specific user
-func GetBookingsForUser(w http.ResponseWriter, r *http.Request) {
+// ConfirmBookingHandler lets the restaurant accept a pending booking
+func (h *BookingHandler) ConfirmBookingHandler(w http.ResponseWriter, r *http.Request) {
+    h.transitionBooking(w, r, "ConfirmBookingHandler", func(*auth.Claims, models.Booking) models.BookingStatus {
+        return models.BookingConfirmed
+    })
+}
+
+// SeatBookingHandler marks the guests of a confirmed booking as seated
+func (h *BookingHandler) SeatBookingHandler(w http.ResponseWriter, r *http.Request) {
+    h.transitionBooking(w, r, "SeatBookingHandler", func(*auth.Claims, models.Booking) models.BookingStatus {
+        return models.BookingSeated
+    })
+}
+
+// CompleteBookingHandler marks a seated booking as completed
+func (h *BookingHandler) CompleteBookingHandler(w http.ResponseWriter, r *http.Request) {
+    h.transitionBooking(w, r, "CompleteBookingHandler", func(*auth.Claims, models.Booking) models.BookingStatus {
+        return models.BookingCompleted
+    })
+}
+
+// NoShowBookingHandler marks a confirmed booking whose guests never arrived
+func (h *BookingHandler) NoShowBookingHandler(w http.ResponseWriter, r *http.Request) {
+    h.transitionBooking(w, r, "NoShowBookingHandler", func(*auth.Claims, models.Booking) models.BookingStatus {
+        return models.BookingNoShow
+    })
+}
+
+// CancelBookingHandler cancels a booking, on behalf of the guest when they made the call and of the restaurant otherwise
+func (h *BookingHandler) CancelBookingHandler(w http.ResponseWriter, r *http.Request) {
+    h.transitionBooking(w, r, "CancelBookingHandler", func(claims *auth.Claims, booking models.Booking) models.BookingStatus {
+        if claims.Type == auth.PrincipalUser && claims.UserId == booking.UserID.Hex() {
+            return models.BookingCancelledByGuest
+        }
+        return models.BookingCancelledByRestaurant
+    })
+}
+
+// transitionBooking moves a booking to the state chosen by next, recording who made the change.
+// The update only applies if the booking is still in the state it was read in.
+func (h *BookingHandler) transitionBooking(w http.ResponseWriter, r *http.Request, handlerName string, next func(*auth.Claims, models.Booking) models.BookingStatus) {
     params := mux.Vars(r)
-    userId, err := primitive.ObjectIDFromHex(params["userId"])
+    bookingId, err := primitive.ObjectIDFromHex(params["id"])
     if err != nil {
-        log.Printf("GetBookingsForUser: Error parsing user ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("%s: Error parsing ID: %v", handlerName, err)
+        apierror.InvalidID(w, r, "id")
         return
     }
 
-    var bookings []models.Booking
-    cursor, err := db.BookingCollection.Find(context.Background(), bson.M{"userId": userId})
+    booking, err := h.bookings.FindByID(r.Context(), bookingId)
     if err != nil {
-        log.Printf("GetBookingsForUser: Error finding bookings: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        h.logger.Printf("%s: Error finding booking: %v", handlerName, err)
+        apierror.Lookup(w, r, err, "Booking not found")
         return
     }
-    defer cursor.Close(context.Background())
 
-    for cursor.Next(context.Background()) {
-        var booking models.Booking
-        if err := cursor.Decode(&booking); err != nil {
-            log.Printf("GetBookingsForUser: Error decoding booking: %v", err)
-            continue
-        }
-        bookings = append(bookings, booking)
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanAccessBooking(claims, booking) {
+        h.logger.Printf("%s: Forbidden access to booking: %v", handlerName, bookingId)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
+        return
+    }
+
+    status := next(claims, booking)
+    if !policy.CanTransitionBooking(claims, booking, status) {
+        h.logger.Printf("%s: Forbidden transition of booking %v to %v", handlerName, bookingId, status)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
+        return
     }
 
-    if err := cursor.Err(); err != nil {
-        log.Printf("GetBookingsForUser: Cursor error: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if !booking.Status.CanTransitionTo(status) {
+        h.logger.Printf("%s: Invalid transition of booking %v from %v to %v", handlerName, bookingId, booking.Status, status)
+        apierror.Conflict(w, r, "Booking cannot move from "+string(booking.Status)+" to "+string(status))
+        return
+    }
+
+    change := models.StatusChange{
+        From:      booking.Status,
+        To:        status,
+        At:        time.Now(),
+        ActorID:   claims.UserId,
+        ActorType: string(claims.Type),
+    }
+    err = h.bookings.Transition(r.Context(), bookingId, booking.Status, change)
+    if errors.Is(err, repository.ErrConflict) {
+        h.logger.Printf("%s: Booking %v changed concurrently", handlerName, bookingId)
+        apierror.Conflict(w, r, "Booking was changed by another request")
+        return
+    }
+    if err != nil {
+        h.logger.Printf("%s: Error updating booking: %v", handlerName, err)
+        apierror.Internal(w, r, err)
         return
     }
 
-    log.Printf("GetBookingsForUser: Successfully retrieved bookings")
-    json.NewEncoder(w).Encode(bookings)
+    h.logger.Printf("%s: Booking %v moved from %v to %v", handlerName, bookingId, booking.Status, status)
+    w.WriteHeader(http.StatusNoContent)
 }
 
-// GetBookingsByDate retrieves bookings on a specific date
-func GetBookingsByDate(w http.ResponseWriter, r *http.Request) {
+// GetBookingsForRestaurant retrieves all bookings for a specific restaurant, with party details, ordered by date
+func (h *BookingHandler) GetBookingsForRestaurant(w http.ResponseWriter, r *http.Request) {
     params := mux.Vars(r)
-    date, err := time.Parse(time.RFC3339, params["date"])
+    restaurantId, err := primitive.ObjectIDFromHex(params["restaurantId"])
     if err != nil {
-        log.Printf("GetBookingsByDate: Error parsing date: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("GetBookingsForRestaurant: Error parsing restaurant ID: %v", err)
+        apierror.InvalidID(w, r, "restaurantId")
         return
     }
 
-    var bookings []models.Booking
-    cursor, err := db.BookingCollection.Find(context.Background(), bson.M{"date": bson.M{"$gte": date, "$lt": date.AddDate(0, 0, 1)}})
-    if err != nil {
-        log.Printf("GetBookingsByDate: Error finding bookings: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanActAsRestaurant(claims, restaurantId) {
+        h.logger.Printf("GetBookingsForRestaurant: Forbidden access to restaurant: %v", restaurantId)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
         return
     }
-    defer cursor.Close(context.Background())
 
-    for cursor.Next(context.Background()) {
-        var booking models.Booking
-        if err := cursor.Decode(&booking); err != nil {
-            log.Printf("GetBookingsByDate: Error decoding booking: %v", err)
-            continue
-        }
-        bookings = append(bookings, booking)
+    h.listBookings(w, r, "GetBookingsForRestaurant", repository.BookingFilter{RestaurantID: restaurantId})
+}
+
+// GetBookingsForUser retrieves all bookings made by a specific user
+func (h *BookingHandler) GetBookingsForUser(w http.ResponseWriter, r *http.Request) {
+    params := mux.Vars(r)
+    userId, err := primitive.ObjectIDFromHex(params["userId"])
+    if err != nil {
+        h.logger.Printf("GetBookingsForUser: Error parsing user ID: %v", err)
+        apierror.InvalidID(w, r, "userId")
+        return
     }
 
-    if err := cursor.Err(); err != nil {
-        log.Printf("GetBookingsByDate: Cursor error: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanActAsUser(claims, userId) {
+        h.logger.Printf("GetBookingsForUser: Forbidden access to user: %v", userId)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
         return
     }
 
-    log.Printf("GetBookingsByDate: Successfully retrieved bookings")
-    json.NewEncoder(w).Encode(bookings)
+    h.listBookings(w, r, "GetBookingsForUser", repository.BookingFilter{UserID: userId})
 }
 
-// GetActiveBookingsForRestaurant retrieves active (non-canceled) bookings for a specific restaurant
-func GetActiveBookingsForRestaurant(w http.ResponseWriter, r *http.Request) {
+// GetBookingsByDate retrieves bookings on a specific date
+func (h *BookingHandler) GetBookingsByDate(w http.ResponseWriter, r *http.Request) {
     params := mux.Vars(r)
-    restaurantId, err := primitive.ObjectIDFromHex(params["restaurantId"])
+    date, err := time.Parse(time.RFC3339, params["date"])
     if err != nil {
-        log.Printf("GetActiveBookingsForRestaurant: Error parsing restaurant ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("GetBookingsByDate: Error parsing date: %v", err)
+        apierror.InvalidField(w, r, "date", "must be an RFC 3339 timestamp")
         return
     }
 
-    var bookings []models.Booking
-    cursor, err := db.BookingCollection.Find(context.Background(), bson.M{
-        "restaurantId": restaurantId,
-        "cancelled":    bson.M{"$ne": true},
-    })
-    if err != nil {
-        log.Printf("GetActiveBookingsForRestaurant: Error finding bookings: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.IsAdmin(claims) {
+        h.logger.Printf("GetBookingsByDate: Forbidden access to bookings on %v", date)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
         return
     }
-    defer cursor.Close(context.Background())
 
-    for cursor.Next(context.Background()) {
-        var booking models.Booking
-        if err := cursor.Decode(&booking); err != nil {
-            log.Printf("GetActiveBookingsForRestaurant: Error decoding booking: %v", err)
-            continue
-        }
-        bookings = append(bookings, booking)
+    h.listBookings(w, r, "GetBookingsByDate", repository.BookingFilter{DateFrom: date, DateTo: date.AddDate(0, 0, 1)})
+}
+
+// GetActiveBookingsForRestaurant retrieves pending, confirmed and seated bookings for a specific restaurant
+func (h *BookingHandler) GetActiveBookingsForRestaurant(w http.ResponseWriter, r *http.Request) {
+    params := mux.Vars(r)
+    restaurantId, err := primitive.ObjectIDFromHex(params["restaurantId"])
+    if err != nil {
+        h.logger.Printf("GetActiveBookingsForRestaurant: Error parsing restaurant ID: %v", err)
+        apierror.InvalidID(w, r, "restaurantId")
+        return
     }
 
-    if err := cursor.Err(); err != nil {
-        log.Printf("GetActiveBookingsForRestaurant: Cursor error: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanActAsRestaurant(claims, restaurantId) {
+        h.logger.Printf("GetActiveBookingsForRestaurant: Forbidden access to restaurant: %v", restaurantId)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
         return
     }
 
-    log.Printf("GetActiveBookingsForRestaurant: Successfully retrieved active bookings")
-    json.NewEncoder(w).Encode(bookings)
+    h.listBookings(w, r, "GetActiveBookingsForRestaurant", repository.BookingFilter{
+        RestaurantID: restaurantId,
+        Statuses:     models.ActiveBookingStatuses,
+    })
 }
 
 // GetFutureBookingsForUser retrieves future bookings for a specific user
-func GetFutureBookingsForUser(w http.ResponseWriter, r *http.Request) {
+func (h *BookingHandler) GetFutureBookingsForUser(w http.ResponseWriter, r *http.Request) {
     params := mux.Vars(r)
     userId, err := primitive.ObjectIDFromHex(params["userId"])
     if err != nil {
-        log.Printf("GetFutureBookingsForUser: Error parsing user ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("GetFutureBookingsForUser: Error parsing user ID: %v", err)
+        apierror.InvalidID(w, r, "userId")
         return
     }
 
-    var bookings []models.Booking
-    cursor, err := db.BookingCollection.Find(context.Background(), bson.M{
-        "userId": userId,
-        "date":   bson.M{"$gte": time.Now()},
-    })
-    if err != nil {
-        log.Printf("GetFutureBookingsForUser: Error finding bookings: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanActAsUser(claims, userId) {
+        h.logger.Printf("GetFutureBookingsForUser: Forbidden access to user: %v", userId)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
         return
     }
-    defer cursor.Close(context.Background())
 
-    for cursor.Next(context.Background()) {
-        var booking models.Booking
-        if err := cursor.Decode(&booking); err != nil {
-            log.Printf("GetFutureBookingsForUser: Error decoding booking: %v", err)
-            continue
-        }
-        bookings = append(bookings, booking)
+    h.listBookings(w, r, "GetFutureBookingsForUser", repository.BookingFilter{
+        UserID:   userId,
+        DateFrom: time.Now(),
+    })
+}
+
+// GetPastBookingsForRestaurant retrieves completed, no-show and cancelled bookings for a specific restaurant
+func (h *BookingHandler) GetPastBookingsForRestaurant(w http.ResponseWriter, r *http.Request) {
+    params := mux.Vars(r)
+    restaurantId, err := primitive.ObjectIDFromHex(params["restaurantId"])
+    if err != nil {
+        h.logger.Printf("GetPastBookingsForRestaurant: Error parsing restaurant ID: %v", err)
+        apierror.InvalidID(w, r, "restaurantId")
+        return
     }
 
-    if err := cursor.Err(); err != nil {
-        log.Printf("GetFutureBookingsForUser: Cursor error: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanActAsRestaurant(claims, restaurantId) {
+        h.logger.Printf("GetPastBookingsForRestaurant: Forbidden access to restaurant: %v", restaurantId)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
         return
     }
 
-    log.Printf("GetFutureBookingsForUser: Successfully retrieved future bookings")
-    json.NewEncoder(w).Encode(bookings)
+    h.listBookings(w, r, "GetPastBookingsForRestaurant", repository.BookingFilter{
+        RestaurantID: restaurantId,
+        Statuses:     models.PastBookingStatuses,
+    })
 }
 
-// GetPastBookingsForRestaurant retrieves past bookings for a specific restaurant
-func GetPastBookingsForRestaurant(w http.ResponseWriter, r *http.Request) {
-    params := mux.Vars(r)
-    restaurantId, err := primitive.ObjectIDFromHex(params["restaurantId"])
-    if err != nil {
-        log.Printf("GetPastBookingsForRestaurant: Error parsing restaurant ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+// bookingSorts are the orders of the booking lists, soonest first unless ordered otherwise
+var bookingSorts = map[string]bool{repository.SortByDate: false}
+
+// listBookings answers with a page of the bookings matching base, narrowed down by the from, to and status parameters.
+// The statuses asked for must be among those of base, when it has any.
+func (h *BookingHandler) listBookings(w http.ResponseWriter, r *http.Request, handlerName string, base repository.BookingFilter) {
+    var errs models.FieldErrors
+    pager := newPager(r, bookingSorts, repository.Sort{Field: repository.SortByDate}, &errs)
+    filter := base
+    if from := queryTime(r, "from", &errs); from.After(filter.DateFrom) {
+        filter.DateFrom = from
+    }
+    if to := queryTime(r, "to", &errs); !to.IsZero() && (filter.DateTo.IsZero() || to.Before(filter.DateTo)) {
+        filter.DateTo = to
+    }
+    if statuses := r.URL.Query().Get("status"); statuses != "" {
+        allowed := base.Statuses
+        if len(allowed) == 0 {
+            allowed = append(append([]models.BookingStatus{}, models.ActiveBookingStatuses...), models.PastBookingStatuses...)
+        }
+        filter.Statuses = nil
+        for _, status := range strings.Split(statuses, ",") {
+            if !hasStatus(allowed, models.BookingStatus(status)) {
+                names := make([]string, len(allowed))
+                for i, status := range allowed {
+                    names[i] = string(status)
+                }
+                errs = append(errs, models.FieldError{Field: "status", Message: "must be a comma separated list of " + strings.Join(names, ", ")})
+                break
+            }
+            filter.Statuses = append(filter.Statuses, models.BookingStatus(status))
+        }
+    }
+    filter.Page = pager.page()
+    if len(errs) > 0 {
+        h.logger.Printf("%s: Invalid parameters: %v", handlerName, errs)
+        apierror.Invalid(w, r, errs)
         return
     }
 
-    var bookings []models.Booking
-    cursor, err := db.BookingCollection.Find(context.Background(), bson.M{
-        "restaurantId": restaurantId,
-        "date":         bson.M{"$lt": time.Now()},
-    })
+    bookings, err := h.bookings.List(r.Context(), filter)
     if err != nil {
-        log.Printf("GetPastBookingsForRestaurant: Error finding bookings: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        h.logger.Printf("%s: Error finding bookings: %v", handlerName, err)
+        apierror.Internal(w, r, err)
         return
     }
-    defer cursor.Close(context.Background())
 
-    for cursor.Next(context.Background()) {
-        var booking models.Booking
-        if err := cursor.Decode(&booking); err != nil {
-            log.Printf("GetPastBookingsForRestaurant: Error decoding booking: %v", err)
-            continue
+    var next string
+    if pager.more(len(bookings)) {
+        bookings = bookings[:len(bookings)-1]
+        next = pager.next(repository.BookingCursor(bookings[len(bookings)-1], pager.sort))
+    }
+
+    h.logger.Printf("%s: Successfully retrieved bookings", handlerName)
+    json.NewEncoder(w).Encode(dto.BookingPage{Items: dto.NewBookingResponses(bookings), Next: next})
+}
+
+func hasStatus(statuses []models.BookingStatus, status models.BookingStatus) bool {
+    for _, s := range statuses {
+        if s == status {
+            return true
         }
-        bookings = append(bookings, booking)
     }
+    return false
+}
 
-    if err := cursor.Err(); err != nil {
-        log.Printf("GetPastBookingsForRestaurant: Cursor error: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
-        return
+// reserveAttempts is how often a booking is given other tables when a concurrent booking took the ones it was given first
+const reserveAttempts = 3
+
+// maxOverlappingBookings bounds the bookings read to tell which tables are busy. Were there more, a table held by one
+// left out could be allocated again, and the repository would refuse the write with ErrTaken.
+const maxOverlappingBookings = 500
+
+// reserveTables allocates tables to the booking and writes it with save. The repository refuses the write when another
+// booking took one of the tables since they were allocated, the booking is then allocated again with the tables that are left.
+// It returns false when no table or combination of tables fits the party.
+func (h *BookingHandler) reserveTables(ctx context.Context, booking *models.Booking, save func() error) (bool, error) {
+    for attempt := 1; ; attempt++ {
+        allocated, err := h.allocateTables(ctx, booking)
+        if err != nil || !allocated {
+            return allocated, err
+        }
+
+        err = save()
+        if !errors.Is(err, repository.ErrTaken) {
+            return err == nil, err
+        }
+        if attempt == reserveAttempts {
+            return false, nil
+        }
     }
+}
 
-    log.Printf("GetPastBookingsForRestaurant: Successfully retrieved past bookings")
-    json.NewEncoder(w).Encode(bookings)
-}
\ No newline at end of file
+// allocateTables assigns free tables of the booked restaurant to the booking.
+// It returns false when no table or combination of tables fits the party in the requested time window.
+func (h *BookingHandler) allocateTables(ctx context.Context, booking *models.Booking) (bool, error) {
+    if booking.PartySize <= 0 {
+        return false, nil
+    }
+    if booking.EndDate.IsZero() {
+        booking.EndDate = booking.Date.Add(models.DefaultBookingDuration)
+    }
+
+    tables, err := h.restaurants.ListTables(ctx, booking.RestaurantID)
+    if err != nil {
+        return false, err
+    }
+
+    // Tables are held by whole reservation slots, so the bookings sharing a slot with this one are as busy as those overlapping it
+    from, until := booking.ReservedSpan()
+    overlapping, err := h.bookings.List(ctx, repository.BookingFilter{
+        RestaurantID: booking.RestaurantID,
+        ExcludeID:    booking.ID,
+        Statuses:     models.ActiveBookingStatuses,
+        DateTo:       until,
+        EndsAfter:    from,
+        Page:         repository.Page{Limit: maxOverlappingBookings},
+    })
+    if err != nil {
+        return false, err
+    }
+
+    tableIds, ok := scheduling.AllocateTables(tables, scheduling.BusyTables(overlapping), booking.PartySize)
+    if !ok {
+        return false, nil
+    }
+    booking.TableIDs = tableIds
+    return true, nil
+}
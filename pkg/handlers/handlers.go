@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"book-and-rate/pkg/apierror"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/phone"
+	"book-and-rate/pkg/validate"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// errPhoneTaken is reported when another account of the same kind already registered the phone number
+var errPhoneTaken = errors.New("an account with this phone number already exists")
+
+// invalidPhone answers 400 for a phone number field that phone.Normalize rejected
+func invalidPhone(w http.ResponseWriter, r *http.Request, field string, err error) {
+	if errors.Is(err, phone.ErrRequired) {
+		apierror.InvalidField(w, r, field, "is required")
+		return
+	}
+	apierror.InvalidField(w, r, field, "must be in international format, such as +31612345678")
+}
+
+// writeCreated answers 201 with the created resource and where it can be found
+func writeCreated(w http.ResponseWriter, location string, resource interface{}) {
+	w.Header().Set("Location", location)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resource)
+}
+
+// validator is a request with rules its validate tags cannot express, such as limits owned by a model
+type validator interface {
+	Validate() error
+}
+
+// decodeRequest decodes the JSON body into v, a pointer to a request, and checks it against its validate tags,
+// then its own Validate when it has one. Fields the request does not have and anything after the JSON value are rejected.
+func decodeRequest(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	// More misses a stray closing bracket, so the rest of the body has to be decoded and found empty
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return apierror.ErrTrailingData
+	}
+	if err := validate.Struct(v); err != nil {
+		return err
+	}
+	if request, ok := v.(validator); ok {
+		return request.Validate()
+	}
+	return nil
+}
+
+// patch is the body of a partial update, a nil field was left out of the request
+type patch interface {
+	// Immutable lists the fields of the resource that a partial update may not name
+	Immutable() []string
+	// Validate checks the fields that were supplied
+	Validate() error
+}
+
+// decodePatch decodes a partial update, rejecting fields that cannot be changed or do not exist
+func decodePatch(r *http.Request, p patch) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return err
+	}
+	for name := range fields {
+		for _, immutable := range p.Immutable() {
+			if strings.EqualFold(name, immutable) {
+				return models.FieldError{Field: immutable, Message: "cannot be changed"}
+			}
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(p); err != nil {
+		return err
+	}
+	if err := validate.Struct(p); err != nil {
+		return err
+	}
+	return p.Validate()
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"book-and-rate/pkg/version"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds each readiness check, so a hung dependency fails the probe instead of stalling it
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck is a named condition the service needs before it can take traffic
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthHandler serves the probes of the orchestrator and the build information
+type HealthHandler struct {
+	checks []ReadinessCheck
+	logger *log.Logger
+}
+
+func NewHealthHandler(checks []ReadinessCheck, logger *log.Logger) *HealthHandler {
+	return &HealthHandler{checks: checks, logger: logger}
+}
+
+type healthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// LivenessHandler answers as long as the process is able to serve requests
+func (h *HealthHandler) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(healthStatus{Status: "ok"})
+}
+
+// ReadinessHandler runs every readiness check and answers 503 unless all of them pass.
+// The probe is unauthenticated, so a failed check is only reported as unavailable and its error is logged.
+func (h *HealthHandler) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus{Status: "ready", Checks: make(map[string]string, len(h.checks))}
+	for _, check := range h.checks {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		err := check.Check(ctx)
+		cancel()
+
+		if err != nil {
+			h.logger.Printf("ReadinessHandler: Check %s failed: %v", check.Name, err)
+			status.Status = "unavailable"
+			status.Checks[check.Name] = "unavailable"
+			continue
+		}
+		status.Checks[check.Name] = "ok"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if status.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// VersionHandler returns the commit, build time and Go version of the running binary
+func (h *HealthHandler) VersionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version.Get())
+}
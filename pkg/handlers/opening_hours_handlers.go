@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"book-and-rate/pkg/apierror"
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/repository"
+	"book-and-rate/pkg/scheduling"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetOpeningHoursHandler retrieves a restaurant's opening hours
+func (h *RestaurantHandler) GetOpeningHoursHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	restaurantId, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		h.logger.Printf("GetOpeningHoursHandler: Error parsing ID: %v", err)
+		apierror.InvalidID(w, r, "id")
+		return
+	}
+
+	restaurant, err := h.restaurants.FindByID(r.Context(), restaurantId)
+	if err != nil {
+		h.logger.Printf("GetOpeningHoursHandler: Error finding restaurant: %v", err)
+		apierror.Lookup(w, r, err, "Restaurant not found")
+		return
+	}
+
+	if restaurant.Hours == nil {
+		h.logger.Printf("GetOpeningHoursHandler: No opening hours for restaurant: %v", restaurantId)
+		apierror.NotFound(w, r, "Opening hours are not configured")
+		return
+	}
+
+	h.logger.Printf("GetOpeningHoursHandler: Opening hours retrieved: %v", restaurantId)
+	json.NewEncoder(w).Encode(dto.NewOpeningHours(*restaurant.Hours))
+}
+
+// UpdateOpeningHoursHandler replaces a restaurant's opening hours
+func (h *RestaurantHandler) UpdateOpeningHoursHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	restaurantId, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		h.logger.Printf("UpdateOpeningHoursHandler: Error parsing ID: %v", err)
+		apierror.InvalidID(w, r, "id")
+		return
+	}
+
+	var request dto.OpeningHours
+	if err := decodeRequest(r, &request); err != nil {
+		h.logger.Printf("UpdateOpeningHoursHandler: Error decoding opening hours: %v", err)
+		apierror.Invalid(w, r, err)
+		return
+	}
+	hours := request.Model()
+
+	if err := scheduling.ValidateHours(hours); err != nil {
+		h.logger.Printf("UpdateOpeningHoursHandler: Invalid opening hours: %v", err)
+		apierror.Invalid(w, r, err)
+		return
+	}
+
+	err = h.restaurants.UpdateHours(r.Context(), restaurantId, hours)
+	if errors.Is(err, repository.ErrNotFound) {
+		h.logger.Printf("UpdateOpeningHoursHandler: Restaurant not found: %v", restaurantId)
+		apierror.NotFound(w, r, "Restaurant not found")
+		return
+	}
+	if err != nil {
+		h.logger.Printf("UpdateOpeningHoursHandler: Error updating opening hours: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	h.logger.Printf("UpdateOpeningHoursHandler: Opening hours updated: %v", restaurantId)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/repository"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+// pager reads the paging parameters of a list endpoint and builds the link to the next page.
+// Lists are paged with limit and after, the opaque cursor of the next link, and ordered with sort and order.
+type pager struct {
+	request *http.Request
+	limit   int
+	sort    repository.Sort
+	after   *repository.Cursor
+}
+
+// newPager reads the paging parameters, adding the invalid ones to errs. sorts maps the fields the list can be sorted on
+// to whether they sort in descending order unless order says otherwise, def is the order when no sort is given.
+func newPager(r *http.Request, sorts map[string]bool, def repository.Sort, errs *models.FieldErrors) *pager {
+	query := r.URL.Query()
+	p := &pager{request: r, limit: defaultPageLimit, sort: def}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			*errs = append(*errs, models.FieldError{Field: "limit", Message: fmt.Sprintf("must be a number between 1 and %d", maxPageLimit)})
+		} else {
+			p.limit = limit
+		}
+	}
+
+	if field := query.Get("sort"); field != "" {
+		if desc, ok := sorts[field]; ok {
+			p.sort = repository.Sort{Field: field, Desc: desc}
+		} else {
+			fields := make([]string, 0, len(sorts))
+			for field := range sorts {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			*errs = append(*errs, models.FieldError{Field: "sort", Message: "must be one of " + strings.Join(fields, ", ")})
+		}
+	}
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		p.sort.Desc = false
+	case "desc":
+		p.sort.Desc = true
+	default:
+		*errs = append(*errs, models.FieldError{Field: "order", Message: "must be asc or desc"})
+	}
+
+	if token := query.Get("after"); token != "" {
+		cursor, err := decodeCursor(token, p.sort)
+		if err != nil {
+			*errs = append(*errs, models.FieldError{Field: "after", Message: "is not a cursor of this list, follow the next link of the previous page"})
+		} else {
+			p.after = &cursor
+		}
+	}
+	return p
+}
+
+// page is the page to ask the repository for, one item longer than the limit to tell whether another page follows
+func (p *pager) page() repository.Page {
+	return repository.Page{Sort: p.sort, After: p.after, Limit: p.limit + 1}
+}
+
+// more reports whether another page follows the n items the repository returned, the last of which is then left off
+func (p *pager) more(n int) bool {
+	return n > p.limit
+}
+
+// next is the link to the page following the item at last, keeping the other parameters of the request
+func (p *pager) next(last repository.Cursor) string {
+	query := p.request.URL.Query()
+	query.Set("after", encodeCursor(last, p.sort))
+	return p.request.URL.Path + "?" + query.Encode()
+}
+
+// cursorToken is the content of the after parameter, the sort is kept so a cursor is never used with another order
+type cursorToken struct {
+	Sort   string             `json:"s,omitempty"`
+	Desc   bool               `json:"d,omitempty"`
+	Time   *time.Time         `json:"t,omitempty"`
+	Text   *string            `json:"x,omitempty"`
+	Number *float64           `json:"n,omitempty"`
+	ID     primitive.ObjectID `json:"id"`
+}
+
+func encodeCursor(cursor repository.Cursor, sort repository.Sort) string {
+	token := cursorToken{Sort: sort.Field, Desc: sort.Desc, ID: cursor.ID}
+	switch value := cursor.Value.(type) {
+	case time.Time:
+		token.Time = &value
+	case string:
+		token.Text = &value
+	case float64:
+		token.Number = &value
+	}
+	encoded, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeCursor(encoded string, sort repository.Sort) (repository.Cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return repository.Cursor{}, err
+	}
+	var token cursorToken
+	if err := json.Unmarshal(decoded, &token); err != nil {
+		return repository.Cursor{}, err
+	}
+	if token.Sort != sort.Field || token.Desc != sort.Desc {
+		return repository.Cursor{}, errors.New("the cursor belongs to another order")
+	}
+
+	cursor := repository.Cursor{ID: token.ID}
+	switch {
+	case token.Time != nil:
+		cursor.Value = *token.Time
+	case token.Text != nil:
+		cursor.Value = *token.Text
+	case token.Number != nil:
+		cursor.Value = *token.Number
+	}
+	return cursor, nil
+}
+
+// queryTime reads an RFC 3339 timestamp parameter, the zero time when it is absent
+func queryTime(r *http.Request, name string, errs *models.FieldErrors) time.Time {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		*errs = append(*errs, models.FieldError{Field: name, Message: "must be an RFC 3339 timestamp"})
+	}
+	return parsed
+}
+
+// queryNumber reads a number parameter between min and max, zero when it is absent
+func queryNumber(r *http.Request, name string, min, max float64, errs *models.FieldErrors) float64 {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed < min || parsed > max {
+		*errs = append(*errs, models.FieldError{Field: name, Message: fmt.Sprintf("must be a number between %g and %g", min, max)})
+		return 0
+	}
+	return parsed
+}
+
+// queryInt reads a whole number parameter between min and max, zero when it is absent
+func queryInt(r *http.Request, name string, min, max int, errs *models.FieldErrors) int {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < min || parsed > max {
+		*errs = append(*errs, models.FieldError{Field: name, Message: fmt.Sprintf("must be a whole number between %d and %d", min, max)})
+		return 0
+	}
+	return parsed
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"book-and-rate/pkg/apierror"
+	"book-and-rate/pkg/auth"
+	"book-and-rate/pkg/config"
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/notify"
+	"book-and-rate/pkg/phone"
+	"book-and-rate/pkg/repository"
+	"book-and-rate/pkg/utils"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// resetTokenBytes is the randomness of a password reset token
+const resetTokenBytes = 32
+
+// PasswordResetHandler serves the forgotten password endpoints of users and restaurants
+type PasswordResetHandler struct {
+	resets      repository.PasswordResetRepository
+	users       repository.UserRepository
+	restaurants repository.RestaurantRepository
+	tokens      repository.TokenRepository
+	hasher      *utils.PasswordHasher
+	notifier    notify.Notifier
+	config      config.PasswordResetConfig
+	logger      *log.Logger
+}
+
+func NewPasswordResetHandler(resets repository.PasswordResetRepository, users repository.UserRepository, restaurants repository.RestaurantRepository, tokens repository.TokenRepository, hasher *utils.PasswordHasher, notifier notify.Notifier, cfg config.PasswordResetConfig, logger *log.Logger) *PasswordResetHandler {
+	return &PasswordResetHandler{resets: resets, users: users, restaurants: restaurants, tokens: tokens, hasher: hasher, notifier: notifier, config: cfg, logger: logger}
+}
+
+// resetAccount is how the reset endpoints reach one kind of account
+type resetAccount struct {
+	principalType auth.PrincipalType
+	findByPhone   func(ctx context.Context, phone string) (primitive.ObjectID, error)
+	setPassword   func(ctx context.Context, id primitive.ObjectID, hashedPassword string) error
+}
+
+func (h *PasswordResetHandler) userAccount() resetAccount {
+	return resetAccount{
+		principalType: auth.PrincipalUser,
+		findByPhone: func(ctx context.Context, phone string) (primitive.ObjectID, error) {
+			user, err := h.users.FindByPhone(ctx, phone)
+			return user.ID, err
+		},
+		setPassword: h.users.SetPassword,
+	}
+}
+
+func (h *PasswordResetHandler) restaurantAccount() resetAccount {
+	return resetAccount{
+		principalType: auth.PrincipalRestaurant,
+		findByPhone: func(ctx context.Context, phone string) (primitive.ObjectID, error) {
+			restaurant, err := h.restaurants.FindByPhone(ctx, phone)
+			return restaurant.ID, err
+		},
+		setPassword: h.restaurants.SetPassword,
+	}
+}
+
+// ForgotUserPasswordHandler texts a password reset token to the user registered with the phone number
+func (h *PasswordResetHandler) ForgotUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	h.forgot(w, r, "ForgotUserPasswordHandler", h.userAccount())
+}
+
+// ResetUserPasswordHandler sets a new user password with a reset token
+func (h *PasswordResetHandler) ResetUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	h.reset(w, r, "ResetUserPasswordHandler", h.userAccount())
+}
+
+// ForgotRestaurantPasswordHandler texts a password reset token to the restaurant registered with the phone number
+func (h *PasswordResetHandler) ForgotRestaurantPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	h.forgot(w, r, "ForgotRestaurantPasswordHandler", h.restaurantAccount())
+}
+
+// ResetRestaurantPasswordHandler sets a new restaurant password with a reset token
+func (h *PasswordResetHandler) ResetRestaurantPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	h.reset(w, r, "ResetRestaurantPasswordHandler", h.restaurantAccount())
+}
+
+// forgot answers 202 whether or not an account has the phone number, so the endpoint cannot be used to find out
+func (h *PasswordResetHandler) forgot(w http.ResponseWriter, r *http.Request, handlerName string, account resetAccount) {
+	var request dto.ForgotPasswordRequest
+	if err := decodeRequest(r, &request); err != nil {
+		h.logger.Printf("%s: Error decoding request: %v", handlerName, err)
+		apierror.Invalid(w, r, err)
+		return
+	}
+
+	phoneNumber, err := phone.Normalize(request.Phone)
+	if err != nil {
+		h.logger.Printf("%s: Invalid phone number: %v", handlerName, err)
+		invalidPhone(w, r, "phone", err)
+		return
+	}
+
+	accountId, err := account.findByPhone(r.Context(), phoneNumber)
+	if errors.Is(err, repository.ErrNotFound) {
+		h.logger.Printf("%s: No account with the phone number", handlerName)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		h.logger.Printf("%s: Error finding account: %v", handlerName, err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	principalType := string(account.principalType)
+	latest, err := h.resets.Latest(r.Context(), principalType, accountId)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		h.logger.Printf("%s: Error finding previous reset: %v", handlerName, err)
+		apierror.Internal(w, r, err)
+		return
+	}
+	if err == nil && now.Before(latest.CreatedAt.Add(time.Duration(h.config.ResendCooldown))) {
+		h.logger.Printf("%s: Reset requested again too soon for %v", handlerName, accountId)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	token, err := utils.GenerateToken(resetTokenBytes)
+	if err != nil {
+		h.logger.Printf("%s: Error generating token: %v", handlerName, err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	reset := models.PasswordReset{
+		TokenHash:     utils.HashToken(token),
+		AccountID:     accountId,
+		PrincipalType: principalType,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(time.Duration(h.config.TokenLifetime)),
+	}
+	if err := h.resets.Create(r.Context(), reset); err != nil {
+		h.logger.Printf("%s: Error saving reset: %v", handlerName, err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	if err := h.notifier.PasswordReset(r.Context(), phoneNumber, token, time.Duration(h.config.TokenLifetime)); err != nil {
+		// Answering differently would tell the caller the account exists, so the failure is only logged
+		h.logger.Printf("%s: Error sending reset token to %v: %v", handlerName, accountId, err)
+		// The token never arrived, so it should not hold the account back until the cooldown passes
+		if err := h.resets.DeleteAccount(r.Context(), principalType, accountId); err != nil {
+			h.logger.Printf("%s: Error deleting unsent reset: %v", handlerName, err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	h.logger.Printf("%s: Reset token sent to %v", handlerName, accountId)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// reset sets the new password, then logs the account out everywhere and voids its other reset tokens
+func (h *PasswordResetHandler) reset(w http.ResponseWriter, r *http.Request, handlerName string, account resetAccount) {
+	var request dto.ResetPasswordRequest
+	if err := decodeRequest(r, &request); err != nil {
+		h.logger.Printf("%s: Error decoding request: %v", handlerName, err)
+		apierror.Invalid(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	principalType := string(account.principalType)
+	reset, err := h.resets.Consume(r.Context(), principalType, utils.HashToken(request.Token), now)
+	if errors.Is(err, repository.ErrNotFound) {
+		h.logger.Printf("%s: Invalid, used or expired reset token", handlerName)
+		apierror.InvalidField(w, r, "token", "is invalid or expired")
+		return
+	}
+	if err != nil {
+		h.logger.Printf("%s: Error consuming reset token: %v", handlerName, err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	hashedPassword, err := h.hasher.Hash(request.Password)
+	if err != nil {
+		h.logger.Printf("%s: Error hashing password: %v", handlerName, err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	if err := account.setPassword(r.Context(), reset.AccountID, hashedPassword); err != nil {
+		h.logger.Printf("%s: Error setting password: %v", handlerName, err)
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.InvalidField(w, r, "token", "is invalid or expired")
+			return
+		}
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	// Whoever knew the old password may still hold tokens issued with it
+	if err := h.tokens.RevokeUser(r.Context(), reset.AccountID.Hex(), now); err != nil {
+		h.logger.Printf("%s: Error revoking refresh tokens: %v", handlerName, err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Password changed, but existing sessions could not be logged out")
+		return
+	}
+
+	if err := h.resets.DeleteAccount(r.Context(), principalType, reset.AccountID); err != nil {
+		h.logger.Printf("%s: Error deleting other reset tokens: %v", handlerName, err)
+	}
+
+	h.logger.Printf("%s: Password reset for %v", handlerName, reset.AccountID)
+	w.WriteHeader(http.StatusNoContent)
+}
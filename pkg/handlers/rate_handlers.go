@@ -1,220 +1,399 @@
 package handlers
 
 import (
-	"book-and-rate/pkg/db"
+	"book-and-rate/pkg/apierror"
+	"book-and-rate/pkg/auth"
+	"book-and-rate/pkg/dto"
 	"book-and-rate/pkg/models"
-	"context"
+	"book-and-rate/pkg/policy"
+	"book-and-rate/pkg/repository"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// RateHandler serves the rates and keeps the rating summaries of the restaurants up to date
+type RateHandler struct {
+    rates       repository.RateRepository
+    bookings    repository.BookingRepository
+    restaurants repository.RestaurantRepository
+    logger      *log.Logger
+}
+
+func NewRateHandler(rates repository.RateRepository, bookings repository.BookingRepository, restaurants repository.RestaurantRepository, logger *log.Logger) *RateHandler {
+    return &RateHandler{rates: rates, bookings: bookings, restaurants: restaurants, logger: logger}
+}
+
 // CreateRateHandler handles the creation of a new rate
-func CreateRateHandler(w http.ResponseWriter, r *http.Request) {
-    var rate models.Rate
-    if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
-        log.Printf("CreateRateHandler: Error decoding rate: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+func (h *RateHandler) CreateRateHandler(w http.ResponseWriter, r *http.Request) {
+    var request dto.RateRequest
+    if err := decodeRequest(r, &request); err != nil {
+        h.logger.Printf("CreateRateHandler: Error decoding rate: %v", err)
+        apierror.Invalid(w, r, err)
+        return
+    }
+    rate := request.Model()
+
+    if err := rate.Validate(); err != nil {
+        h.logger.Printf("CreateRateHandler: Invalid rate: %v", err)
+        apierror.Invalid(w, r, err)
+        return
+    }
+
+    // Only guests rate, and always as themselves
+    claims, _ := auth.FromContext(r.Context())
+    if claims == nil || (claims.Type != auth.PrincipalUser && claims.Type != auth.PrincipalAdmin) {
+        h.logger.Printf("CreateRateHandler: Only users can rate restaurants")
+        apierror.Forbidden(w, r, "You do not have access to this resource")
+        return
+    }
+    userId, err := primitive.ObjectIDFromHex(claims.UserId)
+    if err != nil {
+        h.logger.Printf("CreateRateHandler: Error parsing user ID: %v", err)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
+        return
+    }
+    rate.UserID = userId
+
+    booking, err := h.bookings.FindByID(r.Context(), rate.BookingID)
+    if errors.Is(err, repository.ErrNotFound) {
+        h.logger.Printf("CreateRateHandler: Unknown booking: %v", rate.BookingID)
+        apierror.InvalidField(w, r, "bookingId", "does not exist")
+        return
+    }
+    if err != nil {
+        h.logger.Printf("CreateRateHandler: Error finding booking: %v", err)
+        apierror.Internal(w, r, err)
+        return
+    }
+
+    if booking.UserID != rate.UserID {
+        h.logger.Printf("CreateRateHandler: Booking %v does not belong to user %v", booking.ID, rate.UserID)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
+        return
+    }
+
+    if booking.Status != models.BookingCompleted {
+        h.logger.Printf("CreateRateHandler: Booking %v is %v, not completed", booking.ID, booking.Status)
+        apierror.Conflict(w, r, "Only completed bookings can be rated")
         return
     }
 
+    // The repository refuses a second rate of the booking, even when two arrive at once
+    rate.RestaurantID = booking.RestaurantID
     rate.Date = time.Now() // Setting the rate date to current time
-    result, err := db.RateCollection.InsertOne(context.Background(), rate)
+    err = h.rates.Create(r.Context(), &rate)
+    if errors.Is(err, repository.ErrDuplicate) {
+        h.logger.Printf("CreateRateHandler: Booking %v is already rated", booking.ID)
+        apierror.Conflict(w, r, "This booking has already been rated")
+        return
+    }
     if err != nil {
-        log.Printf("CreateRateHandler: Error inserting rate: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        h.logger.Printf("CreateRateHandler: Error inserting rate: %v", err)
+        apierror.Internal(w, r, err)
+        return
+    }
+
+    undo := func() error { return h.rates.Delete(r.Context(), rate.ID, rate.Rating) }
+    if !h.applyRatingChange(w, r, "CreateRateHandler", rate.RestaurantID, rate.Rating, 0, &rate.Date, undo) {
         return
     }
 
-    log.Printf("CreateRateHandler: Rate created, ID: %v", result.InsertedID)
-    json.NewEncoder(w).Encode(result)
+    h.logger.Printf("CreateRateHandler: Rate created, ID: %v", rate.ID)
+    writeCreated(w, "/rates/"+rate.ID.Hex(), dto.NewRateResponse(rate))
 }
 
 // GetRateHandler retrieves a rate by ID
-func GetRateHandler(w http.ResponseWriter, r *http.Request) {
+func (h *RateHandler) GetRateHandler(w http.ResponseWriter, r *http.Request) {
     params := mux.Vars(r)
     rateId, err := primitive.ObjectIDFromHex(params["id"])
     if err != nil {
-        log.Printf("GetRateHandler: Error parsing ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("GetRateHandler: Error parsing ID: %v", err)
+        apierror.InvalidID(w, r, "id")
         return
     }
 
-    var rate models.Rate
-    if err := db.RateCollection.FindOne(context.Background(), bson.M{"_id": rateId}).Decode(&rate); err != nil {
-        log.Printf("GetRateHandler: Error finding rate: %v", err)
-        http.Error(w, err.Error(), http.StatusNotFound)
+    rate, err := h.rates.FindByID(r.Context(), rateId)
+    if err != nil {
+        h.logger.Printf("GetRateHandler: Error finding rate: %v", err)
+        apierror.Lookup(w, r, err, "Rate not found")
         return
     }
 
-    log.Printf("GetRateHandler: Rate retrieved, ID: %v", rateId)
-    json.NewEncoder(w).Encode(rate)
+    h.logger.Printf("GetRateHandler: Rate retrieved, ID: %v", rateId)
+    json.NewEncoder(w).Encode(dto.NewRateResponse(rate))
 }
 
-// UpdateRateHandler updates a rate's details
-func UpdateRateHandler(w http.ResponseWriter, r *http.Request) {
+// UpdateRateHandler replaces the rating and comment of a rate
+func (h *RateHandler) UpdateRateHandler(w http.ResponseWriter, r *http.Request) {
     params := mux.Vars(r)
     rateId, err := primitive.ObjectIDFromHex(params["id"])
     if err != nil {
-        log.Printf("UpdateRateHandler: Error parsing ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("UpdateRateHandler: Error parsing ID: %v", err)
+        apierror.InvalidID(w, r, "id")
         return
     }
 
-    var rate models.Rate
-    if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
-        log.Printf("UpdateRateHandler: Error decoding rate: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+    existing, err := h.rates.FindByID(r.Context(), rateId)
+    if err != nil {
+        h.logger.Printf("UpdateRateHandler: Error finding rate: %v", err)
+        apierror.Lookup(w, r, err, "Rate not found")
         return
     }
 
-    _, err = db.RateCollection.UpdateOne(context.Background(), bson.M{"_id": rateId}, bson.M{"$set": rate})
-    if err != nil {
-        log.Printf("UpdateRateHandler: Error updating rate: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanManageRate(claims, existing) {
+        h.logger.Printf("UpdateRateHandler: Forbidden access to rate: %v", rateId)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
         return
     }
 
-    log.Printf("UpdateRateHandler: Rate updated, ID: %v", rateId)
-    w.WriteHeader(http.StatusNoContent)
+    var request dto.RateUpdateRequest
+    if err := decodeRequest(r, &request); err != nil {
+        h.logger.Printf("UpdateRateHandler: Error decoding rate: %v", err)
+        apierror.Invalid(w, r, err)
+        return
+    }
+
+    h.saveRate(w, r, "UpdateRateHandler", existing, request.Model())
 }
 
-// DeleteRateHandler deletes a rate by ID
-func DeleteRateHandler(w http.ResponseWriter, r *http.Request) {
+// PatchRateHandler changes only the rating or comment present in the request
+func (h *RateHandler) PatchRateHandler(w http.ResponseWriter, r *http.Request) {
     params := mux.Vars(r)
     rateId, err := primitive.ObjectIDFromHex(params["id"])
     if err != nil {
-        log.Printf("DeleteRateHandler: Error parsing ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("PatchRateHandler: Error parsing ID: %v", err)
+        apierror.InvalidID(w, r, "id")
         return
     }
 
-    _, err = db.RateCollection.DeleteOne(context.Background(), bson.M{"_id": rateId})
+    existing, err := h.rates.FindByID(r.Context(), rateId)
     if err != nil {
-        log.Printf("DeleteRateHandler: Error deleting rate: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        h.logger.Printf("PatchRateHandler: Error finding rate: %v", err)
+        apierror.Lookup(w, r, err, "Rate not found")
+        return
+    }
+
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanManageRate(claims, existing) {
+        h.logger.Printf("PatchRateHandler: Forbidden access to rate: %v", rateId)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
+        return
+    }
+
+    var request dto.RatePatch
+    if err := decodePatch(r, &request); err != nil {
+        h.logger.Printf("PatchRateHandler: Invalid rate patch: %v", err)
+        apierror.Invalid(w, r, err)
+        return
+    }
+
+    rate := existing
+    request.Apply(&rate)
+    h.saveRate(w, r, "PatchRateHandler", existing, rate)
+}
+
+// saveRate writes the new rating and comment over the existing rate and keeps the rating summary in step
+func (h *RateHandler) saveRate(w http.ResponseWriter, r *http.Request, handlerName string, existing, rate models.Rate) {
+    if err := rate.Validate(); err != nil {
+        h.logger.Printf("%s: Invalid rate: %v", handlerName, err)
+        apierror.Invalid(w, r, err)
+        return
+    }
+
+    // Only the rating and comment can change, the rate stays tied to its author and booking
+    rate.ID = existing.ID
+    rate.UserID = existing.UserID
+    rate.RestaurantID = existing.RestaurantID
+    rate.BookingID = existing.BookingID
+    rate.Date = existing.Date
+
+    // The write only applies over the rating that was read, which is the one the summary change removes
+    if err := h.rates.Update(r.Context(), rate, existing.Rating); err != nil {
+        h.logger.Printf("%s: Error updating rate: %v", handlerName, err)
+        h.rateWriteError(w, r, err)
         return
     }
 
-    log.Printf("DeleteRateHandler: Rate deleted, ID: %v", rateId)
+    if rate.Rating != existing.Rating {
+        undo := func() error { return h.rates.Update(r.Context(), existing, rate.Rating) }
+        if !h.applyRatingChange(w, r, handlerName, rate.RestaurantID, rate.Rating, existing.Rating, nil, undo) {
+            return
+        }
+    }
+
+    h.logger.Printf("%s: Rate updated, ID: %v", handlerName, rate.ID)
     w.WriteHeader(http.StatusNoContent)
 }
 
-// GetRatesForRestaurant retrieves all rates for a specific restaurant
-func GetRatesForRestaurant(w http.ResponseWriter, r *http.Request) {
+// DeleteRateHandler deletes a rate by ID
+func (h *RateHandler) DeleteRateHandler(w http.ResponseWriter, r *http.Request) {
     params := mux.Vars(r)
-    restaurantId, err := primitive.ObjectIDFromHex(params["restaurantId"])
+    rateId, err := primitive.ObjectIDFromHex(params["id"])
     if err != nil {
-        log.Printf("GetRatesForRestaurant: Error parsing restaurant ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("DeleteRateHandler: Error parsing ID: %v", err)
+        apierror.InvalidID(w, r, "id")
         return
     }
 
-    var rates []models.Rate
-    cursor, err := db.RateCollection.Find(context.Background(), bson.M{"restaurantId": restaurantId})
+    existing, err := h.rates.FindByID(r.Context(), rateId)
     if err != nil {
-        log.Printf("GetRatesForRestaurant: Error finding rates: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        h.logger.Printf("DeleteRateHandler: Error finding rate: %v", err)
+        apierror.Lookup(w, r, err, "Rate not found")
         return
     }
-    defer cursor.Close(context.Background())
 
-    for cursor.Next(context.Background()) {
-        var rate models.Rate
-        if err := cursor.Decode(&rate); err != nil {
-            log.Printf("GetRatesForRestaurant: Error decoding rate: %v", err)
-            continue
-        }
-        rates = append(rates, rate)
+    claims, _ := auth.FromContext(r.Context())
+    if !policy.CanManageRate(claims, existing) {
+        h.logger.Printf("DeleteRateHandler: Forbidden access to rate: %v", rateId)
+        apierror.Forbidden(w, r, "You do not have access to this resource")
+        return
+    }
+
+    if err := h.rates.Delete(r.Context(), rateId, existing.Rating); err != nil {
+        h.logger.Printf("DeleteRateHandler: Error deleting rate: %v", err)
+        h.rateWriteError(w, r, err)
+        return
     }
 
-    if err := cursor.Err(); err != nil {
-        log.Printf("GetRatesForRestaurant: Cursor error: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    undo := func() error { return h.rates.Create(r.Context(), &existing) }
+    if !h.applyRatingChange(w, r, "DeleteRateHandler", existing.RestaurantID, 0, existing.Rating, nil, undo) {
         return
     }
 
-    log.Printf("GetRatesForRestaurant: Successfully retrieved rates")
-    json.NewEncoder(w).Encode(rates)
+    h.logger.Printf("DeleteRateHandler: Rate deleted, ID: %v", rateId)
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// rateWriteError answers a conditional rate write that failed, the rate having been deleted or changed since it was read
+func (h *RateHandler) rateWriteError(w http.ResponseWriter, r *http.Request, err error) {
+    switch {
+    case errors.Is(err, repository.ErrNotFound):
+        apierror.NotFound(w, r, "Rate not found")
+    case errors.Is(err, repository.ErrConflict):
+        apierror.Conflict(w, r, "The rate was changed in the meantime, read it again")
+    default:
+        apierror.Internal(w, r, err)
+    }
+}
+
+// applyRatingChange brings the rating summary of the restaurant in step with a rate that was just written.
+// The summary is only ever changed by these increments, so when it cannot be updated the write is undone and the request
+// fails, rather than leave the summary off for good. It answers the error itself and returns false then.
+func (h *RateHandler) applyRatingChange(w http.ResponseWriter, r *http.Request, handlerName string, restaurantId primitive.ObjectID, added, removed int, ratedAt *time.Time, undo func() error) bool {
+    err := h.restaurants.ApplyRatingChange(r.Context(), restaurantId, added, removed, ratedAt)
+    if err == nil {
+        return true
+    }
+
+    h.logger.Printf("%s: Error updating rating summary: %v", handlerName, err)
+    if undoErr := undo(); undoErr != nil {
+        h.logger.Printf("%s: Error undoing the rate write, the rating summary of restaurant %v is off: %v", handlerName, restaurantId, undoErr)
+    }
+    apierror.Internal(w, r, err)
+    return false
 }
 
-// GetAverageRatingForRestaurant calculates the average rating for a specific restaurant
-func GetAverageRatingForRestaurant(w http.ResponseWriter, r *http.Request) {
+// rateSorts are the orders of the rate lists, newest or best first
+var rateSorts = map[string]bool{repository.SortByDate: true, repository.SortByRating: true}
+
+// GetRatesForRestaurant lists the rates of a restaurant a page at a time, newest first unless sorted by rating,
+// optionally only those rated between minRating and maxRating and given between from and to
+func (h *RateHandler) GetRatesForRestaurant(w http.ResponseWriter, r *http.Request) {
     params := mux.Vars(r)
     restaurantId, err := primitive.ObjectIDFromHex(params["restaurantId"])
     if err != nil {
-        log.Printf("GetAverageRatingForRestaurant: Error parsing restaurant ID: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        h.logger.Printf("GetRatesForRestaurant: Error parsing restaurant ID: %v", err)
+        apierror.InvalidID(w, r, "restaurantId")
+        return
+    }
+
+    var errs models.FieldErrors
+    pager := newPager(r, rateSorts, repository.Sort{Field: repository.SortByDate, Desc: true}, &errs)
+    filter := repository.RateFilter{
+        RestaurantID: restaurantId,
+        MinRating:    queryInt(r, "minRating", models.MinRating, models.MaxRating, &errs),
+        MaxRating:    queryInt(r, "maxRating", models.MinRating, models.MaxRating, &errs),
+        DateFrom:     queryTime(r, "from", &errs),
+        DateTo:       queryTime(r, "to", &errs),
+        Page:         pager.page(),
+    }
+    if len(errs) > 0 {
+        h.logger.Printf("GetRatesForRestaurant: Invalid parameters: %v", errs)
+        apierror.Invalid(w, r, errs)
+        return
+    }
+
+    rates, err := h.rates.List(r.Context(), filter)
+    if err != nil {
+        h.logger.Printf("GetRatesForRestaurant: Error finding rates: %v", err)
+        apierror.Internal(w, r, err)
         return
     }
 
-    pipeline := []bson.M{
-        {"$match": bson.M{"restaurantId": restaurantId}},
-        {"$group": bson.M{"_id": "$restaurantId", "averageRating": bson.M{"$avg": "$rating"}}},
+    var next string
+    if pager.more(len(rates)) {
+        rates = rates[:len(rates)-1]
+        next = pager.next(repository.RateCursor(rates[len(rates)-1], pager.sort))
+    }
+
+    h.logger.Printf("GetRatesForRestaurant: Successfully retrieved rates")
+    json.NewEncoder(w).Encode(dto.RatePage{Items: dto.NewRateResponses(rates), Next: next})
+}
+
+// GetAverageRatingForRestaurant returns the average rating for a specific restaurant from its rating summary
+func (h *RateHandler) GetAverageRatingForRestaurant(w http.ResponseWriter, r *http.Request) {
+    params := mux.Vars(r)
+    restaurantId, err := primitive.ObjectIDFromHex(params["restaurantId"])
+    if err != nil {
+        h.logger.Printf("GetAverageRatingForRestaurant: Error parsing restaurant ID: %v", err)
+        apierror.InvalidID(w, r, "restaurantId")
+        return
     }
 
-    cursor, err := db.RateCollection.Aggregate(context.Background(), pipeline)
+    restaurant, err := h.restaurants.FindByID(r.Context(), restaurantId)
     if err != nil {
-        log.Printf("GetAverageRatingForRestaurant: Error aggregating ratings: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        h.logger.Printf("GetAverageRatingForRestaurant: Error finding restaurant: %v", err)
+        apierror.Lookup(w, r, err, "Restaurant not found")
         return
     }
-    defer cursor.Close(context.Background())
 
-    var results []bson.M
-    if cursor.All(context.Background(), &results) != nil || len(results) == 0 {
-        log.Printf("GetAverageRatingForRestaurant: No ratings found")
-        json.NewEncoder(w).Encode(bson.M{"averageRating": 0})
+    if restaurant.RatingSummary == nil || restaurant.RatingSummary.Count == 0 {
+        h.logger.Printf("GetAverageRatingForRestaurant: No ratings found")
+        json.NewEncoder(w).Encode(dto.AverageRatingResponse{RestaurantID: restaurantId})
         return
     }
 
-    log.Printf("GetAverageRatingForRestaurant: Successfully calculated average rating")
-    json.NewEncoder(w).Encode(results[0])
+    h.logger.Printf("GetAverageRatingForRestaurant: Successfully retrieved average rating")
+    json.NewEncoder(w).Encode(dto.AverageRatingResponse{
+        RestaurantID:  restaurantId,
+        AverageRating: restaurant.RatingSummary.Mean,
+        Count:         restaurant.RatingSummary.Count,
+    })
 }
 
 // GetRecentRatings retrieves the most recent ratings, limited to a specified number
-func GetRecentRatings(w http.ResponseWriter, r *http.Request) {
+func (h *RateHandler) GetRecentRatings(w http.ResponseWriter, r *http.Request) {
     limitQuery := r.URL.Query().Get("limit")
     limit, err := strconv.Atoi(limitQuery)
     if err != nil || limit <= 0 {
         limit = 10 // Default to 10 if no valid limit is provided
     }
 
-    var ratings []models.Rate
-    cursor, err := db.RateCollection.Find(
-        context.Background(),
-        bson.M{},
-        options.Find().SetSort(bson.M{"date": -1}).SetLimit(int64(limit)),
-    )
+    ratings, err := h.rates.List(r.Context(), repository.RateFilter{Page: repository.Page{Limit: limit}})
     if err != nil {
-        log.Printf("GetRecentRatings: Error finding recent ratings: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
-        return
-    }
-    defer cursor.Close(context.Background())
-
-    for cursor.Next(context.Background()) {
-        var rate models.Rate
-        if err := cursor.Decode(&rate); err != nil {
-            log.Printf("GetRecentRatings: Error decoding rate: %v", err)
-            continue
-        }
-        ratings = append(ratings, rate)
-    }
-
-    if err := cursor.Err(); err != nil {
-        log.Printf("GetRecentRatings: Cursor error: %v", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        h.logger.Printf("GetRecentRatings: Error finding recent ratings: %v", err)
+        apierror.Internal(w, r, err)
         return
     }
 
-    log.Printf("GetRecentRatings: Successfully retrieved recent ratings")
-    json.NewEncoder(w).Encode(ratings)
+    h.logger.Printf("GetRecentRatings: Successfully retrieved recent ratings")
+    json.NewEncoder(w).Encode(dto.NewRateResponses(ratings))
 }
@@ -1,46 +1,227 @@
 package handlers
 
 import (
+	"book-and-rate/pkg/apierror"
 	"book-and-rate/pkg/auth"
-	"book-and-rate/pkg/config"
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/repository"
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
-	var tokenRequest struct {
-		RefreshToken string `json:"refreshToken"`
+var errInvalidRefreshToken = errors.New("token is not a refresh token")
+
+// TokenHandler serves the refresh token rotation and logout endpoints
+type TokenHandler struct {
+	tokens      repository.TokenRepository
+	users       repository.UserRepository
+	restaurants repository.RestaurantRepository
+	signer      *auth.TokenService
+	logger      *log.Logger
+}
+
+func NewTokenHandler(tokens repository.TokenRepository, users repository.UserRepository, restaurants repository.RestaurantRepository, signer *auth.TokenService, logger *log.Logger) *TokenHandler {
+	return &TokenHandler{tokens: tokens, users: users, restaurants: restaurants, signer: signer, logger: logger}
+}
+
+// RefreshTokenHandler exchanges a refresh token for a new access and refresh token pair.
+// Every refresh token can be used once; presenting a used one revokes its whole family.
+func (h *TokenHandler) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var tokenRequest dto.RefreshTokenRequest
+	if err := decodeRequest(r, &tokenRequest); err != nil {
+		apierror.Invalid(w, r, err)
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&tokenRequest); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+
+	claims, err := parseRefreshToken(h.signer, tokenRequest.RefreshToken)
+	if err != nil {
+		apierror.Unauthorized(w, r, "Invalid refresh token")
 		return
 	}
 
-	// Load the configuration
-	cfg := config.LoadConfig("config/config.json")
+	stored, err := h.tokens.Consume(r.Context(), claims.Id, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		h.revokeReusedToken(r.Context(), claims.Id)
+		apierror.Unauthorized(w, r, "Invalid refresh token")
+		return
+	}
+	if err != nil {
+		h.logger.Printf("RefreshTokenHandler: Error consuming refresh token: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
 
-	// Validate the refresh token
-	token, err := auth.ValidateToken(tokenRequest.RefreshToken, *cfg)
+	// Deleting an account revokes its tokens, this catches the tokens of an account deleted while that failed
+	exists, err := h.accountExists(r.Context(), claims)
 	if err != nil {
-		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		h.logger.Printf("RefreshTokenHandler: Error finding account: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+	if !exists {
+		h.logger.Printf("RefreshTokenHandler: Account %v no longer exists", claims.UserId)
+		if err := h.tokens.RevokeUser(r.Context(), claims.UserId, time.Now()); err != nil {
+			h.logger.Printf("RefreshTokenHandler: Error revoking tokens: %v", err)
+		}
+		apierror.Unauthorized(w, r, "Invalid refresh token")
 		return
 	}
 
-	claims, ok := token.Claims.(*auth.Claims)
+	// The new tokens keep the principal type of the refresh token,
+	// unless the account has been removed from the administrators since it logged in
+	principalType := claims.Type
+	if principalType == auth.PrincipalAdmin && !h.signer.IsAdmin(claims.UserId) {
+		principalType = auth.PrincipalUser
+	}
+
+	tokens, replacedBy, err := issueTokens(r.Context(), h.tokens, h.signer, claims.UserId, principalType, stored.FamilyID)
+	if err != nil {
+		h.logger.Printf("RefreshTokenHandler: Error issuing tokens: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	if err := h.tokens.SetReplacedBy(r.Context(), stored.TokenID, replacedBy); err != nil {
+		h.logger.Printf("RefreshTokenHandler: Error recording rotation: %v", err)
+	}
+
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// accountExists reports whether the user or restaurant the token was issued to is still there
+func (h *TokenHandler) accountExists(ctx context.Context, claims *auth.Claims) (bool, error) {
+	id, err := primitive.ObjectIDFromHex(claims.UserId)
+	if err != nil {
+		return false, nil
+	}
+	if claims.Type == auth.PrincipalRestaurant {
+		_, err = h.restaurants.FindByID(ctx, id)
+	} else {
+		_, err = h.users.FindByID(ctx, id)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// LogoutHandler revokes the refresh token family of the current session
+func (h *TokenHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	var tokenRequest dto.RefreshTokenRequest
+	if err := decodeRequest(r, &tokenRequest); err != nil {
+		h.logger.Printf("LogoutHandler: Error decoding request: %v", err)
+		apierror.Invalid(w, r, err)
+		return
+	}
+
+	claims, err := parseRefreshToken(h.signer, tokenRequest.RefreshToken)
+	if err != nil {
+		apierror.Unauthorized(w, r, "Invalid refresh token")
+		return
+	}
+
+	stored, err := h.tokens.FindByTokenID(r.Context(), claims.Id)
+	if err != nil {
+		h.logger.Printf("LogoutHandler: Error finding refresh token: %v", err)
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Unauthorized(w, r, "Invalid refresh token")
+			return
+		}
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	if err := h.tokens.RevokeFamily(r.Context(), stored.FamilyID, time.Now()); err != nil {
+		h.logger.Printf("LogoutHandler: Error revoking tokens: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	h.logger.Printf("LogoutHandler: Session logged out for: %v", claims.UserId)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAllHandler revokes every refresh token of the authenticated account
+func (h *TokenHandler) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.FromContext(r.Context())
 	if !ok {
-		http.Error(w, "Failed to parse token claims", http.StatusUnauthorized)
+		apierror.Unauthorized(w, r, "Authorization header is required")
+		return
+	}
+
+	if err := h.tokens.RevokeUser(r.Context(), claims.UserId, time.Now()); err != nil {
+		h.logger.Printf("LogoutAllHandler: Error revoking tokens: %v", err)
+		apierror.Internal(w, r, err)
 		return
 	}
 
-	// Ensure that claims.UserID is correctly named based on your Claims structure
-	newAccessToken, err := auth.GenerateToken(claims.UserId, *cfg)
+	h.logger.Printf("LogoutAllHandler: All sessions logged out for: %v", claims.UserId)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// issueTokens signs an access token and a refresh token and persists the refresh token.
+// An empty familyID starts a new family, as on login. It returns the jti of the new refresh token.
+func issueTokens(ctx context.Context, tokens repository.TokenRepository, signer *auth.TokenService, userID string, principalType auth.PrincipalType, familyID string) (dto.TokenResponse, string, error) {
+	accessToken, err := signer.GenerateToken(userID, principalType)
+	if err != nil {
+		return dto.TokenResponse{}, "", err
+	}
+
+	now := time.Now()
+	stored := models.RefreshToken{
+		TokenID:       primitive.NewObjectID().Hex(),
+		FamilyID:      familyID,
+		UserID:        userID,
+		PrincipalType: string(principalType),
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(signer.RefreshTokenLifetime()),
+	}
+	if stored.FamilyID == "" {
+		stored.FamilyID = primitive.NewObjectID().Hex()
+	}
+
+	refreshToken, err := signer.GenerateRefreshToken(userID, principalType, stored.TokenID, stored.ExpiresAt)
+	if err != nil {
+		return dto.TokenResponse{}, "", err
+	}
+
+	if err := tokens.Create(ctx, stored); err != nil {
+		return dto.TokenResponse{}, "", err
+	}
+
+	return dto.TokenResponse{AccessToken: accessToken, RefreshToken: refreshToken}, stored.TokenID, nil
+}
+
+// parseRefreshToken validates the token and makes sure it is a refresh token rather than an access token
+func parseRefreshToken(signer *auth.TokenService, tokenString string) (*auth.Claims, error) {
+	token, err := signer.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*auth.Claims)
+	if !ok || claims.Use != auth.UseRefresh {
+		return nil, errInvalidRefreshToken
+	}
+	return claims, nil
+}
+
+// revokeReusedToken revokes the family of a refresh token that is presented after it was used or revoked
+func (h *TokenHandler) revokeReusedToken(ctx context.Context, tokenID string) {
+	stored, err := h.tokens.FindByTokenID(ctx, tokenID)
 	if err != nil {
-		http.Error(w, "Failed to generate new access token", http.StatusInternalServerError)
 		return
 	}
 
-	response := map[string]string{
-		"accessToken": newAccessToken,
+	h.logger.Printf("RefreshTokenHandler: Refresh token reuse detected, revoking family %v of %v", stored.FamilyID, stored.UserID)
+	if err := h.tokens.RevokeFamily(ctx, stored.FamilyID, time.Now()); err != nil {
+		h.logger.Printf("RefreshTokenHandler: Error revoking token family: %v", err)
 	}
-	json.NewEncoder(w).Encode(response)
 }
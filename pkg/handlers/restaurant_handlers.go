@@ -1,199 +1,333 @@
 package handlers
 
 import (
+	"book-and-rate/pkg/apierror"
 	"book-and-rate/pkg/auth"
-	"book-and-rate/pkg/config"
-	"book-and-rate/pkg/db"
+	"book-and-rate/pkg/dto"
 	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/phone"
+	"book-and-rate/pkg/policy"
+	"book-and-rate/pkg/repository"
 	"book-and-rate/pkg/utils"
-	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// RestaurantHandler serves the restaurants along with their opening hours and tables
+type RestaurantHandler struct {
+	restaurants repository.RestaurantRepository
+	tokens      repository.TokenRepository
+	signer      *auth.TokenService
+	hasher      *utils.PasswordHasher
+	logger      *log.Logger
+}
+
+func NewRestaurantHandler(restaurants repository.RestaurantRepository, tokens repository.TokenRepository, signer *auth.TokenService, hasher *utils.PasswordHasher, logger *log.Logger) *RestaurantHandler {
+	return &RestaurantHandler{restaurants: restaurants, tokens: tokens, signer: signer, hasher: hasher, logger: logger}
+}
+
 // CreateRestaurantHandler handles the creation of a new restaurant
-func CreateRestaurantHandler(w http.ResponseWriter, r *http.Request) {
-	var restaurant models.Restaurant
-	if err := json.NewDecoder(r.Body).Decode(&restaurant); err != nil {
-		log.Printf("CreateRestaurantHandler: Error decoding restaurant data: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+func (h *RestaurantHandler) CreateRestaurantHandler(w http.ResponseWriter, r *http.Request) {
+	var request dto.RestaurantRequest
+	if err := decodeRequest(r, &request); err != nil {
+		h.logger.Printf("CreateRestaurantHandler: Error decoding restaurant data: %v", err)
+		apierror.Invalid(w, r, err)
 		return
 	}
+	restaurant := request.Model()
 
-	hashedPassword, err := utils.HashPassword(restaurant.Password)
+	phoneNumber, err := phone.Normalize(restaurant.Phone)
 	if err != nil {
-		log.Printf("CreateRestaurantHandler: Error hashing password: %v", err)
-		http.Error(w, "Error processing password", http.StatusInternalServerError)
+		h.logger.Printf("CreateRestaurantHandler: Invalid phone number: %v", err)
+		invalidPhone(w, r, "phone", err)
 		return
 	}
-	restaurant.Password = hashedPassword
+	restaurant.Phone = phoneNumber
 
-	result, err := db.RestaurantCollection.InsertOne(context.Background(), restaurant)
+	hashedPassword, err := h.hasher.Hash(restaurant.Password)
 	if err != nil {
-		log.Printf("CreateRestaurantHandler: Error inserting new restaurant: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.logger.Printf("CreateRestaurantHandler: Error hashing password: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+	restaurant.Password = hashedPassword
+
+	if err := h.restaurants.Create(r.Context(), &restaurant); err != nil {
+		h.logger.Printf("CreateRestaurantHandler: Error inserting new restaurant: %v", err)
+		if errors.Is(err, repository.ErrDuplicate) {
+			apierror.Conflict(w, r, errPhoneTaken.Error())
+			return
+		}
+		apierror.Internal(w, r, err)
 		return
 	}
 
-	log.Printf("CreateRestaurantHandler: Restaurant created successfully: %v", result.InsertedID)
-	json.NewEncoder(w).Encode(result)
+	h.logger.Printf("CreateRestaurantHandler: Restaurant created successfully: %v", restaurant.ID)
+	writeCreated(w, "/restaurants/"+restaurant.ID.Hex(), dto.NewRestaurantResponse(restaurant))
 }
 
-// GetRestaurantsHandler lists all restaurants
-func GetRestaurantsHandler(w http.ResponseWriter, r *http.Request) {
-	var restaurants []models.Restaurant
+// restaurantSorts are the orders of the restaurant list, best rated or alphabetical first
+var restaurantSorts = map[string]bool{repository.SortByRating: true, repository.SortByName: false}
 
-	cursor, err := db.RestaurantCollection.Find(context.Background(), bson.M{})
+// GetRestaurantsHandler lists the restaurants a page at a time, oldest first unless sorted by rating or name,
+// optionally only those with a mean rating between minRating and maxRating
+func (h *RestaurantHandler) GetRestaurantsHandler(w http.ResponseWriter, r *http.Request) {
+	var errs models.FieldErrors
+	pager := newPager(r, restaurantSorts, repository.Sort{}, &errs)
+	filter := repository.RestaurantFilter{
+		MinRating: queryNumber(r, "minRating", models.MinRating, models.MaxRating, &errs),
+		MaxRating: queryNumber(r, "maxRating", models.MinRating, models.MaxRating, &errs),
+		Page:      pager.page(),
+	}
+	if len(errs) > 0 {
+		h.logger.Printf("GetRestaurantsHandler: Invalid parameters: %v", errs)
+		apierror.Invalid(w, r, errs)
+		return
+	}
+
+	restaurants, err := h.restaurants.List(r.Context(), filter)
 	if err != nil {
-		log.Printf("GetRestaurantsHandler: Error finding restaurants: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.logger.Printf("GetRestaurantsHandler: Error finding restaurants: %v", err)
+		apierror.Internal(w, r, err)
 		return
 	}
-	defer cursor.Close(context.Background())
 
-	for cursor.Next(context.Background()) {
-		var restaurant models.Restaurant
-		if err := cursor.Decode(&restaurant); err != nil {
-			log.Printf("GetRestaurantsHandler: Error decoding restaurant: %v", err)
-			continue
-		}
-		restaurants = append(restaurants, restaurant)
+	var next string
+	if pager.more(len(restaurants)) {
+		restaurants = restaurants[:len(restaurants)-1]
+		next = pager.next(repository.RestaurantCursor(restaurants[len(restaurants)-1], pager.sort))
+	}
+
+	h.logger.Printf("GetRestaurantsHandler: Successfully retrieved restaurants")
+	json.NewEncoder(w).Encode(dto.RestaurantPage{Items: dto.NewRestaurantResponses(restaurants), Next: next})
+}
+
+// GetRestaurantHandler retrieves a restaurant by ID
+func (h *RestaurantHandler) GetRestaurantHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	restaurantId, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		h.logger.Printf("GetRestaurantHandler: Error parsing ID: %v", err)
+		apierror.InvalidID(w, r, "id")
+		return
 	}
 
-	if err := cursor.Err(); err != nil {
-		log.Printf("GetRestaurantsHandler: Cursor error: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	restaurant, err := h.restaurants.FindByID(r.Context(), restaurantId)
+	if err != nil {
+		h.logger.Printf("GetRestaurantHandler: Error finding restaurant: %v", err)
+		apierror.Lookup(w, r, err, "Restaurant not found")
 		return
 	}
 
-	log.Printf("GetRestaurantsHandler: Successfully retrieved restaurants")
-	json.NewEncoder(w).Encode(restaurants)
+	h.logger.Printf("GetRestaurantHandler: Restaurant retrieved: %v", restaurantId)
+	json.NewEncoder(w).Encode(dto.NewRestaurantResponse(restaurant))
 }
 
-// GetRestaurantHandler retrieves a restaurant by ID
-func GetRestaurantHandler(w http.ResponseWriter, r *http.Request) {
+// UpdateRestaurantHandler replaces a restaurant's details, an empty password keeps the current one
+func (h *RestaurantHandler) UpdateRestaurantHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	restaurantId, err := primitive.ObjectIDFromHex(params["id"])
 	if err != nil {
-		log.Printf("GetRestaurantHandler: Error parsing ID: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.logger.Printf("UpdateRestaurantHandler: Error parsing ID: %v", err)
+		apierror.InvalidID(w, r, "id")
 		return
 	}
 
-	var restaurant models.Restaurant
-	if err := db.RestaurantCollection.FindOne(context.Background(), bson.M{"_id": restaurantId}).Decode(&restaurant); err != nil {
-		log.Printf("GetRestaurantHandler: Error finding restaurant: %v", err)
-		http.Error(w, err.Error(), http.StatusNotFound)
+	claims, _ := auth.FromContext(r.Context())
+	if !policy.CanActAsRestaurant(claims, restaurantId) {
+		h.logger.Printf("UpdateRestaurantHandler: Forbidden access to restaurant: %v", restaurantId)
+		apierror.Forbidden(w, r, "You do not have access to this resource")
 		return
 	}
 
-	log.Printf("GetRestaurantHandler: Restaurant retrieved: %v", restaurantId)
-	json.NewEncoder(w).Encode(restaurant)
+	var request dto.RestaurantUpdateRequest
+	if err := decodeRequest(r, &request); err != nil {
+		h.logger.Printf("UpdateRestaurantHandler: Error decoding restaurant: %v", err)
+		apierror.Invalid(w, r, err)
+		return
+	}
+
+	existing, err := h.restaurants.FindByID(r.Context(), restaurantId)
+	if err != nil {
+		h.logger.Printf("UpdateRestaurantHandler: Error finding restaurant: %v", err)
+		apierror.Lookup(w, r, err, "Restaurant not found")
+		return
+	}
+
+	h.saveRestaurant(w, r, "UpdateRestaurantHandler", existing, request.Model(), request.Password)
 }
 
-// UpdateRestaurantHandler updates a restaurant's details
-func UpdateRestaurantHandler(w http.ResponseWriter, r *http.Request) {
+// PatchRestaurantHandler changes only the restaurant details present in the request
+func (h *RestaurantHandler) PatchRestaurantHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	restaurantId, err := primitive.ObjectIDFromHex(params["id"])
 	if err != nil {
-		log.Printf("UpdateRestaurantHandler: Error parsing ID: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.logger.Printf("PatchRestaurantHandler: Error parsing ID: %v", err)
+		apierror.InvalidID(w, r, "id")
 		return
 	}
 
-	var restaurant models.Restaurant
-	if err := json.NewDecoder(r.Body).Decode(&restaurant); err != nil {
-		log.Printf("UpdateRestaurantHandler: Error decoding restaurant: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	claims, _ := auth.FromContext(r.Context())
+	if !policy.CanActAsRestaurant(claims, restaurantId) {
+		h.logger.Printf("PatchRestaurantHandler: Forbidden access to restaurant: %v", restaurantId)
+		apierror.Forbidden(w, r, "You do not have access to this resource")
 		return
 	}
 
-	if restaurant.Password != "" {
-		hashedPassword, err := utils.HashPassword(restaurant.Password)
+	var request dto.RestaurantPatch
+	if err := decodePatch(r, &request); err != nil {
+		h.logger.Printf("PatchRestaurantHandler: Invalid restaurant patch: %v", err)
+		apierror.Invalid(w, r, err)
+		return
+	}
+
+	existing, err := h.restaurants.FindByID(r.Context(), restaurantId)
+	if err != nil {
+		h.logger.Printf("PatchRestaurantHandler: Error finding restaurant: %v", err)
+		apierror.Lookup(w, r, err, "Restaurant not found")
+		return
+	}
+
+	restaurant := existing
+	request.Apply(&restaurant)
+	var password string
+	if request.Password != nil {
+		password = *request.Password
+	}
+	h.saveRestaurant(w, r, "PatchRestaurantHandler", existing, restaurant, password)
+}
+
+// saveRestaurant writes the updated details over the existing restaurant, hashing the password and ending the sessions when a new one was given
+func (h *RestaurantHandler) saveRestaurant(w http.ResponseWriter, r *http.Request, handlerName string, existing, restaurant models.Restaurant, password string) {
+	phoneNumber, err := phone.Normalize(restaurant.Phone)
+	if err != nil {
+		h.logger.Printf("%s: Invalid phone number: %v", handlerName, err)
+		invalidPhone(w, r, "phone", err)
+		return
+	}
+	restaurant.Phone = phoneNumber
+
+	// An empty password keeps the current one
+	restaurant.Password = existing.Password
+	if password != "" {
+		hashedPassword, err := h.hasher.Hash(password)
 		if err != nil {
-			log.Printf("UpdateRestaurantHandler: Error hashing password: %v", err)
-			http.Error(w, "Error processing password", http.StatusInternalServerError)
+			h.logger.Printf("%s: Error hashing password: %v", handlerName, err)
+			apierror.Internal(w, r, err)
 			return
 		}
 		restaurant.Password = hashedPassword
 	}
 
-	_, err = db.RestaurantCollection.UpdateOne(context.Background(), bson.M{"_id": restaurantId}, bson.M{"$set": restaurant})
-	if err != nil {
-		log.Printf("UpdateRestaurantHandler: Error updating restaurant: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// The repository leaves the opening hours and the rating summary untouched
+	restaurant.ID = existing.ID
+	if err := h.restaurants.Update(r.Context(), restaurant); err != nil {
+		h.logger.Printf("%s: Error updating restaurant: %v", handlerName, err)
+		if errors.Is(err, repository.ErrDuplicate) {
+			apierror.Conflict(w, r, errPhoneTaken.Error())
+			return
+		}
+		apierror.Internal(w, r, err)
 		return
 	}
 
-	log.Printf("UpdateRestaurantHandler: Restaurant updated successfully: %v", restaurantId)
+	// Whoever knew the old password may still hold tokens issued with it
+	if password != "" {
+		if err := h.tokens.RevokeUser(r.Context(), restaurant.ID.Hex(), time.Now()); err != nil {
+			h.logger.Printf("%s: Error revoking refresh tokens: %v", handlerName, err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Password changed, but existing sessions could not be logged out")
+			return
+		}
+	}
+
+	h.logger.Printf("%s: Restaurant updated successfully: %v", handlerName, restaurant.ID)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // DeleteRestaurantHandler deletes a restaurant by ID
-func DeleteRestaurantHandler(w http.ResponseWriter, r *http.Request) {
+func (h *RestaurantHandler) DeleteRestaurantHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	restaurantId, err := primitive.ObjectIDFromHex(params["id"])
 	if err != nil {
-		log.Printf("DeleteRestaurantHandler: Error parsing ID: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.logger.Printf("DeleteRestaurantHandler: Error parsing ID: %v", err)
+		apierror.InvalidID(w, r, "id")
 		return
 	}
 
-	_, err = db.RestaurantCollection.DeleteOne(context.Background(), bson.M{"_id": restaurantId})
-	if err != nil {
-		log.Printf("DeleteRestaurantHandler: Error deleting restaurant: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	claims, _ := auth.FromContext(r.Context())
+	if !policy.CanActAsRestaurant(claims, restaurantId) {
+		h.logger.Printf("DeleteRestaurantHandler: Forbidden access to restaurant: %v", restaurantId)
+		apierror.Forbidden(w, r, "You do not have access to this resource")
 		return
 	}
 
-	log.Printf("DeleteRestaurantHandler: Restaurant deleted successfully: %v", restaurantId)
+	if err := h.restaurants.Delete(r.Context(), restaurantId); err != nil {
+		h.logger.Printf("DeleteRestaurantHandler: Error deleting restaurant: %v", err)
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.NotFound(w, r, "Restaurant not found")
+			return
+		}
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	// The sessions of the account end with it
+	if err := h.tokens.RevokeUser(r.Context(), restaurantId.Hex(), time.Now()); err != nil {
+		h.logger.Printf("DeleteRestaurantHandler: Error revoking tokens: %v", err)
+	}
+
+	h.logger.Printf("DeleteRestaurantHandler: Restaurant deleted successfully: %v", restaurantId)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // LoginRestaurantHandler handles the login process for a restaurant
-func LoginRestaurantHandler(w http.ResponseWriter, r *http.Request) {
-	var loginDetails models.Login
-	if err := json.NewDecoder(r.Body).Decode(&loginDetails); err != nil {
-		log.Printf("LoginRestaurantHandler: Error decoding login details: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+func (h *RestaurantHandler) LoginRestaurantHandler(w http.ResponseWriter, r *http.Request) {
+	var loginDetails dto.LoginRequest
+	if err := decodeRequest(r, &loginDetails); err != nil {
+		h.logger.Printf("LoginRestaurantHandler: Error decoding login details: %v", err)
+		apierror.Invalid(w, r, err)
 		return
 	}
 
-	var restaurant models.Restaurant
-	err := db.RestaurantCollection.FindOne(context.Background(), bson.M{"phone": loginDetails.Phone}).Decode(&restaurant)
+	// Numbers are stored normalized, so any formatting of the same number logs in
+	phoneNumber, err := phone.Normalize(loginDetails.Phone)
 	if err != nil {
-		log.Printf("LoginRestaurantHandler: Error finding restaurant: %v", err)
-		http.Error(w, "Invalid phone number or password", http.StatusUnauthorized)
+		h.logger.Printf("LoginRestaurantHandler: Invalid phone number: %v", err)
+		apierror.Unauthorized(w, r, "Invalid phone number or password")
 		return
 	}
 
-	if err = utils.ComparePasswords(restaurant.Password, loginDetails.Password); err != nil {
-		log.Printf("LoginRestaurantHandler: Password does not match: %v", err)
-		http.Error(w, "Invalid phone number or password", http.StatusUnauthorized)
+	restaurant, err := h.restaurants.FindByPhone(r.Context(), phoneNumber)
+	if err != nil {
+		h.logger.Printf("LoginRestaurantHandler: Error finding restaurant: %v", err)
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Unauthorized(w, r, "Invalid phone number or password")
+			return
+		}
+		apierror.Internal(w, r, err)
 		return
 	}
 
-	// Generate JWT Token
-	cfg := config.LoadConfig("./config/config.json")
-	accessToken, err := auth.GenerateToken(restaurant.ID.Hex(), *cfg)
-	if err != nil {
-		log.Printf("LoginRestaurantHandler: Error generating access token: %v", err)
-		http.Error(w, "Error generating access token", http.StatusInternalServerError)
+	if err = utils.ComparePasswords(restaurant.Password, loginDetails.Password); err != nil {
+		h.logger.Printf("LoginRestaurantHandler: Password does not match: %v", err)
+		apierror.Unauthorized(w, r, "Invalid phone number or password")
 		return
 	}
 
-	refreshToken, err := auth.GenerateRefreshToken(restaurant.ID.Hex(), *cfg)
+	// Generate JWT Token
+	tokens, _, err := issueTokens(r.Context(), h.tokens, h.signer, restaurant.ID.Hex(), auth.PrincipalRestaurant, "")
 	if err != nil {
-		log.Printf("LoginRestaurantHandler: Error generating refresh token: %v", err)
-		http.Error(w, "Error generating refresh token", http.StatusInternalServerError)
+		h.logger.Printf("LoginRestaurantHandler: Error generating tokens: %v", err)
+		apierror.Internal(w, r, err)
 		return
 	}
 
-	log.Printf("LoginRestaurantHandler: Restaurant logged in successfully: %v", restaurant.ID)
-	json.NewEncoder(w).Encode(map[string]string{"accessToken": accessToken, "refreshToken": refreshToken})
+	h.logger.Printf("LoginRestaurantHandler: Restaurant logged in successfully: %v", restaurant.ID)
+	json.NewEncoder(w).Encode(tokens)
 }
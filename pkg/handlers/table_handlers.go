@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"book-and-rate/pkg/apierror"
+	"book-and-rate/pkg/auth"
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/policy"
+	"book-and-rate/pkg/repository"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CreateTableHandler adds a table to a restaurant's inventory
+func (h *RestaurantHandler) CreateTableHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	restaurantId, err := primitive.ObjectIDFromHex(params["restaurantId"])
+	if err != nil {
+		h.logger.Printf("CreateTableHandler: Error parsing restaurant ID: %v", err)
+		apierror.InvalidID(w, r, "restaurantId")
+		return
+	}
+
+	claims, _ := auth.FromContext(r.Context())
+	if !policy.CanActAsRestaurant(claims, restaurantId) {
+		h.logger.Printf("CreateTableHandler: Forbidden access to restaurant: %v", restaurantId)
+		apierror.Forbidden(w, r, "You do not have access to this resource")
+		return
+	}
+
+	var request dto.TableRequest
+	if err := decodeRequest(r, &request); err != nil {
+		h.logger.Printf("CreateTableHandler: Error decoding table: %v", err)
+		apierror.Invalid(w, r, err)
+		return
+	}
+	table := request.Model()
+
+	if _, err := h.restaurants.FindByID(r.Context(), restaurantId); err != nil {
+		h.logger.Printf("CreateTableHandler: Error finding restaurant: %v", err)
+		apierror.Lookup(w, r, err, "Restaurant not found")
+		return
+	}
+
+	table.RestaurantID = restaurantId
+	if err := h.restaurants.CreateTable(r.Context(), &table); err != nil {
+		h.logger.Printf("CreateTableHandler: Error inserting table: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	h.logger.Printf("CreateTableHandler: Table created, ID: %v", table.ID)
+	writeCreated(w, "/restaurants/"+restaurantId.Hex()+"/tables/"+table.ID.Hex(), dto.NewTableResponse(table))
+}
+
+// GetTablesHandler lists the tables of a restaurant
+func (h *RestaurantHandler) GetTablesHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	restaurantId, err := primitive.ObjectIDFromHex(params["restaurantId"])
+	if err != nil {
+		h.logger.Printf("GetTablesHandler: Error parsing restaurant ID: %v", err)
+		apierror.InvalidID(w, r, "restaurantId")
+		return
+	}
+
+	claims, _ := auth.FromContext(r.Context())
+	if !policy.CanActAsRestaurant(claims, restaurantId) {
+		h.logger.Printf("GetTablesHandler: Forbidden access to restaurant: %v", restaurantId)
+		apierror.Forbidden(w, r, "You do not have access to this resource")
+		return
+	}
+
+	tables, err := h.restaurants.ListTables(r.Context(), restaurantId)
+	if err != nil {
+		h.logger.Printf("GetTablesHandler: Error finding tables: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	h.logger.Printf("GetTablesHandler: Successfully retrieved tables")
+	json.NewEncoder(w).Encode(dto.NewTableResponses(tables))
+}
+
+// UpdateTableHandler updates a table's seats, zone or combinable flag
+func (h *RestaurantHandler) UpdateTableHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	restaurantId, err := primitive.ObjectIDFromHex(params["restaurantId"])
+	if err != nil {
+		h.logger.Printf("UpdateTableHandler: Error parsing restaurant ID: %v", err)
+		apierror.InvalidID(w, r, "restaurantId")
+		return
+	}
+
+	claims, _ := auth.FromContext(r.Context())
+	if !policy.CanActAsRestaurant(claims, restaurantId) {
+		h.logger.Printf("UpdateTableHandler: Forbidden access to restaurant: %v", restaurantId)
+		apierror.Forbidden(w, r, "You do not have access to this resource")
+		return
+	}
+
+	tableId, err := primitive.ObjectIDFromHex(params["tableId"])
+	if err != nil {
+		h.logger.Printf("UpdateTableHandler: Error parsing table ID: %v", err)
+		apierror.InvalidID(w, r, "tableId")
+		return
+	}
+
+	var request dto.TableRequest
+	if err := decodeRequest(r, &request); err != nil {
+		h.logger.Printf("UpdateTableHandler: Error decoding table: %v", err)
+		apierror.Invalid(w, r, err)
+		return
+	}
+	table := request.Model()
+
+	table.ID = tableId
+	table.RestaurantID = restaurantId
+	err = h.restaurants.UpdateTable(r.Context(), table)
+	if errors.Is(err, repository.ErrNotFound) {
+		h.logger.Printf("UpdateTableHandler: Table not found, ID: %v", tableId)
+		apierror.NotFound(w, r, "Table not found")
+		return
+	}
+	if err != nil {
+		h.logger.Printf("UpdateTableHandler: Error updating table: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	h.logger.Printf("UpdateTableHandler: Table updated, ID: %v", tableId)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteTableHandler removes a table from a restaurant's inventory
+func (h *RestaurantHandler) DeleteTableHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	restaurantId, err := primitive.ObjectIDFromHex(params["restaurantId"])
+	if err != nil {
+		h.logger.Printf("DeleteTableHandler: Error parsing restaurant ID: %v", err)
+		apierror.InvalidID(w, r, "restaurantId")
+		return
+	}
+
+	claims, _ := auth.FromContext(r.Context())
+	if !policy.CanActAsRestaurant(claims, restaurantId) {
+		h.logger.Printf("DeleteTableHandler: Forbidden access to restaurant: %v", restaurantId)
+		apierror.Forbidden(w, r, "You do not have access to this resource")
+		return
+	}
+
+	tableId, err := primitive.ObjectIDFromHex(params["tableId"])
+	if err != nil {
+		h.logger.Printf("DeleteTableHandler: Error parsing table ID: %v", err)
+		apierror.InvalidID(w, r, "tableId")
+		return
+	}
+
+	err = h.restaurants.DeleteTable(r.Context(), restaurantId, tableId)
+	if errors.Is(err, repository.ErrNotFound) {
+		h.logger.Printf("DeleteTableHandler: Table not found, ID: %v", tableId)
+		apierror.NotFound(w, r, "Table not found")
+		return
+	}
+	if err != nil {
+		h.logger.Printf("DeleteTableHandler: Error deleting table: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	h.logger.Printf("DeleteTableHandler: Table deleted, ID: %v", tableId)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,168 +1,314 @@
 package handlers
 
 import (
+	"book-and-rate/pkg/apierror"
 	"book-and-rate/pkg/auth"
-	"book-and-rate/pkg/config"
-	"book-and-rate/pkg/db"
+	"book-and-rate/pkg/dto"
 	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/phone"
+	"book-and-rate/pkg/policy"
+	"book-and-rate/pkg/repository"
 	"book-and-rate/pkg/utils"
-	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// UserHandler serves the user accounts
+type UserHandler struct {
+	users  repository.UserRepository
+	tokens repository.TokenRepository
+	signer *auth.TokenService
+	hasher *utils.PasswordHasher
+	logger *log.Logger
+}
+
+func NewUserHandler(users repository.UserRepository, tokens repository.TokenRepository, signer *auth.TokenService, hasher *utils.PasswordHasher, logger *log.Logger) *UserHandler {
+	return &UserHandler{users: users, tokens: tokens, signer: signer, hasher: hasher, logger: logger}
+}
+
 // CreateUserHandler handles the creation of a new user
-func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		log.Printf("CreateUserHandler: Error decoding user data: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+func (h *UserHandler) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var request dto.UserRequest
+	if err := decodeRequest(r, &request); err != nil {
+		h.logger.Printf("CreateUserHandler: Error decoding user data: %v", err)
+		apierror.Invalid(w, r, err)
 		return
 	}
+	user := request.Model()
 
-	hashedPassword, err := utils.HashPassword(user.Password)
+	phoneNumber, err := phone.Normalize(user.PhoneNumber)
 	if err != nil {
-		log.Printf("CreateUserHandler: Error hashing password: %v", err)
-		http.Error(w, "Error processing password", http.StatusInternalServerError)
+		h.logger.Printf("CreateUserHandler: Invalid phone number: %v", err)
+		invalidPhone(w, r, "phoneNumber", err)
 		return
 	}
-	user.Password = hashedPassword
+	user.PhoneNumber = phoneNumber
 
-	result, err := db.UserCollection.InsertOne(context.Background(), user)
+	hashedPassword, err := h.hasher.Hash(user.Password)
 	if err != nil {
-		log.Printf("CreateUserHandler: Error inserting new user: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.logger.Printf("CreateUserHandler: Error hashing password: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+	user.Password = hashedPassword
+
+	if err := h.users.Create(r.Context(), &user); err != nil {
+		h.logger.Printf("CreateUserHandler: Error inserting new user: %v", err)
+		if errors.Is(err, repository.ErrDuplicate) {
+			apierror.Conflict(w, r, errPhoneTaken.Error())
+			return
+		}
+		apierror.Internal(w, r, err)
 		return
 	}
 
-	log.Printf("CreateUserHandler: User created successfully: %v", result.InsertedID)
-	json.NewEncoder(w).Encode(result)
+	h.logger.Printf("CreateUserHandler: User created successfully: %v", user.ID)
+	writeCreated(w, "/users/"+user.ID.Hex(), dto.NewUserResponse(user))
 }
 
 // GetUserHandler retrieves a user by ID
-func GetUserHandler(w http.ResponseWriter, r *http.Request) {
+func (h *UserHandler) GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userId, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		h.logger.Printf("GetUserHandler: Error parsing ID: %v", err)
+		apierror.InvalidID(w, r, "id")
+		return
+	}
+
+	claims, _ := auth.FromContext(r.Context())
+	if !policy.CanActAsUser(claims, userId) {
+		h.logger.Printf("GetUserHandler: Forbidden access to user: %v", userId)
+		apierror.Forbidden(w, r, "You do not have access to this resource")
+		return
+	}
+
+	user, err := h.users.FindByID(r.Context(), userId)
+	if err != nil {
+		h.logger.Printf("GetUserHandler: Error finding user: %v", err)
+		apierror.Lookup(w, r, err, "User not found")
+		return
+	}
+
+	h.logger.Printf("GetUserHandler: User retrieved: %v", userId)
+	json.NewEncoder(w).Encode(dto.NewUserResponse(user))
+}
+
+// UpdateUserHandler replaces a user's details, an empty password keeps the current one
+func (h *UserHandler) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	userId, err := primitive.ObjectIDFromHex(params["id"])
 	if err != nil {
-		log.Printf("GetUserHandler: Error parsing ID: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.logger.Printf("UpdateUserHandler: Error parsing ID: %v", err)
+		apierror.InvalidID(w, r, "id")
+		return
+	}
+
+	claims, _ := auth.FromContext(r.Context())
+	if !policy.CanActAsUser(claims, userId) {
+		h.logger.Printf("UpdateUserHandler: Forbidden access to user: %v", userId)
+		apierror.Forbidden(w, r, "You do not have access to this resource")
 		return
 	}
 
-	var user models.User
-	if err := db.UserCollection.FindOne(context.Background(), bson.M{"_id": userId}).Decode(&user); err != nil {
-		log.Printf("GetUserHandler: Error finding user: %v", err)
-		http.Error(w, err.Error(), http.StatusNotFound)
+	var request dto.UserUpdateRequest
+	if err := decodeRequest(r, &request); err != nil {
+		h.logger.Printf("UpdateUserHandler: Error decoding user: %v", err)
+		apierror.Invalid(w, r, err)
 		return
 	}
 
-	log.Printf("GetUserHandler: User retrieved: %v", userId)
-	json.NewEncoder(w).Encode(user)
+	existing, err := h.users.FindByID(r.Context(), userId)
+	if err != nil {
+		h.logger.Printf("UpdateUserHandler: Error finding user: %v", err)
+		apierror.Lookup(w, r, err, "User not found")
+		return
+	}
+
+	h.saveUser(w, r, "UpdateUserHandler", existing, request.Model(), request.Password)
 }
 
-// UpdateUserHandler updates a user's details
-func UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
+// PatchUserHandler changes only the user details present in the request
+func (h *UserHandler) PatchUserHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	userId, err := primitive.ObjectIDFromHex(params["id"])
 	if err != nil {
-		log.Printf("UpdateUserHandler: Error parsing ID: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.logger.Printf("PatchUserHandler: Error parsing ID: %v", err)
+		apierror.InvalidID(w, r, "id")
+		return
+	}
+
+	claims, _ := auth.FromContext(r.Context())
+	if !policy.CanActAsUser(claims, userId) {
+		h.logger.Printf("PatchUserHandler: Forbidden access to user: %v", userId)
+		apierror.Forbidden(w, r, "You do not have access to this resource")
+		return
+	}
+
+	var request dto.UserPatch
+	if err := decodePatch(r, &request); err != nil {
+		h.logger.Printf("PatchUserHandler: Invalid user patch: %v", err)
+		apierror.Invalid(w, r, err)
+		return
+	}
+
+	existing, err := h.users.FindByID(r.Context(), userId)
+	if err != nil {
+		h.logger.Printf("PatchUserHandler: Error finding user: %v", err)
+		apierror.Lookup(w, r, err, "User not found")
 		return
 	}
 
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		log.Printf("UpdateUserHandler: Error decoding user: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	user := existing
+	request.Apply(&user)
+	var password string
+	if request.Password != nil {
+		password = *request.Password
+	}
+	h.saveUser(w, r, "PatchUserHandler", existing, user, password)
+}
+
+// saveUser writes the updated details over the existing user, hashing the password and ending the sessions when a new one was given
+func (h *UserHandler) saveUser(w http.ResponseWriter, r *http.Request, handlerName string, existing, user models.User, password string) {
+	phoneNumber, err := phone.Normalize(user.PhoneNumber)
+	if err != nil {
+		h.logger.Printf("%s: Invalid phone number: %v", handlerName, err)
+		invalidPhone(w, r, "phoneNumber", err)
 		return
 	}
+	user.PhoneNumber = phoneNumber
 
-	if user.Password != "" {
-		hashedPassword, err := utils.HashPassword(user.Password)
+	// An empty password keeps the current one
+	user.Password = existing.Password
+	if password != "" {
+		hashedPassword, err := h.hasher.Hash(password)
 		if err != nil {
-			log.Printf("UpdateUserHandler: Error hashing password: %v", err)
-			http.Error(w, "Error processing password", http.StatusInternalServerError)
+			h.logger.Printf("%s: Error hashing password: %v", handlerName, err)
+			apierror.Internal(w, r, err)
 			return
 		}
 		user.Password = hashedPassword
 	}
 
-	_, err = db.UserCollection.UpdateOne(context.Background(), bson.M{"_id": userId}, bson.M{"$set": user})
-	if err != nil {
-		log.Printf("UpdateUserHandler: Error updating user: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// The number stays verified while it does not change
+	user.PhoneVerifiedAt = nil
+	if user.PhoneNumber == existing.PhoneNumber {
+		user.PhoneVerifiedAt = existing.PhoneVerifiedAt
+	}
+
+	user.ID = existing.ID
+	if err := h.users.Update(r.Context(), user); err != nil {
+		h.logger.Printf("%s: Error updating user: %v", handlerName, err)
+		if errors.Is(err, repository.ErrDuplicate) {
+			apierror.Conflict(w, r, errPhoneTaken.Error())
+			return
+		}
+		apierror.Internal(w, r, err)
 		return
 	}
 
-	log.Printf("UpdateUserHandler: User updated successfully: %v", userId)
+	// Whoever knew the old password may still hold tokens issued with it
+	if password != "" {
+		if err := h.tokens.RevokeUser(r.Context(), user.ID.Hex(), time.Now()); err != nil {
+			h.logger.Printf("%s: Error revoking refresh tokens: %v", handlerName, err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Password changed, but existing sessions could not be logged out")
+			return
+		}
+	}
+
+	h.logger.Printf("%s: User updated successfully: %v", handlerName, user.ID)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // DeleteUserHandler deletes a user by ID
-func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+func (h *UserHandler) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	userId, err := primitive.ObjectIDFromHex(params["id"])
 	if err != nil {
-		log.Printf("DeleteUserHandler: Error parsing ID: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.logger.Printf("DeleteUserHandler: Error parsing ID: %v", err)
+		apierror.InvalidID(w, r, "id")
 		return
 	}
 
-	_, err = db.UserCollection.DeleteOne(context.Background(), bson.M{"_id": userId})
-	if err != nil {
-		log.Printf("DeleteUserHandler: Error deleting user: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	claims, _ := auth.FromContext(r.Context())
+	if !policy.CanActAsUser(claims, userId) {
+		h.logger.Printf("DeleteUserHandler: Forbidden access to user: %v", userId)
+		apierror.Forbidden(w, r, "You do not have access to this resource")
 		return
 	}
 
-	log.Printf("DeleteUserHandler: User deleted successfully: %v", userId)
+	if err := h.users.Delete(r.Context(), userId); err != nil {
+		h.logger.Printf("DeleteUserHandler: Error deleting user: %v", err)
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.NotFound(w, r, "User not found")
+			return
+		}
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	// The sessions of the account end with it
+	if err := h.tokens.RevokeUser(r.Context(), userId.Hex(), time.Now()); err != nil {
+		h.logger.Printf("DeleteUserHandler: Error revoking tokens: %v", err)
+	}
+
+	h.logger.Printf("DeleteUserHandler: User deleted successfully: %v", userId)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // LoginUserHandler handles the login process for a user
-func LoginUserHandler(w http.ResponseWriter, r *http.Request) {
-	var loginDetails models.Login
-	if err := json.NewDecoder(r.Body).Decode(&loginDetails); err != nil {
-		log.Printf("LoginUserHandler: Error decoding login details: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+func (h *UserHandler) LoginUserHandler(w http.ResponseWriter, r *http.Request) {
+	var loginDetails dto.LoginRequest
+	if err := decodeRequest(r, &loginDetails); err != nil {
+		h.logger.Printf("LoginUserHandler: Error decoding login details: %v", err)
+		apierror.Invalid(w, r, err)
 		return
 	}
 
-	var user models.User
-	err := db.UserCollection.FindOne(context.Background(), bson.M{"phone": loginDetails.Phone}).Decode(&user)
+	// Numbers are stored normalized, so any formatting of the same number logs in
+	phoneNumber, err := phone.Normalize(loginDetails.Phone)
 	if err != nil {
-		log.Printf("LoginUserHandler: Error finding user: %v", err)
-		http.Error(w, "Invalid phone number or password", http.StatusUnauthorized)
+		h.logger.Printf("LoginUserHandler: Invalid phone number: %v", err)
+		apierror.Unauthorized(w, r, "Invalid phone number or password")
+		return
+	}
+
+	user, err := h.users.FindByPhone(r.Context(), phoneNumber)
+	if err != nil {
+		h.logger.Printf("LoginUserHandler: Error finding user: %v", err)
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Unauthorized(w, r, "Invalid phone number or password")
+			return
+		}
+		apierror.Internal(w, r, err)
 		return
 	}
 
 	if err = utils.ComparePasswords(user.Password, loginDetails.Password); err != nil {
-		log.Printf("LoginUserHandler: Password does not match: %v", err)
-		http.Error(w, "Invalid phone number or password", http.StatusUnauthorized)
+		h.logger.Printf("LoginUserHandler: Password does not match: %v", err)
+		apierror.Unauthorized(w, r, "Invalid phone number or password")
 		return
 	}
 
 	// Generate JWT Token
-	cfg := config.LoadConfig("./config/config.json")
-	accessToken, err := auth.GenerateToken(user.ID.Hex(), *cfg)
-	if err != nil {
-		log.Printf("LoginUserHandler: Error generating access token: %v", err)
-		http.Error(w, "Error generating access token", http.StatusInternalServerError)
-		return
+	principalType := auth.PrincipalUser
+	if h.signer.IsAdmin(user.ID.Hex()) {
+		principalType = auth.PrincipalAdmin
 	}
 
-	refreshToken, err := auth.GenerateRefreshToken(user.ID.Hex(), *cfg)
+	tokens, _, err := issueTokens(r.Context(), h.tokens, h.signer, user.ID.Hex(), principalType, "")
 	if err != nil {
-		log.Printf("LoginUserHandler: Error generating refresh token: %v", err)
-		http.Error(w, "Error generating refresh token", http.StatusInternalServerError)
+		h.logger.Printf("LoginUserHandler: Error generating tokens: %v", err)
+		apierror.Internal(w, r, err)
 		return
 	}
 
-	log.Printf("LoginUserHandler: User logged in successfully: %v", user.ID)
-	json.NewEncoder(w).Encode(map[string]string{"accessToken": accessToken, "refreshToken": refreshToken})
+	h.logger.Printf("LoginUserHandler: User logged in successfully: %v", user.ID)
+	json.NewEncoder(w).Encode(tokens)
 }
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"book-and-rate/pkg/apierror"
+	"book-and-rate/pkg/auth"
+	"book-and-rate/pkg/config"
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/notify"
+	"book-and-rate/pkg/policy"
+	"book-and-rate/pkg/repository"
+	"book-and-rate/pkg/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// verificationCodeDigits is the length of the codes texted to users
+const verificationCodeDigits = 6
+
+// VerificationHandler serves the phone number verification of the logged in user
+type VerificationHandler struct {
+	users         repository.UserRepository
+	verifications repository.VerificationRepository
+	notifier      notify.Notifier
+	config        config.VerificationConfig
+	logger        *log.Logger
+}
+
+func NewVerificationHandler(users repository.UserRepository, verifications repository.VerificationRepository, notifier notify.Notifier, cfg config.VerificationConfig, logger *log.Logger) *VerificationHandler {
+	return &VerificationHandler{users: users, verifications: verifications, notifier: notifier, config: cfg, logger: logger}
+}
+
+// StartVerificationHandler texts a new code to the phone number of the logged in user, replacing any code sent before
+// once the resend cooldown has passed and unless the user was already sent the most codes allowed in a day
+func (h *VerificationHandler) StartVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r, "StartVerificationHandler")
+	if !ok {
+		return
+	}
+
+	code, err := utils.GenerateCode(verificationCodeDigits)
+	if err != nil {
+		h.logger.Printf("StartVerificationHandler: Error generating code: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	verification := models.PhoneVerification{
+		UserID:    user.ID,
+		Phone:     user.PhoneNumber,
+		CodeHash:  utils.HashCode(code, user.ID.Hex()),
+		SentAt:    now,
+		ExpiresAt: now.Add(time.Duration(h.config.CodeLifetime)),
+	}
+	// The limits are checked in the same write that replaces the code, so concurrent requests cannot all pass them
+	limits := repository.SendLimits{Cooldown: time.Duration(h.config.ResendCooldown), MaxSends: h.config.MaxSendsPerDay}
+	err = h.verifications.Start(r.Context(), verification, limits)
+	if errors.Is(err, repository.ErrConflict) {
+		h.logger.Printf("StartVerificationHandler: Code requested again too soon for user %v", user.ID)
+		h.tooSoon(w, r, user.ID, limits, now)
+		return
+	}
+	if err != nil {
+		h.logger.Printf("StartVerificationHandler: Error saving verification: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	if err := h.notifier.VerificationCode(r.Context(), user.PhoneNumber, code, time.Duration(h.config.CodeLifetime)); err != nil {
+		h.logger.Printf("StartVerificationHandler: Error sending code: %v", err)
+		// The code never arrived, so it should not hold the user back until the cooldown passes
+		if err := h.verifications.Delete(r.Context(), user.ID); err != nil {
+			h.logger.Printf("StartVerificationHandler: Error deleting unsent verification: %v", err)
+		}
+		apierror.Upstream(w, r, "Failed to send the verification code")
+		return
+	}
+
+	h.logger.Printf("StartVerificationHandler: Verification code sent to user %v", user.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(dto.VerificationStartedResponse{
+		ExpiresAt: verification.ExpiresAt,
+		ResendAt:  h.resendAt(r, user.ID, limits, now),
+	})
+}
+
+// tooSoon refuses another code, telling the user when the next one can be sent
+func (h *VerificationHandler) tooSoon(w http.ResponseWriter, r *http.Request, userId primitive.ObjectID, limits repository.SendLimits, now time.Time) {
+	wait := h.resendAt(r, userId, limits, now).Sub(now)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(wait.Seconds())))))
+	apierror.TooManyRequests(w, r, "Too many codes were sent recently, wait before requesting another")
+}
+
+// resendAt is when the user may be sent the next code, a cooldown from now when the verification cannot be read
+func (h *VerificationHandler) resendAt(r *http.Request, userId primitive.ObjectID, limits repository.SendLimits, now time.Time) time.Time {
+	verification, err := h.verifications.FindByUser(r.Context(), userId)
+	if err != nil {
+		h.logger.Printf("StartVerificationHandler: Error finding verification: %v", err)
+		return now.Add(limits.Cooldown)
+	}
+	return verification.ResendAt(limits.Cooldown, limits.MaxSends)
+}
+
+// ConfirmVerificationHandler marks the phone number of the logged in user verified when the code matches
+func (h *VerificationHandler) ConfirmVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	var confirmation dto.VerificationConfirmRequest
+	if err := decodeRequest(r, &confirmation); err != nil {
+		h.logger.Printf("ConfirmVerificationHandler: Error decoding code: %v", err)
+		apierror.Invalid(w, r, err)
+		return
+	}
+
+	user, ok := h.currentUser(w, r, "ConfirmVerificationHandler")
+	if !ok {
+		return
+	}
+
+	// The attempt is counted before the code is compared, so concurrent guesses all count against the limit
+	verification, err := h.verifications.RecordAttempt(r.Context(), user.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		h.logger.Printf("ConfirmVerificationHandler: No verification in progress for user %v", user.ID)
+		apierror.BadRequest(w, r, "No code was sent, request one first")
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ConfirmVerificationHandler: Error recording attempt: %v", err)
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	switch {
+	case verification.Attempts > h.config.MaxAttempts:
+		h.logger.Printf("ConfirmVerificationHandler: Too many attempts for user %v", user.ID)
+		apierror.TooManyRequests(w, r, "Too many wrong codes, request a new one")
+		return
+	case !now.Before(verification.ExpiresAt):
+		h.logger.Printf("ConfirmVerificationHandler: Expired code for user %v", user.ID)
+		apierror.BadRequest(w, r, "The code has expired, request a new one")
+		return
+	case verification.Phone != user.PhoneNumber:
+		h.logger.Printf("ConfirmVerificationHandler: Phone number of user %v changed since the code was sent", user.ID)
+		apierror.BadRequest(w, r, "The phone number changed since the code was sent, request a new one")
+		return
+	case !utils.CompareCode(verification.CodeHash, confirmation.Code, user.ID.Hex()):
+		h.logger.Printf("ConfirmVerificationHandler: Wrong code for user %v", user.ID)
+		apierror.InvalidField(w, r, "code", "is invalid")
+		return
+	}
+
+	if err := h.users.MarkPhoneVerified(r.Context(), user.ID, verification.Phone, now); err != nil {
+		h.logger.Printf("ConfirmVerificationHandler: Error marking phone verified: %v", err)
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Conflict(w, r, "The phone number changed since the code was sent, request a new one")
+			return
+		}
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	// A confirmed code must not be accepted twice
+	if err := h.verifications.Delete(r.Context(), user.ID); err != nil {
+		h.logger.Printf("ConfirmVerificationHandler: Error deleting verification: %v", err)
+	}
+
+	h.logger.Printf("ConfirmVerificationHandler: Phone number verified for user %v", user.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// currentUser loads the user the request is authenticated as, writing the error response when it cannot.
+// Verifying a number that is already verified is reported as a conflict.
+func (h *VerificationHandler) currentUser(w http.ResponseWriter, r *http.Request, handlerName string) (models.User, bool) {
+	var userId primitive.ObjectID
+	claims, ok := auth.FromContext(r.Context())
+	if ok {
+		userId, _ = primitive.ObjectIDFromHex(claims.UserId)
+	}
+	if !policy.CanActAsUser(claims, userId) || userId.IsZero() {
+		h.logger.Printf("%s: Only users verify a phone number", handlerName)
+		apierror.Forbidden(w, r, "You do not have access to this resource")
+		return models.User{}, false
+	}
+
+	user, err := h.users.FindByID(r.Context(), userId)
+	if err != nil {
+		h.logger.Printf("%s: Error finding user: %v", handlerName, err)
+		apierror.Lookup(w, r, err, "User not found")
+		return models.User{}, false
+	}
+
+	if user.PhoneVerified() {
+		h.logger.Printf("%s: Phone number of user %v is already verified", handlerName, userId)
+		apierror.Conflict(w, r, "The phone number is already verified")
+		return models.User{}, false
+	}
+	return user, true
+}
@@ -1,27 +1,53 @@
 package middleware
 
 import (
+	"book-and-rate/pkg/apierror"
 	"book-and-rate/pkg/auth"
-	"book-and-rate/pkg/config"
+	"book-and-rate/pkg/policy"
 	"net/http"
 	"strings"
 )
 
-// AuthenticationMiddleware verifies the JWT token
-func AuthenticationMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tokenString := r.Header.Get("Authorization")
-		if tokenString == "" {
-			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
-			return
-		}
+// AuthenticationMiddleware verifies the JWT token and stores its claims in the request context
+func AuthenticationMiddleware(tokens *auth.TokenService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString := r.Header.Get("Authorization")
+			if tokenString == "" {
+				apierror.Unauthorized(w, r, "Authorization header is required")
+				return
+			}
+
+			tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+
+			token, err := tokens.ValidateToken(tokenString)
+			if err != nil || !token.Valid {
+				apierror.Unauthorized(w, r, "Invalid or expired token")
+				return
+			}
 
-		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+			claims, ok := token.Claims.(*auth.Claims)
+			if !ok {
+				apierror.Unauthorized(w, r, "Failed to parse token claims")
+				return
+			}
 
-		cfg := config.LoadConfig("./config/config.json")
-		token, err := auth.ValidateToken(tokenString, *cfg)
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+			if claims.Use != auth.UseAccess {
+				apierror.Unauthorized(w, r, "An access token is required")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), claims)))
+		})
+	}
+}
+
+// AdminMiddleware only lets administrators through, it must run after AuthenticationMiddleware
+func AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := auth.FromContext(r.Context())
+		if !policy.IsAdmin(claims) {
+			apierror.Forbidden(w, r, "Administrator access is required")
 			return
 		}
 
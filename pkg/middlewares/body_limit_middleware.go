@@ -0,0 +1,15 @@
+package middleware
+
+import "net/http"
+
+// BodyLimitMiddleware caps the size of request bodies, reading past the limit fails with *http.MaxBytesError
+func BodyLimitMiddleware(limit int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, int64(limit))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
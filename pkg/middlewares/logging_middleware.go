@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"book-and-rate/pkg/requestid"
+	"log"
+	"net/http"
+	"time"
+)
+
+// statusRecorder remembers the status code written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// LoggingMiddleware logs the method, path, status, duration and request ID of every request except those to the skipped paths
+func LoggingMiddleware(logger *log.Logger, skip ...string) func(http.Handler) http.Handler {
+	skipped := make(map[string]bool, len(skip))
+	for _, path := range skip {
+		skipped[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skipped[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+			logger.Printf("%s %s %d %v %s", r.Method, r.URL.Path, recorder.status, time.Since(start), requestid.FromContext(r.Context()))
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"book-and-rate/pkg/requestid"
+	"net/http"
+)
+
+// RequestIDMiddleware gives every request an ID, keeping a well-formed one sent by the client, and returns it in the response
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
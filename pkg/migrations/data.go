@@ -0,0 +1,154 @@
+package migrations
+
+import (
+	"book-and-rate/pkg/db"
+	"book-and-rate/pkg/models"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migrationActor is the actor type of the status changes made by migrations
+const migrationActor = "migration"
+
+var cancelledStatuses = bson.A{models.BookingCancelledByGuest, models.BookingCancelledByRestaurant}
+
+// legacyBooking is what the status migrations read of a booking written before the lifecycle state machine.
+// Bookings made before tables were allocated have no end date.
+type legacyBooking struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Date      time.Time          `bson:"date"`
+	EndDate   time.Time          `bson:"endDate"`
+	Cancelled bool               `bson:"cancelled"`
+}
+
+// status is the state the booking was in at the time: the legacy flag does not say who cancelled,
+// so cancelled bookings are attributed to the guest; the others are completed when they were over and confirmed otherwise
+func (b legacyBooking) status(at time.Time) models.BookingStatus {
+	end := b.EndDate
+	if end.IsZero() {
+		end = b.Date.Add(models.DefaultBookingDuration)
+	}
+	switch {
+	case b.Cancelled:
+		return models.BookingCancelledByGuest
+	case !end.After(at):
+		return models.BookingCompleted
+	default:
+		return models.BookingConfirmed
+	}
+}
+
+// bookingStatusFromCancelled gives the bookings written before the lifecycle state machine a status
+var bookingStatusFromCancelled = Migration{
+	Version: 3,
+	Name:    "booking status from the legacy cancelled flag",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		bookings := database.Collection(db.BookingCollection)
+		now := time.Now()
+
+		cursor, err := bookings.Find(ctx, bson.M{"status": bson.M{"$exists": false}})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		byStatus := make(map[models.BookingStatus][]primitive.ObjectID)
+		for cursor.Next(ctx) {
+			var booking legacyBooking
+			if err := cursor.Decode(&booking); err != nil {
+				return err
+			}
+			status := booking.status(now)
+			byStatus[status] = append(byStatus[status], booking.ID)
+		}
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+
+		for status, ids := range byStatus {
+			_, err := bookings.UpdateMany(ctx,
+				bson.M{"_id": bson.M{"$in": ids}, "status": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{
+					"status":  status,
+					"history": []models.StatusChange{{To: status, At: now, ActorType: migrationActor}},
+				}},
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = bookings.UpdateMany(ctx, bson.M{"cancelled": bson.M{"$exists": true}}, bson.M{"$unset": bson.M{"cancelled": ""}})
+		return err
+	},
+	// Down restores the flag for older releases and keeps the status, which they ignore
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		bookings := database.Collection(db.BookingCollection)
+		if _, err := bookings.UpdateMany(ctx, bson.M{"status": bson.M{"$in": cancelledStatuses}}, bson.M{"$set": bson.M{"cancelled": true}}); err != nil {
+			return err
+		}
+		_, err := bookings.UpdateMany(ctx, bson.M{"status": bson.M{"$nin": cancelledStatuses}}, bson.M{"$set": bson.M{"cancelled": false}})
+		return err
+	},
+}
+
+// ratingSummaryBackfill computes the rating summary of every rated restaurant from its rates,
+// so the restaurants rated before the summary was maintained sort and display correctly
+var ratingSummaryBackfill = Migration{
+	Version: 4,
+	Name:    "rating summary backfill",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		cursor, err := database.Collection(db.RateCollection).Find(ctx, bson.M{})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		summaries := make(ratingSummaries)
+		for cursor.Next(ctx) {
+			var rate models.Rate
+			if err := cursor.Decode(&rate); err != nil {
+				return err
+			}
+			summaries.add(rate)
+		}
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+
+		// Replacing the whole summary makes a rerun after a failure land on the same result
+		restaurants := database.Collection(db.RestaurantCollection)
+		for restaurantId, summary := range summaries {
+			if _, err := restaurants.UpdateOne(ctx, bson.M{"_id": restaurantId}, bson.M{"$set": bson.M{"ratingSummary": summary}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// Down has nothing to revert, the summary is maintained by the rate handlers from then on
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		return nil
+	},
+}
+
+// ratingSummaries are the rating summaries of the restaurants by id
+type ratingSummaries map[primitive.ObjectID]*models.RatingSummary
+
+// add counts the rate towards the summary of its restaurant. Legacy rates outside the range the handlers accept
+// are left out, they would add a histogram bucket of their own and skew the mean.
+func (s ratingSummaries) add(rate models.Rate) {
+	if rate.Rating < models.MinRating || rate.Rating > models.MaxRating {
+		return
+	}
+	summary, ok := s[rate.RestaurantID]
+	if !ok {
+		summary = &models.RatingSummary{}
+		s[rate.RestaurantID] = summary
+	}
+	date := rate.Date
+	summary.Apply(rate.Rating, 0, &date)
+}
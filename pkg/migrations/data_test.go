@@ -0,0 +1,78 @@
+package migrations
+
+import (
+	"book-and-rate/pkg/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestLegacyBookingStatus(t *testing.T) {
+	now := time.Date(2024, time.May, 1, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		document bson.M
+		want     models.BookingStatus
+	}{
+		{"cancelled", bson.M{"date": now.AddDate(0, 0, -1), "cancelled": true}, models.BookingCancelledByGuest},
+		{"over without an end date", bson.M{"date": now.AddDate(0, 0, -1), "cancelled": false}, models.BookingCompleted},
+		{"under way without an end date", bson.M{"date": now.Add(-time.Hour)}, models.BookingConfirmed},
+		{"upcoming without an end date", bson.M{"date": now.AddDate(0, 0, 1)}, models.BookingConfirmed},
+		{"over by its end date", bson.M{"date": now.Add(-3 * time.Hour), "endDate": now.Add(-time.Hour)}, models.BookingCompleted},
+		{"running past its default duration", bson.M{"date": now.Add(-3 * time.Hour), "endDate": now.Add(time.Hour)}, models.BookingConfirmed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.document["_id"] = primitive.NewObjectID()
+			raw, err := bson.Marshal(tt.document)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var booking legacyBooking
+			if err := bson.Unmarshal(raw, &booking); err != nil {
+				t.Fatal(err)
+			}
+			if got := booking.status(now); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRatingSummaryBackfill(t *testing.T) {
+	first, second, outOfRange := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	day := time.Date(2024, time.May, 1, 20, 0, 0, 0, time.UTC)
+	rates := []models.Rate{
+		{RestaurantID: first, Rating: 5, Date: day},
+		{RestaurantID: first, Rating: 2, Date: day.AddDate(0, 0, 2)},
+		{RestaurantID: first, Rating: 0, Date: day.AddDate(0, 0, 3)},
+		{RestaurantID: first, Rating: 7, Date: day.AddDate(0, 0, 4)},
+		{RestaurantID: second, Rating: 4, Date: day.AddDate(0, 0, 1)},
+		{RestaurantID: outOfRange, Rating: -1, Date: day},
+	}
+
+	summaries := make(ratingSummaries)
+	for _, rate := range rates {
+		summaries.add(rate)
+	}
+
+	summary := summaries[first]
+	if summary == nil || summary.Count != 2 || summary.Sum != 7 || summary.Mean != 3.5 {
+		t.Fatalf("expected 2 ratings averaging 3.5, got %+v", summary)
+	}
+	if len(summary.Histogram) != 2 || summary.Histogram["5"] != 1 || summary.Histogram["2"] != 1 {
+		t.Errorf("expected only the 5 and 2 buckets, got %v", summary.Histogram)
+	}
+	if !summary.LastRatedAt.Equal(day.AddDate(0, 0, 2)) {
+		t.Errorf("expected the last rating in range to be the latest, got %v", summary.LastRatedAt)
+	}
+	if summary := summaries[second]; summary == nil || summary.Count != 1 || summary.Mean != 4 {
+		t.Errorf("expected a single rating of 4, got %+v", summary)
+	}
+	if _, ok := summaries[outOfRange]; ok {
+		t.Error("expected no summary for a restaurant without ratings in range")
+	}
+}
@@ -0,0 +1,201 @@
+package migrations
+
+import (
+	"book-and-rate/pkg/db"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// index is an index of a collection, named so Down can drop it again
+type index struct {
+	collection string
+	name       string
+	keys       bson.D
+	unique     bool
+	// expires makes a TTL index, documents are removed once the indexed date has passed
+	expires bool
+	// partial limits the index to the documents matching the filter
+	partial bson.M
+}
+
+var phoneIndexes = []index{
+	{collection: db.UserCollection, name: "phoneNumber_unique", keys: bson.D{{Key: "phoneNumber", Value: 1}}, unique: true},
+	{collection: db.RestaurantCollection, name: "phone_unique", keys: bson.D{{Key: "phone", Value: 1}}, unique: true},
+}
+
+var uniquePhoneIndexes = Migration{
+	Version: 1,
+	Name:    "unique phone indexes on users and restaurants",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		// Report every duplicate at once rather than the first one the index build trips over
+		var problems []string
+		for _, idx := range phoneIndexes {
+			field := idx.keys[0].Key
+			duplicates, err := duplicateValues(ctx, database.Collection(idx.collection), field, bson.M{})
+			if err != nil {
+				return err
+			}
+			if len(duplicates) > 0 {
+				problems = append(problems, fmt.Sprintf("%s share %s values %s", idx.collection, field, strings.Join(duplicates, ", ")))
+			}
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("resolve the duplicates first: %s", strings.Join(problems, "; "))
+		}
+		return createIndexes(ctx, database, phoneIndexes)
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		return dropIndexes(ctx, database, phoneIndexes)
+	},
+}
+
+// queryIndexes back the lookups and filters of the repositories, which were collection scans before.
+// The bookingId index of the rates is unique, so concurrent ratings of a booking cannot both be stored;
+// it stops with the bookings rated more than once, keep one rate of each first.
+var queryIndexes = Migration{
+	Version: 2,
+	Name:    "indexes for booking, rate, table and refresh token queries",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		duplicates, err := duplicateValues(ctx, database.Collection(db.RateCollection), "bookingId", bson.M{"bookingId": bson.M{"$exists": true}})
+		if err != nil {
+			return err
+		}
+		if len(duplicates) > 0 {
+			return fmt.Errorf("keep one rate of each of these bookings first: %s", strings.Join(duplicates, ", "))
+		}
+		return createIndexes(ctx, database, lookupIndexes)
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		return dropIndexes(ctx, database, lookupIndexes)
+	},
+}
+
+var lookupIndexes = []index{
+	{collection: db.BookingCollection, name: "restaurantId_date", keys: bson.D{{Key: "restaurantId", Value: 1}, {Key: "date", Value: 1}}},
+	{collection: db.BookingCollection, name: "userId_date", keys: bson.D{{Key: "userId", Value: 1}, {Key: "date", Value: 1}}},
+	{collection: db.RateCollection, name: "restaurantId_date", keys: bson.D{{Key: "restaurantId", Value: 1}, {Key: "date", Value: -1}}},
+	// Rates written before they were tied to bookings have none, they are left out of the index
+	{
+		collection: db.RateCollection,
+		name:       "bookingId_unique",
+		keys:       bson.D{{Key: "bookingId", Value: 1}},
+		unique:     true,
+		partial:    bson.M{"bookingId": bson.M{"$exists": true}},
+	},
+	{collection: db.RateCollection, name: "date", keys: bson.D{{Key: "date", Value: -1}}},
+	{collection: db.TableCollection, name: "restaurantId", keys: bson.D{{Key: "restaurantId", Value: 1}}},
+	{collection: db.RefreshTokenCollection, name: "jti_unique", keys: bson.D{{Key: "jti", Value: 1}}, unique: true},
+	{collection: db.RefreshTokenCollection, name: "familyId", keys: bson.D{{Key: "familyId", Value: 1}}},
+	{collection: db.RefreshTokenCollection, name: "userId", keys: bson.D{{Key: "userId", Value: 1}}},
+	{collection: db.RefreshTokenCollection, name: "expiresAt", keys: bson.D{{Key: "expiresAt", Value: 1}}},
+}
+
+// expiringVerifications lets MongoDB delete phone verifications once the sends they count towards the daily cap
+// no longer count, rather than with their code. Verifications written before the cap are kept until their code expires.
+var expiringVerifications = Migration{
+	Version: 6,
+	Name:    "expire phone verifications after their send window",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		_, err := database.Collection(db.PhoneVerificationCollection).UpdateMany(ctx,
+			bson.M{"purgeAt": bson.M{"$exists": false}},
+			bson.A{bson.M{"$set": bson.M{"purgeAt": "$expiresAt"}}},
+		)
+		if err != nil {
+			return err
+		}
+		return createIndexes(ctx, database, verificationIndexes)
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		return dropIndexes(ctx, database, verificationIndexes)
+	},
+}
+
+var verificationIndexes = []index{
+	{collection: db.PhoneVerificationCollection, name: "purgeAt_ttl", keys: bson.D{{Key: "purgeAt", Value: 1}}, expires: true},
+}
+
+// passwordResetIndexes look reset tokens up by hash and by account, and let MongoDB delete them once they expire
+var passwordResetIndexes = Migration{
+	Version: 7,
+	Name:    "indexes for password reset tokens",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		return createIndexes(ctx, database, resetIndexes)
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		return dropIndexes(ctx, database, resetIndexes)
+	},
+}
+
+var resetIndexes = []index{
+	{collection: db.PasswordResetCollection, name: "tokenHash_unique", keys: bson.D{{Key: "tokenHash", Value: 1}}, unique: true},
+	{collection: db.PasswordResetCollection, name: "principalType_accountId_createdAt", keys: bson.D{{Key: "principalType", Value: 1}, {Key: "accountId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	{collection: db.PasswordResetCollection, name: "expiresAt_ttl", keys: bson.D{{Key: "expiresAt", Value: 1}}, expires: true},
+}
+
+// createIndexes relies on createIndexes being a no-op for an identical index that already exists
+func createIndexes(ctx context.Context, database *mongo.Database, indexes []index) error {
+	for _, idx := range indexes {
+		model := mongo.IndexModel{Keys: idx.keys, Options: options.Index().SetName(idx.name)}
+		if idx.unique {
+			model.Options.SetUnique(true)
+		}
+		if idx.expires {
+			model.Options.SetExpireAfterSeconds(0)
+		}
+		if idx.partial != nil {
+			model.Options.SetPartialFilterExpression(idx.partial)
+		}
+		if _, err := database.Collection(idx.collection).Indexes().CreateOne(ctx, model); err != nil {
+			return fmt.Errorf("creating index %s of %s: %w", idx.name, idx.collection, err)
+		}
+	}
+	return nil
+}
+
+// indexNotFound is the server error code for dropping an index that does not exist
+const indexNotFound = 27
+
+func dropIndexes(ctx context.Context, database *mongo.Database, indexes []index) error {
+	for _, idx := range indexes {
+		_, err := database.Collection(idx.collection).Indexes().DropOne(ctx, idx.name)
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) && serverErr.HasErrorCode(indexNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("dropping index %s of %s: %w", idx.name, idx.collection, err)
+		}
+	}
+	return nil
+}
+
+// duplicateValues returns the values of field held by more than one of the documents of the collection matching filter
+func duplicateValues(ctx context.Context, collection *mongo.Collection, field string, filter bson.M) ([]string, error) {
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []struct {
+		Value interface{} `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	duplicates := make([]string, 0, len(groups))
+	for _, group := range groups {
+		duplicates = append(duplicates, fmt.Sprintf("%q", fmt.Sprint(group.Value)))
+	}
+	return duplicates, nil
+}
@@ -0,0 +1,166 @@
+package migrations
+
+import (
+	"book-and-rate/pkg/db"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is a versioned change to the database. Up applies it and Down reverts it,
+// both must be safe to run again after a failure halfway through.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, database *mongo.Database) error
+	Down    func(ctx context.Context, database *mongo.Database) error
+}
+
+// all lists the migrations in the order they are applied, versions only ever grow
+var all = []Migration{
+	uniquePhoneIndexes,
+	queryIndexes,
+	bookingStatusFromCancelled,
+	ratingSummaryBackfill,
+	normalizedPhones,
+	expiringVerifications,
+	passwordResetIndexes,
+	tableReservations,
+}
+
+// All returns the known migrations in version order
+func All() []Migration {
+	return append([]Migration(nil), all...)
+}
+
+// record is the document stored in the migrations collection for every applied migration
+type record struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+// Status tells whether a migration has been applied, and when
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies and reverts the migrations of a database.
+// It does not lock, so only one migrate command should run against a database at a time.
+type Migrator struct {
+	database   *mongo.Database
+	migrations []Migration
+}
+
+func NewMigrator(database *mongo.Database) *Migrator {
+	return &Migrator{database: database, migrations: all}
+}
+
+func (m *Migrator) collection() *mongo.Collection {
+	return m.database.Collection(db.MigrationCollection)
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]record, error) {
+	cursor, err := m.collection().Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var records []record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// Status lists every known migration with the time it was applied, nil when it is pending
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if r, ok := applied[migration.Version]; ok {
+			appliedAt := r.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies the pending migrations in version order and returns those it applied.
+// It stops at the first failure, the migrations before it stay applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range pending {
+		if err := migration.Up(ctx, m.database); err != nil {
+			return done, fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+
+		r := record{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}
+		_, err := m.collection().ReplaceOne(ctx, bson.M{"_id": r.Version}, r, options.Replace().SetUpsert(true))
+		if err != nil {
+			return done, fmt.Errorf("recording migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts the most recently applied migration and returns it, or nil when none is applied
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		if err := migration.Down(ctx, m.database); err != nil {
+			return nil, fmt.Errorf("reverting migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		if _, err := m.collection().DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+			return nil, fmt.Errorf("unrecording migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		return &migration, nil
+	}
+	return nil, nil
+}
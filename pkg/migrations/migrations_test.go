@@ -0,0 +1,31 @@
+package migrations
+
+import "testing"
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	previous := 0
+	for _, migration := range All() {
+		if migration.Version <= previous {
+			t.Errorf("migration %q has version %d, after version %d", migration.Name, migration.Version, previous)
+		}
+		if migration.Name == "" || migration.Up == nil || migration.Down == nil {
+			t.Errorf("migration %d is incomplete", migration.Version)
+		}
+		previous = migration.Version
+	}
+}
+
+func TestIndexNamesAreUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	var indexes []index
+	for _, group := range [][]index{phoneIndexes, lookupIndexes, userPhoneIndex, verificationIndexes, resetIndexes, reservationIndexes} {
+		indexes = append(indexes, group...)
+	}
+	for _, idx := range indexes {
+		key := idx.collection + "." + idx.name
+		if seen[key] {
+			t.Errorf("index %s is defined twice", key)
+		}
+		seen[key] = true
+	}
+}
@@ -0,0 +1,123 @@
+package migrations
+
+import (
+	"book-and-rate/pkg/db"
+	"book-and-rate/pkg/phone"
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	userPhoneNumberIndex = []index{phoneIndexes[0]}
+	userPhoneIndex       = []index{{collection: db.UserCollection, name: "phone_unique", keys: bson.D{{Key: "phone", Value: 1}}, unique: true}}
+)
+
+// normalizedPhones stores every phone number in E.164 and moves the number of the users from phoneNumber
+// to phone, the field the restaurants already use. It changes nothing when a number cannot be normalized
+// or two accounts end up with the same number, and lists them so they can be fixed by hand.
+var normalizedPhones = Migration{
+	Version: 5,
+	Name:    "E.164 phone numbers, users keyed by phone",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		users, err := normalize(ctx, database.Collection(db.UserCollection), "phoneNumber", "phone")
+		if err != nil {
+			return err
+		}
+		restaurants, err := normalize(ctx, database.Collection(db.RestaurantCollection), "phone")
+		if err != nil {
+			return err
+		}
+		if problems := append(users.problems, restaurants.problems...); len(problems) > 0 {
+			return fmt.Errorf("fix these phone numbers first: %s", strings.Join(problems, "; "))
+		}
+
+		// The old index would reject every user without phoneNumber as a duplicate of null
+		if err := dropIndexes(ctx, database, userPhoneNumberIndex); err != nil {
+			return err
+		}
+		if err := users.write(ctx, "phoneNumber"); err != nil {
+			return err
+		}
+		if err := restaurants.write(ctx, ""); err != nil {
+			return err
+		}
+		return createIndexes(ctx, database, userPhoneIndex)
+	},
+	// Down moves the user numbers back to phoneNumber, they stay normalized
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		if err := dropIndexes(ctx, database, userPhoneIndex); err != nil {
+			return err
+		}
+		users := database.Collection(db.UserCollection)
+		if _, err := users.UpdateMany(ctx, bson.M{"phone": bson.M{"$exists": true}}, bson.M{"$rename": bson.M{"phone": "phoneNumber"}}); err != nil {
+			return err
+		}
+		return createIndexes(ctx, database, userPhoneNumberIndex)
+	},
+}
+
+// normalization is the new phone number of every document of a collection
+type normalization struct {
+	collection *mongo.Collection
+	phones     map[primitive.ObjectID]string
+	problems   []string
+}
+
+// normalize reads the number of every document from the first of the fields it has
+func normalize(ctx context.Context, collection *mongo.Collection, fields ...string) (*normalization, error) {
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	n := &normalization{collection: collection, phones: make(map[primitive.ObjectID]string)}
+	owners := make(map[string]primitive.ObjectID)
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		id, _ := doc["_id"].(primitive.ObjectID)
+
+		var raw string
+		for _, field := range fields {
+			if value, ok := doc[field].(string); ok && value != "" {
+				raw = value
+				break
+			}
+		}
+
+		normalized, err := phone.Normalize(raw)
+		if err != nil {
+			n.problems = append(n.problems, fmt.Sprintf("%s %s has %q: %v", collection.Name(), id.Hex(), raw, err))
+			continue
+		}
+		if owner, ok := owners[normalized]; ok {
+			n.problems = append(n.problems, fmt.Sprintf("%s %s and %s share %s", collection.Name(), owner.Hex(), id.Hex(), normalized))
+			continue
+		}
+		owners[normalized] = id
+		n.phones[id] = normalized
+	}
+	return n, cursor.Err()
+}
+
+// write stores the normalized numbers in phone and removes the legacy field, if any
+func (n *normalization) write(ctx context.Context, legacyField string) error {
+	for id, number := range n.phones {
+		update := bson.M{"$set": bson.M{"phone": number}}
+		if legacyField != "" {
+			update["$unset"] = bson.M{legacyField: ""}
+		}
+		if _, err := n.collection.UpdateOne(ctx, bson.M{"_id": id}, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"book-and-rate/pkg/db"
+	"book-and-rate/pkg/models"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var reservationIndexes = []index{
+	{collection: db.TableReservationCollection, name: "bookingId", keys: bson.D{{Key: "bookingId", Value: 1}}},
+	{collection: db.TableReservationCollection, name: "expiresAt_ttl", keys: bson.D{{Key: "expiresAt", Value: 1}}, expires: true},
+}
+
+// tableReservations gives every booking an end date and the active ones the table reservations that keep two bookings
+// from holding the same table. It stops with the bookings that already share a table, they have to be moved or
+// cancelled by hand before it can be applied.
+var tableReservations = Migration{
+	Version: 8,
+	Name:    "booking end dates and table reservations",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		bookings := database.Collection(db.BookingCollection)
+		_, err := bookings.UpdateMany(ctx, bson.M{"endDate": nil}, mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"endDate": bson.M{"$add": bson.A{"$date", models.DefaultBookingDuration.Milliseconds()}}}}},
+		})
+		if err != nil {
+			return err
+		}
+		if err := createIndexes(ctx, database, reservationIndexes); err != nil {
+			return err
+		}
+
+		cursor, err := bookings.Find(ctx, bson.M{"status": bson.M{"$in": models.ActiveBookingStatuses}, "endDate": bson.M{"$gt": time.Now()}})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		// Reservations a booking already has are matched again, so a rerun after a failure claims only the missing ones
+		reservations := database.Collection(db.TableReservationCollection)
+		var problems []string
+		for cursor.Next(ctx) {
+			var booking models.Booking
+			if err := cursor.Decode(&booking); err != nil {
+				return err
+			}
+			for _, reservation := range booking.Reservations() {
+				_, err := reservations.UpdateOne(ctx,
+					bson.M{"_id": reservation.ID, "bookingId": booking.ID},
+					bson.M{"$set": bson.M{"expiresAt": reservation.ExpiresAt}},
+					options.Update().SetUpsert(true),
+				)
+				if mongo.IsDuplicateKeyError(err) {
+					var holder models.TableReservation
+					if err := reservations.FindOne(ctx, bson.M{"_id": reservation.ID}).Decode(&holder); err != nil {
+						return err
+					}
+					table, _, _ := strings.Cut(reservation.ID, "@")
+					problems = append(problems, fmt.Sprintf("bookings %s and %s share table %s", holder.BookingID.Hex(), booking.ID.Hex(), table))
+					break
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("move or cancel these bookings first: %s", strings.Join(problems, "; "))
+		}
+		return nil
+	},
+	// Down drops the reservations, the end dates stay
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		return database.Collection(db.TableReservationCollection).Drop(ctx)
+	},
+}
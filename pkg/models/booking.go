@@ -1,14 +1,107 @@
 package models
 
 import (
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"book-and-rate/pkg/phone"
+	"fmt"
 	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultBookingDuration is how long a table is held when the booking has no end date
+const DefaultBookingDuration = 2 * time.Hour
+
+// MaxBookingDuration is the longest a booking may hold its tables
+const MaxBookingDuration = 6 * time.Hour
+
+const (
+	MaxPartySize      = 20
+	MaxBookingNoteLen = 500
 )
 
+// Occasions are the values accepted for Booking.Occasion
+var Occasions = []string{"birthday", "anniversary", "business", "date", "celebration", "other"}
+
 type Booking struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty"`
-	UserID       primitive.ObjectID `bson:"userId"`
-	RestaurantID primitive.ObjectID `bson:"restaurantId"`
-	Date         time.Time          `bson:"date"`
-	Cancelled    bool               `bson:"cancelled"`
+	ID              primitive.ObjectID   `bson:"_id,omitempty"`
+	UserID          primitive.ObjectID   `bson:"userId"`
+	RestaurantID    primitive.ObjectID   `bson:"restaurantId"`
+	Date            time.Time            `bson:"date"`
+	EndDate         time.Time            `bson:"endDate"`
+	PartySize       int                  `bson:"partySize"`
+	TableIDs        []primitive.ObjectID `bson:"tableIds"`
+	SpecialRequests string               `bson:"specialRequests,omitempty"`
+	Occasion        string               `bson:"occasion,omitempty"`
+	DietaryNotes    string               `bson:"dietaryNotes,omitempty"`
+	ContactPhone    string               `bson:"contactPhone,omitempty"`
+	Status          BookingStatus        `bson:"status,omitempty"`
+	History         []StatusChange       `bson:"history,omitempty"`
+}
+
+// End is when the booking releases its tables, its end date or the default duration after its date when it has none
+func (b Booking) End() time.Time {
+	if b.EndDate.IsZero() {
+		return b.Date.Add(DefaultBookingDuration)
+	}
+	return b.EndDate
+}
+
+// Validate checks the guest supplied details of a booking
+func (b Booking) Validate() error {
+	if err := ValidatePartySize(b.PartySize); err != nil {
+		return err
+	}
+	if !b.EndDate.IsZero() && !b.EndDate.After(b.Date) {
+		return FieldError{"endDate", "must be after the booking date"}
+	}
+	if b.EndDate.Sub(b.Date) > MaxBookingDuration {
+		return FieldError{"endDate", fmt.Sprintf("must be at most %d hours after the booking date", int(MaxBookingDuration.Hours()))}
+	}
+	if err := ValidateBookingNote("specialRequests", b.SpecialRequests); err != nil {
+		return err
+	}
+	if err := ValidateBookingNote("dietaryNotes", b.DietaryNotes); err != nil {
+		return err
+	}
+	if err := ValidateOccasion(b.Occasion); err != nil {
+		return err
+	}
+	if b.ContactPhone != "" && !phone.IsE164(b.ContactPhone) {
+		return FieldError{"contactPhone", "must be in international format, such as +31612345678"}
+	}
+	return nil
+}
+
+// ValidatePartySize checks the number of guests of a booking
+func ValidatePartySize(partySize int) error {
+	if partySize < 1 || partySize > MaxPartySize {
+		return FieldError{"partySize", fmt.Sprintf("must be between 1 and %d", MaxPartySize)}
+	}
+	return nil
+}
+
+// ValidateBookingNote checks the length of a free text field of a booking, reported as field
+func ValidateBookingNote(field, note string) error {
+	if utf8.RuneCountInString(note) > MaxBookingNoteLen {
+		return FieldError{field, fmt.Sprintf("must be at most %d characters", MaxBookingNoteLen)}
+	}
+	return nil
+}
+
+// ValidateOccasion checks the occasion of a booking, which may be left empty
+func ValidateOccasion(occasion string) error {
+	if occasion != "" && !isOccasion(occasion) {
+		return FieldError{"occasion", fmt.Sprintf("must be one of %v", Occasions)}
+	}
+	return nil
+}
+
+func isOccasion(occasion string) bool {
+	for _, o := range Occasions {
+		if o == occasion {
+			return true
+		}
+	}
+	return false
 }
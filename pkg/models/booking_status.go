@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+type BookingStatus string
+
+const (
+	BookingPending               BookingStatus = "pending"
+	BookingConfirmed             BookingStatus = "confirmed"
+	BookingSeated                BookingStatus = "seated"
+	BookingCompleted             BookingStatus = "completed"
+	BookingNoShow                BookingStatus = "no_show"
+	BookingCancelledByGuest      BookingStatus = "cancelled_by_guest"
+	BookingCancelledByRestaurant BookingStatus = "cancelled_by_restaurant"
+)
+
+// ActiveBookingStatuses are the states in which a booking holds its tables
+var ActiveBookingStatuses = []BookingStatus{BookingPending, BookingConfirmed, BookingSeated}
+
+// PastBookingStatuses are the terminal states, a booking never leaves them
+var PastBookingStatuses = []BookingStatus{BookingCompleted, BookingNoShow, BookingCancelledByGuest, BookingCancelledByRestaurant}
+
+var bookingTransitions = map[BookingStatus][]BookingStatus{
+	BookingPending:   {BookingConfirmed, BookingCancelledByGuest, BookingCancelledByRestaurant},
+	BookingConfirmed: {BookingSeated, BookingNoShow, BookingCancelledByGuest, BookingCancelledByRestaurant},
+	BookingSeated:    {BookingCompleted},
+}
+
+// CanTransitionTo reports whether the state machine allows moving from s to next
+func (s BookingStatus) CanTransitionTo(next BookingStatus) bool {
+	for _, allowed := range bookingTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive reports whether a booking in this state still holds its tables
+func (s BookingStatus) IsActive() bool {
+	for _, active := range ActiveBookingStatuses {
+		if active == s {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChange records a transition of a booking and who made it
+type StatusChange struct {
+	From      BookingStatus `bson:"from,omitempty"`
+	To        BookingStatus `bson:"to"`
+	At        time.Time     `bson:"at"`
+	ActorID   string        `bson:"actorId"`
+	ActorType string        `bson:"actorType"`
+}
@@ -1,6 +0,0 @@
-package models
-
-type Login struct {
-    Phone    string `json:"phone"`
-    Password string `json:"password"`
-}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// DefaultSlotInterval is the booking slot length in minutes when none is configured
+const DefaultSlotInterval = 15
+
+// ServicePeriod is a span of service within a day, times are "HH:MM" in the restaurant's timezone
+type ServicePeriod struct {
+	Open        string `bson:"open"`
+	Close       string `bson:"close"`
+	LastSeating string `bson:"lastSeating,omitempty"`
+}
+
+type DayHours struct {
+	Weekday time.Weekday    `bson:"weekday"`
+	Periods []ServicePeriod `bson:"periods"`
+}
+
+// ExceptionDate overrides the weekly schedule for a single "YYYY-MM-DD" date
+type ExceptionDate struct {
+	Date    string          `bson:"date"`
+	Closed  bool            `bson:"closed"`
+	Periods []ServicePeriod `bson:"periods"`
+	Reason  string          `bson:"reason"`
+}
+
+type OpeningHours struct {
+	Timezone     string          `bson:"timezone"`
+	SlotInterval int             `bson:"slotInterval"`
+	Weekly       []DayHours      `bson:"weekly"`
+	Exceptions   []ExceptionDate `bson:"exceptions"`
+}
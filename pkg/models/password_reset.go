@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PasswordReset is a token sent to an account holder who forgot their password.
+// Only a hash of the token is kept, and it can set a new password once.
+type PasswordReset struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	TokenHash     string             `bson:"tokenHash"`
+	AccountID     primitive.ObjectID `bson:"accountId"`
+	PrincipalType string             `bson:"principalType"`
+	CreatedAt     time.Time          `bson:"createdAt"`
+	ExpiresAt     time.Time          `bson:"expiresAt"`
+	UsedAt        *time.Time         `bson:"usedAt,omitempty"`
+}
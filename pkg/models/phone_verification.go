@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// VerificationSendWindow is the rolling day over which the codes sent to a user are capped
+const VerificationSendWindow = 24 * time.Hour
+
+// PhoneVerification is the code last sent to a user to confirm their phone number.
+// Only a hash of the code is kept, a user has at most one verification in progress.
+type PhoneVerification struct {
+	UserID    primitive.ObjectID `bson:"_id"`
+	Phone     string             `bson:"phone"`
+	CodeHash  string             `bson:"codeHash"`
+	Attempts  int                `bson:"attempts"`
+	SentAt    time.Time          `bson:"sentAt"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+	// Sends counts the codes sent since WindowStart, while that is less than VerificationSendWindow ago
+	Sends       int       `bson:"sends"`
+	WindowStart time.Time `bson:"windowStart"`
+	// PurgeAt is when the verification can be deleted, once its code has expired and its sends no longer count
+	PurgeAt time.Time `bson:"purgeAt"`
+}
+
+// ResendAt is when the code after v may be sent: a cooldown after v, and once maxSends codes were sent in the window
+// not before the window has passed
+func (v PhoneVerification) ResendAt(cooldown time.Duration, maxSends int) time.Time {
+	at := v.SentAt.Add(cooldown)
+	if windowEnd := v.WindowStart.Add(VerificationSendWindow); v.Sends >= maxSends && windowEnd.After(at) {
+		at = windowEnd
+	}
+	return at
+}
+
+// Follow counts v as the code sent after previous, in the window of previous while it lasts or else in a new one
+func (v PhoneVerification) Follow(previous PhoneVerification) PhoneVerification {
+	v.WindowStart, v.Sends = v.SentAt, 1
+	if previous.WindowStart.Add(VerificationSendWindow).After(v.SentAt) {
+		v.WindowStart, v.Sends = previous.WindowStart, previous.Sends+1
+	}
+	v.PurgeAt = v.WindowStart.Add(VerificationSendWindow)
+	if v.ExpiresAt.After(v.PurgeAt) {
+		v.PurgeAt = v.ExpiresAt
+	}
+	return v
+}
@@ -1,15 +1,36 @@
 package models
 
 import (
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"fmt"
 	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	MinRating         = 1
+	MaxRating         = 5
+	MaxRateCommentLen = 1000
 )
 
 type Rate struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty"`
 	UserID       primitive.ObjectID `bson:"userId"`
 	RestaurantID primitive.ObjectID `bson:"restaurantId"`
-	Rating       byte               `bson:"rating"`
+	BookingID    primitive.ObjectID `bson:"bookingId"`
+	Rating       int                `bson:"rating"`
 	Comment      string             `bson:"comment"`
 	Date         time.Time          `bson:"date"`
 }
+
+// Validate checks the rating and comment of a rate
+func (r Rate) Validate() error {
+	if r.Rating < MinRating || r.Rating > MaxRating {
+		return FieldError{"rating", fmt.Sprintf("must be between %d and %d", MinRating, MaxRating)}
+	}
+	if utf8.RuneCountInString(r.Comment) > MaxRateCommentLen {
+		return FieldError{"comment", fmt.Sprintf("must be at most %d characters", MaxRateCommentLen)}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+// The Bayesian score pulls restaurants with few ratings towards RatingPriorMean,
+// as if each had RatingPriorWeight extra ratings of that value
+const (
+	RatingPriorMean   = 3.0
+	RatingPriorWeight = 5
+)
+
+// RatingSummary is maintained on the restaurant as rates are created, updated and deleted.
+// Histogram counts the ratings per star, keyed "1" to "5".
+type RatingSummary struct {
+	Count         int            `bson:"count"`
+	Sum           int            `bson:"sum"`
+	Mean          float64        `bson:"mean"`
+	BayesianScore float64        `bson:"bayesianScore"`
+	Histogram     map[string]int `bson:"histogram"`
+	LastRatedAt   *time.Time     `bson:"lastRatedAt,omitempty"`
+}
+
+// Apply adds the added rating and removes the removed one, zero meaning none, and recomputes the derived scores
+func (s *RatingSummary) Apply(added, removed int, ratedAt *time.Time) {
+	if s.Histogram == nil {
+		s.Histogram = make(map[string]int)
+	}
+	if added > 0 {
+		s.Count++
+		s.Sum += added
+		s.Histogram[strconv.Itoa(added)]++
+	}
+	if removed > 0 {
+		s.Count--
+		s.Sum -= removed
+		s.Histogram[strconv.Itoa(removed)]--
+	}
+	if ratedAt != nil && (s.LastRatedAt == nil || ratedAt.After(*s.LastRatedAt)) {
+		lastRatedAt := *ratedAt
+		s.LastRatedAt = &lastRatedAt
+	}
+
+	s.Mean = 0
+	if s.Count > 0 {
+		s.Mean = float64(s.Sum) / float64(s.Count)
+	}
+	s.BayesianScore = (RatingPriorMean*RatingPriorWeight + float64(s.Sum)) / float64(RatingPriorWeight+s.Count)
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// RefreshToken records an issued refresh token, tokens rotated from the same login share a FamilyID
+type RefreshToken struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	TokenID       string             `bson:"jti"`
+	FamilyID      string             `bson:"familyId"`
+	UserID        string             `bson:"userId"`
+	PrincipalType string             `bson:"principalType"`
+	CreatedAt     time.Time          `bson:"createdAt"`
+	ExpiresAt     time.Time          `bson:"expiresAt"`
+	UsedAt        *time.Time         `bson:"usedAt,omitempty"`
+	ReplacedBy    string             `bson:"replacedBy,omitempty"`
+	RevokedAt     *time.Time         `bson:"revokedAt,omitempty"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type Table struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	RestaurantID primitive.ObjectID `bson:"restaurantId"`
+	Name         string             `bson:"name"`
+	Seats        int                `bson:"seats"`
+	Zone         string             `bson:"zone"`
+	Combinable   bool               `bson:"combinable"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReservationSlot is the granularity at which bookings hold their tables, a booking holds every slot it touches
+const ReservationSlot = 5 * time.Minute
+
+// TableReservation holds a table for one slot on behalf of a booking. Its ID names the table and the slot,
+// so no two bookings can hold the same table at the same time.
+type TableReservation struct {
+	ID        string             `bson:"_id"`
+	BookingID primitive.ObjectID `bson:"bookingId"`
+	// ExpiresAt is the end of the slot, the reservation is of no use after it
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// ReservedSpan is the time the booking holds its tables for, from the start of the slot it starts in
+// to the end of the slot it ends in. Two bookings sharing a slot contend for their tables even when their times do not overlap.
+func (b Booking) ReservedSpan() (from, until time.Time) {
+	end := b.End()
+	from, until = b.Date.Truncate(ReservationSlot), end.Truncate(ReservationSlot)
+	if until.Before(end) {
+		until = until.Add(ReservationSlot)
+	}
+	return from, until
+}
+
+// Reservations lists the table reservations the booking needs, none once it no longer holds its tables
+func (b Booking) Reservations() []TableReservation {
+	if !b.Status.IsActive() {
+		return nil
+	}
+
+	var reservations []TableReservation
+	from, until := b.ReservedSpan()
+	for _, tableId := range b.TableIDs {
+		for slot := from; slot.Before(until); slot = slot.Add(ReservationSlot) {
+			reservations = append(reservations, TableReservation{
+				ID:        fmt.Sprintf("%s@%d", tableId.Hex(), slot.Unix()),
+				BookingID: b.ID,
+				ExpiresAt: slot.Add(ReservationSlot),
+			})
+		}
+	}
+	return reservations
+}
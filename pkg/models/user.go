@@ -1,11 +1,22 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type User struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty"`
 	FirstName   string             `bson:"firstName"`
 	LastName    string             `bson:"lastName"`
-	PhoneNumber string             `bson:"phoneNumber"`
+	PhoneNumber string             `bson:"phone"`
 	Password    string             `bson:"password"`
+	// PhoneVerifiedAt is when the user proved they own PhoneNumber, it is cleared when the number changes
+	PhoneVerifiedAt *time.Time `bson:"phoneVerifiedAt"`
+}
+
+// PhoneVerified reports whether the user has confirmed their current phone number
+func (u User) PhoneVerified() bool {
+	return u.PhoneVerifiedAt != nil
 }
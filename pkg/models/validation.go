@@ -0,0 +1,24 @@
+package models
+
+import "strings"
+
+// FieldError is a validation failure of one field, Field is the JSON name of the field in the API
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// FieldErrors are the validation failures of several fields of one request
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
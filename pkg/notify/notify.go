@@ -0,0 +1,37 @@
+// Package notify tells account holders about their account. Accounts are identified by phone number,
+// so notifications go out as text messages through an sms.Sender.
+package notify
+
+import (
+	"book-and-rate/pkg/sms"
+	"context"
+	"fmt"
+	"time"
+)
+
+// Notifier delivers the account notifications, phone numbers are in E.164 format
+type Notifier interface {
+	// VerificationCode sends the code confirming the account holder owns the phone number
+	VerificationCode(ctx context.Context, phone, code string, expiresIn time.Duration) error
+	// PasswordReset sends the token that sets a new password
+	PasswordReset(ctx context.Context, phone, token string, expiresIn time.Duration) error
+}
+
+// SMSNotifier words the notifications as text messages
+type SMSNotifier struct {
+	sender sms.Sender
+}
+
+func NewSMSNotifier(sender sms.Sender) *SMSNotifier {
+	return &SMSNotifier{sender: sender}
+}
+
+func (n *SMSNotifier) VerificationCode(ctx context.Context, phone, code string, expiresIn time.Duration) error {
+	return n.sender.Send(ctx, phone, fmt.Sprintf("Your Book and Rate verification code is %s. It expires in %s.", code, expiresIn))
+}
+
+func (n *SMSNotifier) PasswordReset(ctx context.Context, phone, token string, expiresIn time.Duration) error {
+	return n.sender.Send(ctx, phone, fmt.Sprintf(
+		"Your Book and Rate password reset token is %s. It expires in %s. If you did not ask to reset your password, ignore this message.",
+		token, expiresIn))
+}
@@ -0,0 +1,49 @@
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+// E.164 numbers are a country code and subscriber number of at most 15 digits in total
+const (
+	minDigits = 7
+	maxDigits = 15
+)
+
+var (
+	ErrRequired = errors.New("phone number is required")
+	ErrInvalid  = errors.New("phone number must be in international format, such as +31612345678")
+)
+
+// separators are the characters people write between digit groups, they carry no meaning
+var separators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "/", "")
+
+// Normalize returns the number in E.164 format, such as +31612345678.
+// The number must include its country code, either after a + or the 00 international prefix.
+func Normalize(raw string) (string, error) {
+	number := separators.Replace(strings.TrimSpace(raw))
+	if number == "" {
+		return "", ErrRequired
+	}
+	if strings.HasPrefix(number, "00") {
+		number = "+" + number[2:]
+	}
+	if !IsE164(number) {
+		return "", ErrInvalid
+	}
+	return number, nil
+}
+
+// IsE164 reports whether the number is already normalized
+func IsE164(number string) bool {
+	if len(number) < minDigits+1 || len(number) > maxDigits+1 || number[0] != '+' || number[1] == '0' {
+		return false
+	}
+	for _, c := range number[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+package phone
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+		err  error
+	}{
+		{"+31612345678", "+31612345678", nil},
+		{" +31 6 1234 5678 ", "+31612345678", nil},
+		{"+1 (415) 555-0100", "+14155550100", nil},
+		{"0031.6.12345678", "+31612345678", nil},
+		{"+290 1234", "+2901234", nil},
+		{"", "", ErrRequired},
+		{"   ", "", ErrRequired},
+		{"0612345678", "", ErrInvalid},
+		{"+0612345678", "", ErrInvalid},
+		{"+31 6 CALL ME", "", ErrInvalid},
+		{"+12345", "", ErrInvalid},
+		{"+1234567890123456", "", ErrInvalid},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.raw)
+		if got != tt.want || err != tt.err {
+			t.Errorf("Normalize(%q) = %q, %v; want %q, %v", tt.raw, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"book-and-rate/pkg/auth"
+	"book-and-rate/pkg/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IsAdmin reports whether the claims belong to an administrator
+func IsAdmin(claims *auth.Claims) bool {
+	return claims != nil && claims.Type == auth.PrincipalAdmin && claims.HasRole(auth.RoleAdmin)
+}
+
+// CanActAsUser reports whether the claims may act on behalf of the user
+func CanActAsUser(claims *auth.Claims, userId primitive.ObjectID) bool {
+	return IsAdmin(claims) || (claims != nil && claims.Type == auth.PrincipalUser && claims.UserId == userId.Hex())
+}
+
+// CanActAsRestaurant reports whether the claims may act on behalf of the restaurant
+func CanActAsRestaurant(claims *auth.Claims, restaurantId primitive.ObjectID) bool {
+	return IsAdmin(claims) || (claims != nil && claims.Type == auth.PrincipalRestaurant && claims.UserId == restaurantId.Hex())
+}
+
+// CanAccessBooking lets the guest who made the booking and the booked restaurant in
+func CanAccessBooking(claims *auth.Claims, booking models.Booking) bool {
+	return CanActAsUser(claims, booking.UserID) || CanActAsRestaurant(claims, booking.RestaurantID)
+}
+
+// CanManageRate lets only the author of a rate change it
+func CanManageRate(claims *auth.Claims, rate models.Rate) bool {
+	return CanActAsUser(claims, rate.UserID)
+}
+
+// CanTransitionBooking decides who may move a booking into a state:
+// guests may only cancel their own bookings, the restaurant runs the rest of the lifecycle
+func CanTransitionBooking(claims *auth.Claims, booking models.Booking, next models.BookingStatus) bool {
+	if IsAdmin(claims) {
+		return true
+	}
+	if next == models.BookingCancelledByGuest {
+		return CanActAsUser(claims, booking.UserID)
+	}
+	return CanActAsRestaurant(claims, booking.RestaurantID)
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NewMemoryStore returns repositories that keep everything in memory, for tests and local runs without MongoDB.
+// They copy documents in and out, so callers never share state with the store.
+func NewMemoryStore() *Store {
+	return &Store{
+		Users: &memoryUsers{users: make(map[primitive.ObjectID]models.User)},
+		Restaurants: &memoryRestaurants{
+			restaurants: make(map[primitive.ObjectID]models.Restaurant),
+			tables:      make(map[primitive.ObjectID]models.Table),
+		},
+		Bookings:      &memoryBookings{bookings: make(map[primitive.ObjectID]models.Booking)},
+		Rates:         &memoryRates{rates: make(map[primitive.ObjectID]models.Rate)},
+		Tokens:        &memoryTokens{tokens: make(map[string]models.RefreshToken)},
+		Verifications: &memoryVerifications{verifications: make(map[primitive.ObjectID]models.PhoneVerification)},
+		Resets:        &memoryResets{resets: make(map[string]models.PasswordReset)},
+		Database:      memoryDatabase{},
+	}
+}
+
+// memoryDatabase is always up and has no schema to migrate
+type memoryDatabase struct{}
+
+func (memoryDatabase) Ping(ctx context.Context) error            { return nil }
+func (memoryDatabase) CheckMigrations(ctx context.Context) error { return nil }
+
+func idLess(a, b primitive.ObjectID) bool {
+	return a.Hex() < b.Hex()
+}
+
+func cloneRestaurant(restaurant models.Restaurant) models.Restaurant {
+	if restaurant.Hours != nil {
+		hours := cloneHours(*restaurant.Hours)
+		restaurant.Hours = &hours
+	}
+	if restaurant.RatingSummary != nil {
+		summary := *restaurant.RatingSummary
+		if summary.Histogram != nil {
+			histogram := make(map[string]int, len(summary.Histogram))
+			for stars, count := range summary.Histogram {
+				histogram[stars] = count
+			}
+			summary.Histogram = histogram
+		}
+		if summary.LastRatedAt != nil {
+			lastRatedAt := *summary.LastRatedAt
+			summary.LastRatedAt = &lastRatedAt
+		}
+		restaurant.RatingSummary = &summary
+	}
+	return restaurant
+}
+
+func cloneHours(hours models.OpeningHours) models.OpeningHours {
+	if hours.Weekly != nil {
+		weekly := make([]models.DayHours, len(hours.Weekly))
+		for i, day := range hours.Weekly {
+			day.Periods = append([]models.ServicePeriod(nil), day.Periods...)
+			weekly[i] = day
+		}
+		hours.Weekly = weekly
+	}
+	if hours.Exceptions != nil {
+		exceptions := make([]models.ExceptionDate, len(hours.Exceptions))
+		for i, exception := range hours.Exceptions {
+			exception.Periods = append([]models.ServicePeriod(nil), exception.Periods...)
+			exceptions[i] = exception
+		}
+		hours.Exceptions = exceptions
+	}
+	return hours
+}
+
+func cloneBooking(booking models.Booking) models.Booking {
+	booking.TableIDs = append([]primitive.ObjectID(nil), booking.TableIDs...)
+	booking.History = append([]models.StatusChange(nil), booking.History...)
+	return booking
+}
+
+func cloneUser(user models.User) models.User {
+	if user.PhoneVerifiedAt != nil {
+		verifiedAt := *user.PhoneVerifiedAt
+		user.PhoneVerifiedAt = &verifiedAt
+	}
+	return user
+}
+
+func cloneReset(reset models.PasswordReset) models.PasswordReset {
+	if reset.UsedAt != nil {
+		usedAt := *reset.UsedAt
+		reset.UsedAt = &usedAt
+	}
+	return reset
+}
+
+func cloneToken(token models.RefreshToken) models.RefreshToken {
+	if token.UsedAt != nil {
+		usedAt := *token.UsedAt
+		token.UsedAt = &usedAt
+	}
+	if token.RevokedAt != nil {
+		revokedAt := *token.RevokedAt
+		token.RevokedAt = &revokedAt
+	}
+	return token
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryBookings struct {
+	mu       sync.RWMutex
+	bookings map[primitive.ObjectID]models.Booking
+}
+
+func (m *memoryBookings) Create(ctx context.Context, booking *models.Booking) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if booking.ID.IsZero() {
+		booking.ID = primitive.NewObjectID()
+	}
+	if _, ok := m.bookings[booking.ID]; ok {
+		return ErrDuplicate
+	}
+	if m.taken(*booking) {
+		return ErrTaken
+	}
+	m.bookings[booking.ID] = cloneBooking(*booking)
+	return nil
+}
+
+// taken reports whether another booking holds one of the tables of the booking, slot by slot like the Mongo store
+func (m *memoryBookings) taken(booking models.Booking) bool {
+	held := make(map[string]bool)
+	for _, other := range m.bookings {
+		if other.ID == booking.ID || other.RestaurantID != booking.RestaurantID {
+			continue
+		}
+		for _, reservation := range other.Reservations() {
+			held[reservation.ID] = true
+		}
+	}
+	for _, reservation := range booking.Reservations() {
+		if held[reservation.ID] {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *memoryBookings) FindByID(ctx context.Context, id primitive.ObjectID) (models.Booking, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	booking, ok := m.bookings[id]
+	if !ok {
+		return models.Booking{}, ErrNotFound
+	}
+	return cloneBooking(booking), nil
+}
+
+func (m *memoryBookings) List(ctx context.Context, filter BookingFilter) ([]models.Booking, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	page := filter.Page
+	page.Sort = page.Sort.or(bookingsSort)
+	if err := checkSort(page.Sort, bookingSortFields); err != nil {
+		return nil, err
+	}
+
+	var matching []models.Booking
+	var cursors []Cursor
+	for _, booking := range m.bookings {
+		if matchesBooking(booking, filter) {
+			matching = append(matching, booking)
+			cursors = append(cursors, BookingCursor(booking, page.Sort))
+		}
+	}
+
+	var bookings []models.Booking
+	for _, i := range pageIndexes(cursors, page) {
+		bookings = append(bookings, cloneBooking(matching[i]))
+	}
+	return bookings, nil
+}
+
+func matchesBooking(booking models.Booking, filter BookingFilter) bool {
+	if !filter.RestaurantID.IsZero() && booking.RestaurantID != filter.RestaurantID {
+		return false
+	}
+	if !filter.UserID.IsZero() && booking.UserID != filter.UserID {
+		return false
+	}
+	if !filter.ExcludeID.IsZero() && booking.ID == filter.ExcludeID {
+		return false
+	}
+	if len(filter.Statuses) > 0 {
+		found := false
+		for _, status := range filter.Statuses {
+			if booking.Status == status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !filter.DateFrom.IsZero() && booking.Date.Before(filter.DateFrom) {
+		return false
+	}
+	if !filter.DateTo.IsZero() && !booking.Date.Before(filter.DateTo) {
+		return false
+	}
+	if !filter.EndsAfter.IsZero() && !booking.End().After(filter.EndsAfter) {
+		return false
+	}
+	return true
+}
+
+func (m *memoryBookings) Update(ctx context.Context, booking models.Booking) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.bookings[booking.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if existing.Status != booking.Status {
+		return ErrConflict
+	}
+	if m.taken(booking) {
+		return ErrTaken
+	}
+	booking.History = existing.History
+	m.bookings[booking.ID] = cloneBooking(booking)
+	return nil
+}
+
+func (m *memoryBookings) Transition(ctx context.Context, id primitive.ObjectID, from models.BookingStatus, change models.StatusChange) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	booking, ok := m.bookings[id]
+	if !ok || booking.Status != from {
+		return ErrConflict
+	}
+	booking = cloneBooking(booking)
+	booking.Status = change.To
+	booking.History = append(booking.History, change)
+	m.bookings[id] = booking
+	return nil
+}
+
+func (m *memoryBookings) Delete(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.bookings[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.bookings, id)
+	return nil
+}
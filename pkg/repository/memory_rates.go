@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryRates struct {
+	mu    sync.RWMutex
+	rates map[primitive.ObjectID]models.Rate
+}
+
+func (m *memoryRates) Create(ctx context.Context, rate *models.Rate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rate.ID.IsZero() {
+		rate.ID = primitive.NewObjectID()
+	}
+	if _, ok := m.rates[rate.ID]; ok {
+		return ErrDuplicate
+	}
+	// Like the unique index of the Mongo store, a booking is rated once
+	for _, existing := range m.rates {
+		if !rate.BookingID.IsZero() && existing.BookingID == rate.BookingID {
+			return ErrDuplicate
+		}
+	}
+	m.rates[rate.ID] = *rate
+	return nil
+}
+
+func (m *memoryRates) FindByID(ctx context.Context, id primitive.ObjectID) (models.Rate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rate, ok := m.rates[id]
+	if !ok {
+		return models.Rate{}, ErrNotFound
+	}
+	return rate, nil
+}
+
+func (m *memoryRates) List(ctx context.Context, filter RateFilter) ([]models.Rate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	page := filter.Page
+	page.Sort = page.Sort.or(ratesSort)
+	if err := checkSort(page.Sort, rateSortFields); err != nil {
+		return nil, err
+	}
+
+	var matching []models.Rate
+	var cursors []Cursor
+	for _, rate := range m.rates {
+		if !filter.RestaurantID.IsZero() && rate.RestaurantID != filter.RestaurantID {
+			continue
+		}
+		if !filter.BookingID.IsZero() && rate.BookingID != filter.BookingID {
+			continue
+		}
+		if (filter.MinRating != 0 && rate.Rating < filter.MinRating) || (filter.MaxRating != 0 && rate.Rating > filter.MaxRating) {
+			continue
+		}
+		if (!filter.DateFrom.IsZero() && rate.Date.Before(filter.DateFrom)) || (!filter.DateTo.IsZero() && !rate.Date.Before(filter.DateTo)) {
+			continue
+		}
+		matching = append(matching, rate)
+		cursors = append(cursors, RateCursor(rate, page.Sort))
+	}
+
+	var rates []models.Rate
+	for _, i := range pageIndexes(cursors, page) {
+		rates = append(rates, matching[i])
+	}
+	return rates, nil
+}
+
+func (m *memoryRates) Update(ctx context.Context, rate models.Rate, previousRating int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.rates[rate.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.Rating != previousRating {
+		return ErrConflict
+	}
+	m.rates[rate.ID] = rate
+	return nil
+}
+
+func (m *memoryRates) Delete(ctx context.Context, id primitive.ObjectID, rating int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.rates[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.Rating != rating {
+		return ErrConflict
+	}
+	delete(m.rates, id)
+	return nil
+}
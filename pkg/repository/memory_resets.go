@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryResets is keyed by token hash
+type memoryResets struct {
+	mu     sync.Mutex
+	resets map[string]models.PasswordReset
+}
+
+func (m *memoryResets) Create(ctx context.Context, reset models.PasswordReset) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.resets[reset.TokenHash]; ok {
+		return ErrDuplicate
+	}
+	if reset.ID.IsZero() {
+		reset.ID = primitive.NewObjectID()
+	}
+	m.resets[reset.TokenHash] = cloneReset(reset)
+	return nil
+}
+
+func (m *memoryResets) Latest(ctx context.Context, principalType string, accountId primitive.ObjectID) (models.PasswordReset, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var latest *models.PasswordReset
+	for _, reset := range m.resets {
+		if reset.PrincipalType != principalType || reset.AccountID != accountId {
+			continue
+		}
+		if latest == nil || reset.CreatedAt.After(latest.CreatedAt) {
+			reset := reset
+			latest = &reset
+		}
+	}
+	if latest == nil {
+		return models.PasswordReset{}, ErrNotFound
+	}
+	return cloneReset(*latest), nil
+}
+
+func (m *memoryResets) Consume(ctx context.Context, principalType, tokenHash string, at time.Time) (models.PasswordReset, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reset, ok := m.resets[tokenHash]
+	if !ok || reset.PrincipalType != principalType || reset.UsedAt != nil || !at.Before(reset.ExpiresAt) {
+		return models.PasswordReset{}, ErrNotFound
+	}
+	reset.UsedAt = &at
+	m.resets[tokenHash] = reset
+	return cloneReset(reset), nil
+}
+
+func (m *memoryResets) DeleteAccount(ctx context.Context, principalType string, accountId primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for hash, reset := range m.resets {
+		if reset.PrincipalType == principalType && reset.AccountID == accountId {
+			delete(m.resets, hash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryRestaurants struct {
+	mu          sync.RWMutex
+	restaurants map[primitive.ObjectID]models.Restaurant
+	tables      map[primitive.ObjectID]models.Table
+}
+
+func (m *memoryRestaurants) Create(ctx context.Context, restaurant *models.Restaurant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if restaurant.ID.IsZero() {
+		restaurant.ID = primitive.NewObjectID()
+	}
+	if _, ok := m.restaurants[restaurant.ID]; ok || m.phoneTaken(restaurant.Phone, restaurant.ID) {
+		return ErrDuplicate
+	}
+	m.restaurants[restaurant.ID] = cloneRestaurant(*restaurant)
+	return nil
+}
+
+func (m *memoryRestaurants) FindByID(ctx context.Context, id primitive.ObjectID) (models.Restaurant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	restaurant, ok := m.restaurants[id]
+	if !ok {
+		return models.Restaurant{}, ErrNotFound
+	}
+	return cloneRestaurant(restaurant), nil
+}
+
+func (m *memoryRestaurants) FindByPhone(ctx context.Context, phone string) (models.Restaurant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, restaurant := range m.restaurants {
+		if restaurant.Phone == phone {
+			return cloneRestaurant(restaurant), nil
+		}
+	}
+	return models.Restaurant{}, ErrNotFound
+}
+
+func (m *memoryRestaurants) List(ctx context.Context, filter RestaurantFilter) ([]models.Restaurant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	page := filter.Page
+	page.Sort = page.Sort.or(restaurantsSort)
+	if err := checkSort(page.Sort, restaurantSortFields); err != nil {
+		return nil, err
+	}
+
+	var matching []models.Restaurant
+	var cursors []Cursor
+	for _, restaurant := range m.restaurants {
+		if filter.WithHours && restaurant.Hours == nil {
+			continue
+		}
+		if (filter.MinRating != 0 || filter.MaxRating != 0) && !ratedWithin(restaurant, filter.MinRating, filter.MaxRating) {
+			continue
+		}
+		matching = append(matching, restaurant)
+		cursors = append(cursors, RestaurantCursor(restaurant, page.Sort))
+	}
+
+	var restaurants []models.Restaurant
+	for _, i := range pageIndexes(cursors, page) {
+		restaurants = append(restaurants, cloneRestaurant(matching[i]))
+	}
+	return restaurants, nil
+}
+
+// ratedWithin reports whether the mean rating of the restaurant lies within the bounds, zero bounds being open
+func ratedWithin(restaurant models.Restaurant, min, max float64) bool {
+	if restaurant.RatingSummary == nil {
+		return false
+	}
+	mean := restaurant.RatingSummary.Mean
+	return (min == 0 || mean >= min) && (max == 0 || mean <= max)
+}
+
+func (m *memoryRestaurants) Update(ctx context.Context, restaurant models.Restaurant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.restaurants[restaurant.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if m.phoneTaken(restaurant.Phone, restaurant.ID) {
+		return ErrDuplicate
+	}
+	restaurant.Hours = existing.Hours
+	restaurant.RatingSummary = existing.RatingSummary
+	m.restaurants[restaurant.ID] = restaurant
+	return nil
+}
+
+func (m *memoryRestaurants) UpdateHours(ctx context.Context, id primitive.ObjectID, hours models.OpeningHours) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	restaurant, ok := m.restaurants[id]
+	if !ok {
+		return ErrNotFound
+	}
+	cloned := cloneHours(hours)
+	restaurant.Hours = &cloned
+	m.restaurants[id] = restaurant
+	return nil
+}
+
+func (m *memoryRestaurants) ApplyRatingChange(ctx context.Context, id primitive.ObjectID, added, removed int, ratedAt *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	restaurant, ok := m.restaurants[id]
+	if !ok {
+		return ErrNotFound
+	}
+	restaurant = cloneRestaurant(restaurant)
+	if restaurant.RatingSummary == nil {
+		restaurant.RatingSummary = &models.RatingSummary{}
+	}
+	restaurant.RatingSummary.Apply(added, removed, ratedAt)
+	m.restaurants[id] = restaurant
+	return nil
+}
+
+func (m *memoryRestaurants) SetPassword(ctx context.Context, id primitive.ObjectID, hashedPassword string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	restaurant, ok := m.restaurants[id]
+	if !ok {
+		return ErrNotFound
+	}
+	restaurant.Password = hashedPassword
+	m.restaurants[id] = restaurant
+	return nil
+}
+
+func (m *memoryRestaurants) Delete(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.restaurants[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.restaurants, id)
+	return nil
+}
+
+func (m *memoryRestaurants) CreateTable(ctx context.Context, table *models.Table) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if table.ID.IsZero() {
+		table.ID = primitive.NewObjectID()
+	}
+	if _, ok := m.tables[table.ID]; ok {
+		return ErrDuplicate
+	}
+	m.tables[table.ID] = *table
+	return nil
+}
+
+func (m *memoryRestaurants) ListTables(ctx context.Context, restaurantId primitive.ObjectID) ([]models.Table, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var tables []models.Table
+	for _, table := range m.tables {
+		if table.RestaurantID == restaurantId {
+			tables = append(tables, table)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool { return idLess(tables[i].ID, tables[j].ID) })
+	return tables, nil
+}
+
+func (m *memoryRestaurants) UpdateTable(ctx context.Context, table models.Table) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.tables[table.ID]
+	if !ok || existing.RestaurantID != table.RestaurantID {
+		return ErrNotFound
+	}
+	m.tables[table.ID] = table
+	return nil
+}
+
+func (m *memoryRestaurants) DeleteTable(ctx context.Context, restaurantId, tableId primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.tables[tableId]
+	if !ok || existing.RestaurantID != restaurantId {
+		return ErrNotFound
+	}
+	delete(m.tables, tableId)
+	return nil
+}
+
+// phoneTaken mirrors the unique phone index, the caller holds the lock
+func (m *memoryRestaurants) phoneTaken(phone string, except primitive.ObjectID) bool {
+	for id, restaurant := range m.restaurants {
+		if id != except && restaurant.Phone == phone {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryTokens is keyed by jti
+type memoryTokens struct {
+	mu     sync.Mutex
+	tokens map[string]models.RefreshToken
+}
+
+func (m *memoryTokens) Create(ctx context.Context, token models.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tokens[token.TokenID]; ok {
+		return ErrDuplicate
+	}
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	m.tokens[token.TokenID] = cloneToken(token)
+	return nil
+}
+
+func (m *memoryTokens) FindByTokenID(ctx context.Context, tokenId string) (models.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[tokenId]
+	if !ok {
+		return models.RefreshToken{}, ErrNotFound
+	}
+	return cloneToken(token), nil
+}
+
+func (m *memoryTokens) Consume(ctx context.Context, tokenId string, at time.Time) (models.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[tokenId]
+	if !ok || token.UsedAt != nil || token.RevokedAt != nil {
+		return models.RefreshToken{}, ErrNotFound
+	}
+	token.UsedAt = &at
+	m.tokens[tokenId] = token
+	return cloneToken(token), nil
+}
+
+func (m *memoryTokens) SetReplacedBy(ctx context.Context, tokenId, replacedBy string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[tokenId]
+	if !ok {
+		return ErrNotFound
+	}
+	token.ReplacedBy = replacedBy
+	m.tokens[tokenId] = token
+	return nil
+}
+
+func (m *memoryTokens) RevokeFamily(ctx context.Context, familyId string, at time.Time) error {
+	m.revoke(func(token models.RefreshToken) bool { return token.FamilyID == familyId }, at)
+	return nil
+}
+
+func (m *memoryTokens) RevokeUser(ctx context.Context, userId string, at time.Time) error {
+	m.revoke(func(token models.RefreshToken) bool { return token.UserID == userId }, at)
+	return nil
+}
+
+func (m *memoryTokens) revoke(match func(models.RefreshToken) bool, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for jti, token := range m.tokens {
+		if token.RevokedAt == nil && match(token) {
+			revokedAt := at
+			token.RevokedAt = &revokedAt
+			m.tokens[jti] = token
+		}
+	}
+}
+
+func (m *memoryTokens) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for jti, token := range m.tokens {
+		if token.ExpiresAt.Before(before) {
+			delete(m.tokens, jti)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
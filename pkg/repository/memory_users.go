@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryUsers struct {
+	mu    sync.RWMutex
+	users map[primitive.ObjectID]models.User
+}
+
+func (m *memoryUsers) Create(ctx context.Context, user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	if _, ok := m.users[user.ID]; ok || m.phoneTaken(user.PhoneNumber, user.ID) {
+		return ErrDuplicate
+	}
+	m.users[user.ID] = cloneUser(*user)
+	return nil
+}
+
+func (m *memoryUsers) FindByID(ctx context.Context, id primitive.ObjectID) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return cloneUser(user), nil
+}
+
+func (m *memoryUsers) FindByPhone(ctx context.Context, phone string) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.PhoneNumber == phone {
+			return cloneUser(user), nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (m *memoryUsers) Update(ctx context.Context, user models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[user.ID]; !ok {
+		return ErrNotFound
+	}
+	if m.phoneTaken(user.PhoneNumber, user.ID) {
+		return ErrDuplicate
+	}
+	m.users[user.ID] = cloneUser(user)
+	return nil
+}
+
+func (m *memoryUsers) MarkPhoneVerified(ctx context.Context, id primitive.ObjectID, phone string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok || user.PhoneNumber != phone {
+		return ErrNotFound
+	}
+	user.PhoneVerifiedAt = &at
+	m.users[id] = user
+	return nil
+}
+
+func (m *memoryUsers) SetPassword(ctx context.Context, id primitive.ObjectID, hashedPassword string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Password = hashedPassword
+	m.users[id] = user
+	return nil
+}
+
+func (m *memoryUsers) Delete(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.users, id)
+	return nil
+}
+
+// phoneTaken mirrors the unique phone index, the caller holds the lock
+func (m *memoryUsers) phoneTaken(phone string, except primitive.ObjectID) bool {
+	for id, user := range m.users {
+		if id != except && user.PhoneNumber == phone {
+			return true
+		}
+	}
+	return false
+}
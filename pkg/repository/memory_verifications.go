@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryVerifications is keyed by user, expired verifications stay until replaced as there is no TTL index
+type memoryVerifications struct {
+	mu            sync.Mutex
+	verifications map[primitive.ObjectID]models.PhoneVerification
+}
+
+func (m *memoryVerifications) Start(ctx context.Context, verification models.PhoneVerification, limits SendLimits) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous, ok := m.verifications[verification.UserID]
+	if ok && previous.ResendAt(limits.Cooldown, limits.MaxSends).After(verification.SentAt) {
+		return ErrConflict
+	}
+	m.verifications[verification.UserID] = verification.Follow(previous)
+	return nil
+}
+
+func (m *memoryVerifications) Save(ctx context.Context, verification models.PhoneVerification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.verifications[verification.UserID] = verification
+	return nil
+}
+
+func (m *memoryVerifications) FindByUser(ctx context.Context, userId primitive.ObjectID) (models.PhoneVerification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	verification, ok := m.verifications[userId]
+	if !ok {
+		return models.PhoneVerification{}, ErrNotFound
+	}
+	return verification, nil
+}
+
+func (m *memoryVerifications) RecordAttempt(ctx context.Context, userId primitive.ObjectID) (models.PhoneVerification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	verification, ok := m.verifications[userId]
+	if !ok {
+		return models.PhoneVerification{}, ErrNotFound
+	}
+	verification.Attempts++
+	m.verifications[userId] = verification
+	return verification, nil
+}
+
+func (m *memoryVerifications) Delete(ctx context.Context, userId primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.verifications[userId]; !ok {
+		return ErrNotFound
+	}
+	delete(m.verifications, userId)
+	return nil
+}
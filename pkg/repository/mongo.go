@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"book-and-rate/pkg/db"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// NewMongoStore returns repositories backed by the collections of database
+func NewMongoStore(database *mongo.Database) *Store {
+	return &Store{
+		Users: &mongoUsers{collection: database.Collection(db.UserCollection)},
+		Restaurants: &mongoRestaurants{
+			collection: database.Collection(db.RestaurantCollection),
+			tables:     database.Collection(db.TableCollection),
+		},
+		Bookings: &mongoBookings{
+			collection:   database.Collection(db.BookingCollection),
+			reservations: database.Collection(db.TableReservationCollection),
+		},
+		Rates:         &mongoRates{collection: database.Collection(db.RateCollection)},
+		Tokens:        &mongoTokens{collection: database.Collection(db.RefreshTokenCollection)},
+		Verifications: &mongoVerifications{collection: database.Collection(db.PhoneVerificationCollection)},
+		Resets:        &mongoResets{collection: database.Collection(db.PasswordResetCollection)},
+		Database:      &mongoDatabase{database: database},
+	}
+}
+
+// mongoError translates driver errors into the repository errors
+func mongoError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return ErrDuplicate
+	case mongo.IsTimeout(err) || mongo.IsNetworkError(err) || errors.As(err, &topology.ServerSelectionError{}) || errors.Is(err, mongo.ErrClientDisconnected):
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	default:
+		return err
+	}
+}
+
+func insertedID(result *mongo.InsertOneResult) primitive.ObjectID {
+	id, _ := result.InsertedID.(primitive.ObjectID)
+	return id
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// mongoBookings holds the tables of the active bookings with a reservation per table and slot, whose unique ID
+// makes claiming a table an atomic write. Reservations are claimed before a booking is written and released after,
+// so a write that fails halfway leaves at most a reservation behind, which holds its table until the slot is over.
+type mongoBookings struct {
+	collection   *mongo.Collection
+	reservations *mongo.Collection
+}
+
+func (m *mongoBookings) Create(ctx context.Context, booking *models.Booking) error {
+	if booking.ID.IsZero() {
+		booking.ID = primitive.NewObjectID()
+	}
+	if err := m.hold(ctx, *booking); err != nil {
+		m.release(ctx, booking.ID, nil)
+		return err
+	}
+	if _, err := m.collection.InsertOne(ctx, booking); err != nil {
+		m.release(ctx, booking.ID, nil)
+		return mongoError(err)
+	}
+	return nil
+}
+
+// hold claims the reservations of the booking, those it already has are kept
+func (m *mongoBookings) hold(ctx context.Context, booking models.Booking) error {
+	reservations := booking.Reservations()
+	if len(reservations) == 0 {
+		return nil
+	}
+
+	// The filter only matches a reservation of this booking, so the upsert of a slot held by another booking
+	// inserts a second document with the same ID and fails
+	writes := make([]mongo.WriteModel, 0, len(reservations))
+	for _, reservation := range reservations {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": reservation.ID, "bookingId": reservation.BookingID}).
+			SetUpdate(bson.M{"$set": bson.M{"expiresAt": reservation.ExpiresAt}}).
+			SetUpsert(true))
+	}
+	_, err := m.reservations.BulkWrite(ctx, writes)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrTaken
+	}
+	return mongoError(err)
+}
+
+// release drops the reservations of the booking other than those in keep
+func (m *mongoBookings) release(ctx context.Context, id primitive.ObjectID, keep []models.TableReservation) error {
+	kept := make([]string, 0, len(keep))
+	for _, reservation := range keep {
+		kept = append(kept, reservation.ID)
+	}
+	_, err := m.reservations.DeleteMany(ctx, bson.M{"bookingId": id, "_id": bson.M{"$nin": kept}})
+	return mongoError(err)
+}
+
+// resync brings the reservations of a booking back in line with the stored booking after a failed update
+func (m *mongoBookings) resync(ctx context.Context, id primitive.ObjectID) {
+	stored, err := m.FindByID(ctx, id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return
+	}
+	m.release(ctx, id, stored.Reservations())
+}
+
+func (m *mongoBookings) FindByID(ctx context.Context, id primitive.ObjectID) (models.Booking, error) {
+	var booking models.Booking
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&booking)
+	return booking, mongoError(err)
+}
+
+func (m *mongoBookings) List(ctx context.Context, filter BookingFilter) ([]models.Booking, error) {
+	query := bson.M{}
+	if !filter.RestaurantID.IsZero() {
+		query["restaurantId"] = filter.RestaurantID
+	}
+	if !filter.UserID.IsZero() {
+		query["userId"] = filter.UserID
+	}
+	if !filter.ExcludeID.IsZero() {
+		query["_id"] = bson.M{"$ne": filter.ExcludeID}
+	}
+	if len(filter.Statuses) > 0 {
+		query["status"] = bson.M{"$in": filter.Statuses}
+	}
+	date := bson.M{}
+	if !filter.DateFrom.IsZero() {
+		date["$gte"] = filter.DateFrom
+	}
+	if !filter.DateTo.IsZero() {
+		date["$lt"] = filter.DateTo
+	}
+	if len(date) > 0 {
+		query["date"] = date
+	}
+	if !filter.EndsAfter.IsZero() {
+		query["endDate"] = bson.M{"$gt": filter.EndsAfter}
+	}
+
+	page := filter.Page
+	page.Sort = page.Sort.or(bookingsSort)
+	query, findOptions, err := findPage(query, page, bookingSortFields)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := m.collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	var bookings []models.Booking
+	if err := cursor.All(ctx, &bookings); err != nil {
+		return nil, mongoError(err)
+	}
+	return bookings, nil
+}
+
+// Update filters on the status the booking was read in, so it cannot undo a transition made in the meantime
+func (m *mongoBookings) Update(ctx context.Context, booking models.Booking) error {
+	if err := m.hold(ctx, booking); err != nil {
+		m.resync(ctx, booking.ID)
+		return err
+	}
+
+	// The status and history have their own writer
+	details := booking
+	details.Status = ""
+	details.History = nil
+	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": booking.ID, "status": booking.Status}, bson.M{"$set": details})
+	if err != nil {
+		m.resync(ctx, booking.ID)
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		m.resync(ctx, booking.ID)
+		if _, err := m.FindByID(ctx, booking.ID); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return m.release(ctx, booking.ID, booking.Reservations())
+}
+
+// Transition filters on the current status, so two concurrent transitions cannot both succeed
+func (m *mongoBookings) Transition(ctx context.Context, id primitive.ObjectID, from models.BookingStatus, change models.StatusChange) error {
+	result, err := m.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": from},
+		bson.M{"$set": bson.M{"status": change.To}, "$push": bson.M{"history": change}},
+	)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrConflict
+	}
+	if !change.To.IsActive() {
+		return m.release(ctx, id, nil)
+	}
+	return nil
+}
+
+func (m *mongoBookings) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := m.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return m.release(ctx, id, nil)
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"book-and-rate/pkg/migrations"
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoDatabase struct {
+	database *mongo.Database
+}
+
+func (m *mongoDatabase) Ping(ctx context.Context) error {
+	return m.database.Client().Ping(ctx, nil)
+}
+
+func (m *mongoDatabase) CheckMigrations(ctx context.Context) error {
+	pending, err := migrations.NewMigrator(m.database).Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d migrations are pending, starting with %d %s", len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
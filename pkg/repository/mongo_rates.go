@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoRates struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoRates) Create(ctx context.Context, rate *models.Rate) error {
+	result, err := m.collection.InsertOne(ctx, rate)
+	if err != nil {
+		return mongoError(err)
+	}
+	rate.ID = insertedID(result)
+	return nil
+}
+
+func (m *mongoRates) FindByID(ctx context.Context, id primitive.ObjectID) (models.Rate, error) {
+	var rate models.Rate
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&rate)
+	return rate, mongoError(err)
+}
+
+func (m *mongoRates) List(ctx context.Context, filter RateFilter) ([]models.Rate, error) {
+	query := bson.M{}
+	if !filter.RestaurantID.IsZero() {
+		query["restaurantId"] = filter.RestaurantID
+	}
+	if !filter.BookingID.IsZero() {
+		query["bookingId"] = filter.BookingID
+	}
+	rating := bson.M{}
+	if filter.MinRating != 0 {
+		rating["$gte"] = filter.MinRating
+	}
+	if filter.MaxRating != 0 {
+		rating["$lte"] = filter.MaxRating
+	}
+	if len(rating) > 0 {
+		query["rating"] = rating
+	}
+	date := bson.M{}
+	if !filter.DateFrom.IsZero() {
+		date["$gte"] = filter.DateFrom
+	}
+	if !filter.DateTo.IsZero() {
+		date["$lt"] = filter.DateTo
+	}
+	if len(date) > 0 {
+		query["date"] = date
+	}
+
+	page := filter.Page
+	page.Sort = page.Sort.or(ratesSort)
+	query, findOptions, err := findPage(query, page, rateSortFields)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := m.collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	var rates []models.Rate
+	if err := cursor.All(ctx, &rates); err != nil {
+		return nil, mongoError(err)
+	}
+	return rates, nil
+}
+
+// Update filters on the rating the rate was read with, so two concurrent changes cannot both move the summary from it
+func (m *mongoRates) Update(ctx context.Context, rate models.Rate, previousRating int) error {
+	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": rate.ID, "rating": previousRating}, bson.M{"$set": rate})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return m.missingOrChanged(ctx, rate.ID)
+	}
+	return nil
+}
+
+func (m *mongoRates) Delete(ctx context.Context, id primitive.ObjectID, rating int) error {
+	result, err := m.collection.DeleteOne(ctx, bson.M{"_id": id, "rating": rating})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.DeletedCount == 0 {
+		return m.missingOrChanged(ctx, id)
+	}
+	return nil
+}
+
+// missingOrChanged tells why a conditional write matched nothing: ErrNotFound without the rate, ErrConflict with it
+func (m *mongoRates) missingOrChanged(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := m.FindByID(ctx, id); err != nil {
+		return err
+	}
+	return ErrConflict
+}
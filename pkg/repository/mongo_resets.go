@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoResets struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoResets) Create(ctx context.Context, reset models.PasswordReset) error {
+	_, err := m.collection.InsertOne(ctx, reset)
+	return mongoError(err)
+}
+
+func (m *mongoResets) Latest(ctx context.Context, principalType string, accountId primitive.ObjectID) (models.PasswordReset, error) {
+	var reset models.PasswordReset
+	err := m.collection.FindOne(ctx,
+		bson.M{"principalType": principalType, "accountId": accountId},
+		options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
+	).Decode(&reset)
+	return reset, mongoError(err)
+}
+
+// Consume is a single FindOneAndUpdate, so a token raced by two resets is only honoured once
+func (m *mongoResets) Consume(ctx context.Context, principalType, tokenHash string, at time.Time) (models.PasswordReset, error) {
+	var reset models.PasswordReset
+	err := m.collection.FindOneAndUpdate(ctx,
+		bson.M{"tokenHash": tokenHash, "principalType": principalType, "usedAt": nil, "expiresAt": bson.M{"$gt": at}},
+		bson.M{"$set": bson.M{"usedAt": at}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&reset)
+	return reset, mongoError(err)
+}
+
+func (m *mongoResets) DeleteAccount(ctx context.Context, principalType string, accountId primitive.ObjectID) error {
+	_, err := m.collection.DeleteMany(ctx, bson.M{"principalType": principalType, "accountId": accountId})
+	return mongoError(err)
+}
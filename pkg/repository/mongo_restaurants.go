@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoRestaurants struct {
+	collection *mongo.Collection
+	tables     *mongo.Collection
+}
+
+func (m *mongoRestaurants) Create(ctx context.Context, restaurant *models.Restaurant) error {
+	result, err := m.collection.InsertOne(ctx, restaurant)
+	if err != nil {
+		return mongoError(err)
+	}
+	restaurant.ID = insertedID(result)
+	return nil
+}
+
+func (m *mongoRestaurants) FindByID(ctx context.Context, id primitive.ObjectID) (models.Restaurant, error) {
+	var restaurant models.Restaurant
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&restaurant)
+	return restaurant, mongoError(err)
+}
+
+func (m *mongoRestaurants) FindByPhone(ctx context.Context, phone string) (models.Restaurant, error) {
+	var restaurant models.Restaurant
+	err := m.collection.FindOne(ctx, bson.M{"phone": phone}).Decode(&restaurant)
+	return restaurant, mongoError(err)
+}
+
+func (m *mongoRestaurants) List(ctx context.Context, filter RestaurantFilter) ([]models.Restaurant, error) {
+	query := bson.M{}
+	if filter.WithHours {
+		query["hours"] = bson.M{"$exists": true}
+	}
+	rating := bson.M{}
+	if filter.MinRating != 0 {
+		rating["$gte"] = filter.MinRating
+	}
+	if filter.MaxRating != 0 {
+		rating["$lte"] = filter.MaxRating
+	}
+	if len(rating) > 0 {
+		query["ratingSummary.mean"] = rating
+	}
+
+	page := filter.Page
+	page.Sort = page.Sort.or(restaurantsSort)
+	query, findOptions, err := findPage(query, page, restaurantSortFields)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := m.collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	var restaurants []models.Restaurant
+	if err := cursor.All(ctx, &restaurants); err != nil {
+		return nil, mongoError(err)
+	}
+	return restaurants, nil
+}
+
+func (m *mongoRestaurants) Update(ctx context.Context, restaurant models.Restaurant) error {
+	// Hours and the rating summary have their own writers
+	restaurant.Hours = nil
+	restaurant.RatingSummary = nil
+	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": restaurant.ID}, bson.M{"$set": restaurant})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoRestaurants) UpdateHours(ctx context.Context, id primitive.ObjectID, hours models.OpeningHours) error {
+	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"hours": hours}})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ApplyRatingChange updates the rating summary in a single pipeline update, so concurrent rates never lose counts
+func (m *mongoRestaurants) ApplyRatingChange(ctx context.Context, id primitive.ObjectID, added, removed int, ratedAt *time.Time) error {
+	countDelta := 0
+	histogramDelta := make(map[int]int)
+	if added > 0 {
+		countDelta++
+		histogramDelta[added]++
+	}
+	if removed > 0 {
+		countDelta--
+		histogramDelta[removed]--
+	}
+
+	counters := bson.M{
+		"ratingSummary.count": incremented("$ratingSummary.count", countDelta),
+		"ratingSummary.sum":   incremented("$ratingSummary.sum", added-removed),
+	}
+	for stars, delta := range histogramDelta {
+		field := "ratingSummary.histogram." + strconv.Itoa(stars)
+		counters[field] = incremented("$"+field, delta)
+	}
+	if ratedAt != nil {
+		counters["ratingSummary.lastRatedAt"] = bson.M{"$max": bson.A{"$ratingSummary.lastRatedAt", *ratedAt}}
+	}
+
+	derived := bson.M{
+		"ratingSummary.mean": bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{"$ratingSummary.count", 0}},
+			bson.M{"$divide": bson.A{"$ratingSummary.sum", "$ratingSummary.count"}},
+			0,
+		}},
+		"ratingSummary.bayesianScore": bson.M{"$divide": bson.A{
+			bson.M{"$add": bson.A{models.RatingPriorMean * models.RatingPriorWeight, "$ratingSummary.sum"}},
+			bson.M{"$add": bson.A{models.RatingPriorWeight, "$ratingSummary.count"}},
+		}},
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$set", Value: counters}}, {{Key: "$set", Value: derived}}}
+	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": id}, pipeline)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoRestaurants) SetPassword(ctx context.Context, id primitive.ObjectID, hashedPassword string) error {
+	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"password": hashedPassword}})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoRestaurants) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := m.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoRestaurants) CreateTable(ctx context.Context, table *models.Table) error {
+	result, err := m.tables.InsertOne(ctx, table)
+	if err != nil {
+		return mongoError(err)
+	}
+	table.ID = insertedID(result)
+	return nil
+}
+
+func (m *mongoRestaurants) ListTables(ctx context.Context, restaurantId primitive.ObjectID) ([]models.Table, error) {
+	cursor, err := m.tables.Find(ctx, bson.M{"restaurantId": restaurantId})
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	var tables []models.Table
+	if err := cursor.All(ctx, &tables); err != nil {
+		return nil, mongoError(err)
+	}
+	return tables, nil
+}
+
+func (m *mongoRestaurants) UpdateTable(ctx context.Context, table models.Table) error {
+	result, err := m.tables.UpdateOne(ctx, bson.M{"_id": table.ID, "restaurantId": table.RestaurantID}, bson.M{"$set": table})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoRestaurants) DeleteTable(ctx context.Context, restaurantId, tableId primitive.ObjectID) error {
+	result, err := m.tables.DeleteOne(ctx, bson.M{"_id": tableId, "restaurantId": restaurantId})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func incremented(field string, delta int) bson.M {
+	return bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{field, 0}}, delta}}
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoTokens struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoTokens) Create(ctx context.Context, token models.RefreshToken) error {
+	_, err := m.collection.InsertOne(ctx, token)
+	return mongoError(err)
+}
+
+func (m *mongoTokens) FindByTokenID(ctx context.Context, tokenId string) (models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := m.collection.FindOne(ctx, bson.M{"jti": tokenId}).Decode(&token)
+	return token, mongoError(err)
+}
+
+// Consume is a single FindOneAndUpdate, so a token raced by two refreshes is only honoured once
+func (m *mongoTokens) Consume(ctx context.Context, tokenId string, at time.Time) (models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := m.collection.FindOneAndUpdate(ctx,
+		bson.M{"jti": tokenId, "usedAt": nil, "revokedAt": nil},
+		bson.M{"$set": bson.M{"usedAt": at}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&token)
+	return token, mongoError(err)
+}
+
+func (m *mongoTokens) SetReplacedBy(ctx context.Context, tokenId, replacedBy string) error {
+	result, err := m.collection.UpdateOne(ctx, bson.M{"jti": tokenId}, bson.M{"$set": bson.M{"replacedBy": replacedBy}})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoTokens) RevokeFamily(ctx context.Context, familyId string, at time.Time) error {
+	return m.revoke(ctx, bson.M{"familyId": familyId}, at)
+}
+
+func (m *mongoTokens) RevokeUser(ctx context.Context, userId string, at time.Time) error {
+	return m.revoke(ctx, bson.M{"userId": userId}, at)
+}
+
+func (m *mongoTokens) revoke(ctx context.Context, filter bson.M, at time.Time) error {
+	filter["revokedAt"] = nil
+	_, err := m.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": at}})
+	return mongoError(err)
+}
+
+func (m *mongoTokens) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := m.collection.DeleteMany(ctx, bson.M{"expiresAt": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, mongoError(err)
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoUsers struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoUsers) Create(ctx context.Context, user *models.User) error {
+	result, err := m.collection.InsertOne(ctx, user)
+	if err != nil {
+		return mongoError(err)
+	}
+	user.ID = insertedID(result)
+	return nil
+}
+
+func (m *mongoUsers) FindByID(ctx context.Context, id primitive.ObjectID) (models.User, error) {
+	var user models.User
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
+	return user, mongoError(err)
+}
+
+func (m *mongoUsers) FindByPhone(ctx context.Context, phone string) (models.User, error) {
+	var user models.User
+	err := m.collection.FindOne(ctx, bson.M{"phone": phone}).Decode(&user)
+	return user, mongoError(err)
+}
+
+func (m *mongoUsers) Update(ctx context.Context, user models.User) error {
+	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": user})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoUsers) MarkPhoneVerified(ctx context.Context, id primitive.ObjectID, phone string, at time.Time) error {
+	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": id, "phone": phone}, bson.M{"$set": bson.M{"phoneVerifiedAt": at}})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoUsers) SetPassword(ctx context.Context, id primitive.ObjectID, hashedPassword string) error {
+	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"password": hashedPassword}})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoUsers) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := m.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
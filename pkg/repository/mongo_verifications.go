@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoVerifications stores one document per user, keyed by the user ID
+type mongoVerifications struct {
+	collection *mongo.Collection
+}
+
+// Start upserts only while the stored verification allows another code, so a verification holding it back turns the
+// upsert into an insert that the _id refuses. The window is carried over in the update the way Follow does it.
+func (m *mongoVerifications) Start(ctx context.Context, verification models.PhoneVerification, limits SendLimits) error {
+	windowFrom := verification.SentAt.Add(-models.VerificationSendWindow)
+	filter := bson.M{
+		"_id":    verification.UserID,
+		"sentAt": bson.M{"$lte": verification.SentAt.Add(-limits.Cooldown)},
+		"$or": bson.A{
+			bson.M{"sends": bson.M{"$lt": limits.MaxSends}},
+			bson.M{"windowStart": bson.M{"$lte": windowFrom}},
+			bson.M{"windowStart": bson.M{"$exists": false}},
+		},
+	}
+
+	inWindow := bson.M{"$gt": bson.A{"$windowStart", windowFrom}}
+	update := bson.A{
+		bson.M{"$set": bson.M{
+			"phone":       verification.Phone,
+			"codeHash":    verification.CodeHash,
+			"attempts":    0,
+			"sentAt":      verification.SentAt,
+			"expiresAt":   verification.ExpiresAt,
+			"windowStart": bson.M{"$cond": bson.A{inWindow, "$windowStart", verification.SentAt}},
+			"sends":       bson.M{"$cond": bson.A{inWindow, bson.M{"$add": bson.A{"$sends", 1}}, 1}},
+		}},
+		bson.M{"$set": bson.M{
+			"purgeAt": bson.M{"$max": bson.A{"$expiresAt", bson.M{"$add": bson.A{"$windowStart", models.VerificationSendWindow.Milliseconds()}}}},
+		}},
+	}
+
+	_, err := m.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err = mongoError(err); errors.Is(err, ErrDuplicate) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (m *mongoVerifications) Save(ctx context.Context, verification models.PhoneVerification) error {
+	_, err := m.collection.ReplaceOne(ctx, bson.M{"_id": verification.UserID}, verification, options.Replace().SetUpsert(true))
+	return mongoError(err)
+}
+
+func (m *mongoVerifications) FindByUser(ctx context.Context, userId primitive.ObjectID) (models.PhoneVerification, error) {
+	var verification models.PhoneVerification
+	err := m.collection.FindOne(ctx, bson.M{"_id": userId}).Decode(&verification)
+	return verification, mongoError(err)
+}
+
+// RecordAttempt increments in place, so concurrent guesses cannot share an attempt
+func (m *mongoVerifications) RecordAttempt(ctx context.Context, userId primitive.ObjectID) (models.PhoneVerification, error) {
+	var verification models.PhoneVerification
+	err := m.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": userId},
+		bson.M{"$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&verification)
+	return verification, mongoError(err)
+}
+
+func (m *mongoVerifications) Delete(ctx context.Context, userId primitive.ObjectID) error {
+	result, err := m.collection.DeleteOne(ctx, bson.M{"_id": userId})
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
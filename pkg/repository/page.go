@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The fields lists can be sorted on, each list supports some of them
+const (
+	SortByDate   = "date"
+	SortByName   = "name"
+	SortByRating = "rating"
+)
+
+// Sort orders a list on one field, the IDs of the items breaking ties in the same direction.
+// The zero value is the default order of each list.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// or returns def for the zero Sort
+func (s Sort) or(def Sort) Sort {
+	if s == (Sort{}) {
+		return def
+	}
+	return s
+}
+
+// Cursor is the position of an item in a sorted list, its value of the sorted field and its ID.
+// Value is a time.Time, string or float64, nil when the item has no value or the list is sorted on IDs alone.
+type Cursor struct {
+	Value interface{}
+	ID    primitive.ObjectID
+}
+
+// Page selects part of a sorted list: at most Limit items, following the item After points at.
+// The zero value selects the whole list in its default order.
+type Page struct {
+	Sort  Sort
+	After *Cursor
+	Limit int
+}
+
+// The default orders of the lists
+var (
+	bookingsSort    = Sort{Field: SortByDate}
+	ratesSort       = Sort{Field: SortByDate, Desc: true}
+	restaurantsSort = Sort{}
+)
+
+// BookingCursor is the position of the booking in a list of bookings sorted by sort
+func BookingCursor(booking models.Booking, sort Sort) Cursor {
+	return Cursor{Value: booking.Date, ID: booking.ID}
+}
+
+// RateCursor is the position of the rate in a list of rates sorted by sort
+func RateCursor(rate models.Rate, sort Sort) Cursor {
+	if sort.or(ratesSort).Field == SortByRating {
+		return Cursor{Value: float64(rate.Rating), ID: rate.ID}
+	}
+	return Cursor{Value: rate.Date, ID: rate.ID}
+}
+
+// RestaurantCursor is the position of the restaurant in a list of restaurants sorted by sort
+func RestaurantCursor(restaurant models.Restaurant, sort Sort) Cursor {
+	switch sort.or(restaurantsSort).Field {
+	case SortByName:
+		return Cursor{Value: restaurant.Name, ID: restaurant.ID}
+	case SortByRating:
+		if restaurant.RatingSummary == nil {
+			return Cursor{ID: restaurant.ID}
+		}
+		return Cursor{Value: restaurant.RatingSummary.BayesianScore, ID: restaurant.ID}
+	}
+	return Cursor{ID: restaurant.ID}
+}
+
+// sortFields maps the sortable fields of each collection to their document fields, the empty field sorts on IDs alone
+var (
+	bookingSortFields    = map[string]string{SortByDate: "date"}
+	rateSortFields       = map[string]string{SortByDate: "date", SortByRating: "rating"}
+	restaurantSortFields = map[string]string{"": "", SortByName: "name", SortByRating: "ratingSummary.bayesianScore"}
+)
+
+// checkSort rejects a sort on a field the collection cannot be sorted on
+func checkSort(sort Sort, fields map[string]string) error {
+	if _, ok := fields[sort.Field]; !ok {
+		return fmt.Errorf("repository: cannot sort on %q", sort.Field)
+	}
+	return nil
+}
+
+// findPage narrows the query to the page and returns the options sorting and limiting it.
+// fields maps the sortable fields of the collection to document fields.
+func findPage(query bson.M, page Page, fields map[string]string) (bson.M, *options.FindOptions, error) {
+	if err := checkSort(page.Sort, fields); err != nil {
+		return nil, nil, err
+	}
+	field := fields[page.Sort.Field]
+
+	direction := 1
+	if page.Sort.Desc {
+		direction = -1
+	}
+	order := bson.D{{Key: "_id", Value: direction}}
+	if field != "" {
+		order = append(bson.D{{Key: field, Value: direction}}, order...)
+	}
+	findOptions := options.Find().SetSort(order)
+	if page.Limit > 0 {
+		findOptions.SetLimit(int64(page.Limit))
+	}
+
+	if page.After != nil {
+		query = bson.M{"$and": bson.A{query, afterCursor(field, *page.After, page.Sort.Desc)}}
+	}
+	return query, findOptions, nil
+}
+
+// afterCursor matches the documents that follow the cursor. Mongo sorts a missing value before any other,
+// so documents without the field come first in ascending order and last in descending order.
+func afterCursor(field string, cursor Cursor, desc bool) bson.M {
+	beyond := "$gt"
+	if desc {
+		beyond = "$lt"
+	}
+	if field == "" {
+		return bson.M{"_id": bson.M{beyond: cursor.ID}}
+	}
+
+	tie := bson.M{field: cursor.Value, "_id": bson.M{beyond: cursor.ID}}
+	if cursor.Value == nil {
+		if desc {
+			return tie
+		}
+		return bson.M{"$or": bson.A{bson.M{field: bson.M{"$ne": nil}}, tie}}
+	}
+	following := bson.A{bson.M{field: bson.M{beyond: cursor.Value}}, tie}
+	if desc {
+		following = append(following, bson.M{field: nil})
+	}
+	return bson.M{"$or": following}
+}
+
+// pageIndexes sorts the positions of the items by their cursors and returns those on the page, for the memory store
+func pageIndexes(cursors []Cursor, page Page) []int {
+	indexes := make([]int, len(cursors))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return compareCursors(cursors[indexes[i]], cursors[indexes[j]], page.Sort.Desc) < 0
+	})
+
+	if page.After != nil {
+		first := sort.Search(len(indexes), func(i int) bool {
+			return compareCursors(cursors[indexes[i]], *page.After, page.Sort.Desc) > 0
+		})
+		indexes = indexes[first:]
+	}
+	if page.Limit > 0 && len(indexes) > page.Limit {
+		indexes = indexes[:page.Limit]
+	}
+	return indexes
+}
+
+// compareCursors orders two positions the way Mongo sorts them
+func compareCursors(a, b Cursor, desc bool) int {
+	result := compareValues(a.Value, b.Value)
+	if result == 0 {
+		result = strings.Compare(a.ID.Hex(), b.ID.Hex())
+	}
+	if desc {
+		return -result
+	}
+	return result
+}
+
+// compareValues orders values of different types the way Mongo does, missing values before numbers, strings and times
+func compareValues(a, b interface{}) int {
+	if rankA, rankB := typeRank(a), typeRank(b); rankA != rankB {
+		return rankA - rankB
+	}
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(a, b.(string))
+	case float64:
+		switch b := b.(float64); {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	}
+	return 0
+}
+
+func typeRank(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case float64:
+		return 1
+	case string:
+		return 2
+	case time.Time:
+		return 3
+	}
+	panic(fmt.Sprintf("repository: cannot sort on values of type %T", value))
+}
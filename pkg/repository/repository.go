@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"book-and-rate/pkg/models"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotFound is returned when no document matches the lookup
+	ErrNotFound = errors.New("repository: not found")
+	// ErrDuplicate is returned when a write would break a uniqueness constraint
+	ErrDuplicate = errors.New("repository: duplicate")
+	// ErrConflict is returned when a conditional write finds the document in another state
+	ErrConflict = errors.New("repository: conflict")
+	// ErrTaken is returned when a booking asks for a table another active booking holds for part of its time
+	ErrTaken = errors.New("repository: taken")
+	// ErrUnavailable wraps the errors of storage that cannot be reached or did not answer in time
+	ErrUnavailable = errors.New("repository: unavailable")
+)
+
+// Store groups the repositories of every aggregate
+type Store struct {
+	Users         UserRepository
+	Restaurants   RestaurantRepository
+	Bookings      BookingRepository
+	Rates         RateRepository
+	Tokens        TokenRepository
+	Verifications VerificationRepository
+	Resets        PasswordResetRepository
+	Database      Database
+}
+
+// Database is the storage backing the repositories
+type Database interface {
+	// Ping checks that the storage answers
+	Ping(ctx context.Context) error
+	// CheckMigrations fails while the schema migrations the repositories rely on are not all applied
+	CheckMigrations(ctx context.Context) error
+}
+
+type UserRepository interface {
+	// Create inserts the user and sets its ID
+	Create(ctx context.Context, user *models.User) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.User, error)
+	FindByPhone(ctx context.Context, phone string) (models.User, error)
+	Update(ctx context.Context, user models.User) error
+	// MarkPhoneVerified records that the user confirmed the phone number; ErrNotFound means the user is gone or changed number since
+	MarkPhoneVerified(ctx context.Context, id primitive.ObjectID, phone string, at time.Time) error
+	// SetPassword replaces the password hash of the user
+	SetPassword(ctx context.Context, id primitive.ObjectID, hashedPassword string) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// RestaurantFilter narrows down List, the zero value lists every restaurant.
+// The rating bounds apply to the mean rating, zero bounds do not filter and unrated restaurants only pass without bounds.
+type RestaurantFilter struct {
+	WithHours bool
+	MinRating float64
+	MaxRating float64
+	Page      Page
+}
+
+// RestaurantRepository also owns the tables of the restaurants
+type RestaurantRepository interface {
+	// Create inserts the restaurant and sets its ID
+	Create(ctx context.Context, restaurant *models.Restaurant) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.Restaurant, error)
+	FindByPhone(ctx context.Context, phone string) (models.Restaurant, error)
+	// List returns the matching restaurants in the order of the page, by ID unless another order is asked for
+	List(ctx context.Context, filter RestaurantFilter) ([]models.Restaurant, error)
+	// Update writes the restaurant's own fields, leaving its hours and rating summary alone
+	Update(ctx context.Context, restaurant models.Restaurant) error
+	UpdateHours(ctx context.Context, id primitive.ObjectID, hours models.OpeningHours) error
+	// ApplyRatingChange atomically adds and removes ratings from the rating summary, zero meaning none
+	ApplyRatingChange(ctx context.Context, id primitive.ObjectID, added, removed int, ratedAt *time.Time) error
+	// SetPassword replaces the password hash of the restaurant
+	SetPassword(ctx context.Context, id primitive.ObjectID, hashedPassword string) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+
+	// CreateTable inserts the table and sets its ID
+	CreateTable(ctx context.Context, table *models.Table) error
+	ListTables(ctx context.Context, restaurantId primitive.ObjectID) ([]models.Table, error)
+	UpdateTable(ctx context.Context, table models.Table) error
+	DeleteTable(ctx context.Context, restaurantId, tableId primitive.ObjectID) error
+}
+
+// BookingFilter narrows down List, zero fields do not filter.
+// Bookings overlapping [start, end) are found with DateTo set to end and EndsAfter set to start.
+type BookingFilter struct {
+	RestaurantID primitive.ObjectID
+	UserID       primitive.ObjectID
+	ExcludeID    primitive.ObjectID
+	Statuses     []models.BookingStatus
+	DateFrom     time.Time
+	DateTo       time.Time
+	EndsAfter    time.Time
+	Page         Page
+}
+
+// BookingRepository keeps active bookings from sharing a table: while a booking is active it holds its tables
+// from its date to its end, and writes that would give another booking one of them fail with ErrTaken
+type BookingRepository interface {
+	// Create inserts the booking and sets its ID
+	Create(ctx context.Context, booking *models.Booking) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.Booking, error)
+	// List returns the matching bookings in the order of the page, by date unless another order is asked for
+	List(ctx context.Context, filter BookingFilter) ([]models.Booking, error)
+	// Update writes the details and tables of the booking if it is still in booking.Status, ErrConflict otherwise.
+	// The status and history are left alone, they only change through Transition.
+	Update(ctx context.Context, booking models.Booking) error
+	// Transition moves the booking to change.To if it is still in the from state, ErrConflict otherwise.
+	// A booking leaving the active states releases its tables.
+	Transition(ctx context.Context, id primitive.ObjectID, from models.BookingStatus, change models.StatusChange) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// RateFilter narrows down List, zero fields do not filter
+type RateFilter struct {
+	RestaurantID primitive.ObjectID
+	BookingID    primitive.ObjectID
+	MinRating    int
+	MaxRating    int
+	DateFrom     time.Time
+	DateTo       time.Time
+	Page         Page
+}
+
+type RateRepository interface {
+	// Create inserts the rate and sets its ID, ErrDuplicate means its booking is already rated
+	Create(ctx context.Context, rate *models.Rate) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.Rate, error)
+	// List returns the matching rates in the order of the page, newest first unless another order is asked for
+	List(ctx context.Context, filter RateFilter) ([]models.Rate, error)
+	// Update writes the rate if it still has the previous rating, ErrConflict otherwise, so the rating summary can be
+	// moved from the rating the write replaced
+	Update(ctx context.Context, rate models.Rate, previousRating int) error
+	// Delete removes the rate if it still has the rating, ErrConflict otherwise
+	Delete(ctx context.Context, id primitive.ObjectID, rating int) error
+}
+
+type TokenRepository interface {
+	Create(ctx context.Context, token models.RefreshToken) error
+	FindByTokenID(ctx context.Context, tokenId string) (models.RefreshToken, error)
+	// Consume marks an unused, unrevoked token as used; ErrNotFound means it cannot be used
+	Consume(ctx context.Context, tokenId string, at time.Time) (models.RefreshToken, error)
+	SetReplacedBy(ctx context.Context, tokenId, replacedBy string) error
+	RevokeFamily(ctx context.Context, familyId string, at time.Time) error
+	RevokeUser(ctx context.Context, userId string, at time.Time) error
+	// DeleteExpired removes the tokens that expired before the time and returns how many were removed
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// SendLimits hold back the verification codes sent to a user: one every Cooldown and MaxSends in a rolling
+// models.VerificationSendWindow
+type SendLimits struct {
+	Cooldown time.Duration
+	MaxSends int
+}
+
+// VerificationRepository holds the phone verification each user has in progress
+type VerificationRepository interface {
+	// Start atomically replaces the user's verification in progress with one sent at its SentAt, counting it in the
+	// window of the sends before it. ErrConflict means the limits hold it back, concurrent requests send one code.
+	Start(ctx context.Context, verification models.PhoneVerification, limits SendLimits) error
+	// Save replaces the user's verification in progress as it is
+	Save(ctx context.Context, verification models.PhoneVerification) error
+	FindByUser(ctx context.Context, userId primitive.ObjectID) (models.PhoneVerification, error)
+	// RecordAttempt counts a confirmation attempt and returns the verification with the attempt included
+	RecordAttempt(ctx context.Context, userId primitive.ObjectID) (models.PhoneVerification, error)
+	Delete(ctx context.Context, userId primitive.ObjectID) error
+}
+
+// PasswordResetRepository holds the password reset tokens sent to users and restaurants
+type PasswordResetRepository interface {
+	Create(ctx context.Context, reset models.PasswordReset) error
+	// Latest returns the reset most recently requested for the account
+	Latest(ctx context.Context, principalType string, accountId primitive.ObjectID) (models.PasswordReset, error)
+	// Consume marks an unused, unexpired reset as used; ErrNotFound means it cannot be used
+	Consume(ctx context.Context, principalType, tokenHash string, at time.Time) (models.PasswordReset, error)
+	// DeleteAccount removes every reset of the account, used or not
+	DeleteAccount(ctx context.Context, principalType string, accountId primitive.ObjectID) error
+}
@@ -0,0 +1,43 @@
+// Package requestid tags each request with an ID that is returned to the client and written to the logs,
+// so a failure a client reports can be found again.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+)
+
+// Header carries the request ID, clients may send their own and always get it back
+const Header = "X-Request-ID"
+
+// valid limits the IDs accepted from clients to what is safe to log and echo
+var valid = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type contextKey struct{}
+
+// New returns a random request ID
+func New() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether an ID sent by a client can be used as is
+func Valid(id string) bool {
+	return valid.MatchString(id)
+}
+
+// NewContext returns a copy of ctx carrying the request ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID stored by NewContext, or the empty string
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"book-and-rate/pkg/handlers"
+	"github.com/gorilla/mux"
+)
+
+func AvailabilityRoutes(router *mux.Router, availability *handlers.AvailabilityHandler) {
+	router.HandleFunc("/availability", availability.SearchAvailabilityHandler).Methods("GET")
+	router.HandleFunc("/restaurants/{id}/availability", availability.GetAvailabilityHandler).Methods("GET")
+}
@@ -0,0 +1,202 @@
+package routes_test
+
+import (
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/repository"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type restaurantAvailability struct {
+	RestaurantID primitive.ObjectID
+	Name         string
+	Slots        []dto.SlotResponse
+}
+
+type availabilityPage struct {
+	Items []restaurantAvailability
+	Next  string
+}
+
+func TestGetAvailability(t *testing.T) {
+	f := newFixture(t)
+	path := "/restaurants/" + f.restaurantId.Hex() + "/availability"
+	date := tomorrowAt(0).Format("2006-01-02")
+
+	var slots []dto.SlotResponse
+	f.expect(f.do("GET", path+"?date="+date+"&partySize=2", "", nil), http.StatusOK, &slots)
+	if len(slots) != 0 {
+		t.Errorf("a restaurant without opening hours should have no slots, got %d", len(slots))
+	}
+
+	if err := f.store.Restaurants.UpdateHours(context.Background(), f.restaurantId, everyDay("18:00", "22:00")); err != nil {
+		t.Fatal(err)
+	}
+	f.book(f.userId, f.restaurantId, f.user.AccessToken, tomorrowAt(19), 2)
+
+	// Slots every 30 minutes from 18:00 to 21:30; the only table is held from 19:00 to 21:00
+	rec := f.do("GET", path+"?date="+date+"&partySize=2", "", nil)
+	if strings.Contains(rec.Body.String(), "tableIds") {
+		t.Errorf("the public slots should not name the tables, got %s", rec.Body)
+	}
+	f.expect(rec, http.StatusOK, &slots)
+	want := []time.Time{tomorrowAt(21), tomorrowAt(21).Add(30 * time.Minute)}
+	if len(slots) != len(want) {
+		t.Fatalf("expected %d slots, got %+v", len(want), slots)
+	}
+	for i, slot := range slots {
+		if !slot.Time.Equal(want[i]) {
+			t.Errorf("slot %d: expected %v, got %+v", i, want[i], slot)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"malformed ID", "/restaurants/nope/availability?date=" + date + "&partySize=2", http.StatusBadRequest},
+		{"unknown restaurant", "/restaurants/" + primitive.NewObjectID().Hex() + "/availability?date=" + date + "&partySize=2", http.StatusNotFound},
+		{"missing party size", path + "?date=" + date, http.StatusBadRequest},
+		{"party too large", path + "?date=" + date + "&partySize=" + strconv.Itoa(models.MaxPartySize+1), http.StatusBadRequest},
+		{"malformed date", path + "?date=tomorrow&partySize=2", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := f.with(t)
+			f.expect(f.do("GET", tt.path, "", nil), tt.status, nil)
+		})
+	}
+}
+
+func TestSearchAvailability(t *testing.T) {
+	f := newFixture(t)
+	openId, openToken := f.registerRestaurant()
+	f.addTable(openId, openToken.AccessToken, 2)
+	ctx := context.Background()
+	if err := f.store.Restaurants.UpdateHours(ctx, f.restaurantId, everyDay("18:00", "22:00")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.store.Restaurants.UpdateHours(ctx, openId, everyDay("18:00", "22:00")); err != nil {
+		t.Fatal(err)
+	}
+	f.book(f.userId, f.restaurantId, f.user.AccessToken, tomorrowAt(19), 2)
+
+	query := url.Values{"time": {tomorrowAt(19).Format(time.RFC3339)}, "partySize": {"2"}, "window": {"30"}}
+	var results availabilityPage
+	f.expect(f.do("GET", "/availability?"+query.Encode(), "", nil), http.StatusOK, &results)
+	if len(results.Items) != 1 || results.Items[0].RestaurantID != openId || len(results.Items[0].Slots) != 3 || results.Next != "" {
+		t.Errorf("expected only the free restaurant with 3 slots, got %+v", results)
+	}
+
+	query.Set("partySize", "4")
+	f.expect(f.do("GET", "/availability?"+query.Encode(), "", nil), http.StatusOK, &results)
+	if len(results.Items) != 0 {
+		t.Errorf("no restaurant seats 4 at that time, got %+v", results)
+	}
+
+	// The restaurants are searched a page at a time
+	query.Set("partySize", "2")
+	query.Set("limit", "1")
+	var first, second availabilityPage
+	f.expect(f.do("GET", "/availability?"+query.Encode(), "", nil), http.StatusOK, &first)
+	if first.Next == "" {
+		t.Fatalf("expected a link to the second restaurant, got %+v", first)
+	}
+	f.expect(f.do("GET", first.Next, "", nil), http.StatusOK, &second)
+	if found := append(first.Items, second.Items...); len(found) != 1 || found[0].RestaurantID != openId || second.Next != "" {
+		t.Errorf("expected the free restaurant over two pages, got %+v and %+v", first, second)
+	}
+
+	tests := []struct {
+		name  string
+		query url.Values
+	}{
+		{"malformed time", url.Values{"time": {"tomorrow"}, "partySize": {"2"}}},
+		{"missing party size", url.Values{"time": {tomorrowAt(19).Format(time.RFC3339)}}},
+		{"party too large", url.Values{"time": {tomorrowAt(19).Format(time.RFC3339)}, "partySize": {strconv.Itoa(models.MaxPartySize + 1)}}},
+		{"negative window", url.Values{"time": {tomorrowAt(19).Format(time.RFC3339)}, "partySize": {"2"}, "window": {"-5"}}},
+		{"window too wide", url.Values{"time": {tomorrowAt(19).Format(time.RFC3339)}, "partySize": {"2"}, "window": {"721"}}},
+		{"malformed cursor", url.Values{"time": {tomorrowAt(19).Format(time.RFC3339)}, "partySize": {"2"}, "after": {"nope"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := f.with(t)
+			f.expect(f.do("GET", "/availability?"+tt.query.Encode(), "", nil), http.StatusBadRequest, nil)
+		})
+	}
+}
+
+// failingTables fails listing the tables of one restaurant
+type failingTables struct {
+	repository.RestaurantRepository
+	restaurantId *primitive.ObjectID
+}
+
+func (f failingTables) ListTables(ctx context.Context, restaurantId primitive.ObjectID) ([]models.Table, error) {
+	if restaurantId == *f.restaurantId {
+		return nil, errors.New("tables unavailable")
+	}
+	return f.RestaurantRepository.ListTables(ctx, restaurantId)
+}
+
+func TestSearchAvailabilityFailsWithARestaurant(t *testing.T) {
+	store := repository.NewMemoryStore()
+	failing := new(primitive.ObjectID)
+	store.Restaurants = failingTables{store.Restaurants, failing}
+	f := newFixtureOver(t, store)
+	openId, openToken := f.registerRestaurant()
+	f.addTable(openId, openToken.AccessToken, 2)
+	ctx := context.Background()
+	for _, id := range []primitive.ObjectID{f.restaurantId, openId} {
+		if err := store.Restaurants.UpdateHours(ctx, id, everyDay("18:00", "22:00")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A restaurant that cannot be searched fails the page rather than passing for one without free slots
+	*failing = f.restaurantId
+	query := url.Values{"time": {tomorrowAt(19).Format(time.RFC3339)}, "partySize": {"2"}}
+	f.expect(f.do("GET", "/availability?"+query.Encode(), "", nil), http.StatusInternalServerError, nil)
+}
+
+func TestSearchAvailabilityAcrossMidnight(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	if err := f.store.Restaurants.UpdateHours(ctx, f.restaurantId, everyDay("18:00", "23:30")); err != nil {
+		t.Fatal(err)
+	}
+	lateId, late := f.registerRestaurant()
+	f.addTable(lateId, late.AccessToken, 2)
+	if err := f.store.Restaurants.UpdateHours(ctx, lateId, everyDay("20:00", "02:00")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Half past midnight reaches back to the evening before and on into the night, a day ahead so all of it is to come
+	requested := tomorrowAt(0).AddDate(0, 0, 1).Add(30 * time.Minute)
+	query := url.Values{"time": {requested.Format(time.RFC3339)}, "partySize": {"2"}, "window": {"90"}}
+	var results availabilityPage
+	f.expect(f.do("GET", "/availability?"+query.Encode(), "", nil), http.StatusOK, &results)
+
+	slots := make(map[primitive.ObjectID][]time.Time)
+	for _, result := range results.Items {
+		for _, slot := range result.Slots {
+			slots[result.RestaurantID] = append(slots[result.RestaurantID], slot.Time)
+		}
+	}
+	if got := slots[f.restaurantId]; len(got) != 1 || !got[0].Equal(requested.Add(-90*time.Minute)) {
+		t.Errorf("expected the 23:00 last seating of the evening before, got %v", got)
+	}
+	if got := slots[lateId]; len(got) != 6 || !got[len(got)-1].Equal(requested.Add(time.Hour)) {
+		t.Errorf("expected the slots from 23:00 to 01:30 running past midnight, got %v", got)
+	}
+}
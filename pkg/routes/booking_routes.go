@@ -2,21 +2,24 @@ package routes
 
 import (
 	"book-and-rate/pkg/handlers"
-	middleware "book-and-rate/pkg/middlewares"
 	"github.com/gorilla/mux"
 )
 
-func BookingRoutes(router *mux.Router) {
-	router.HandleFunc("/bookings", handlers.CreateBookingHandler).Methods("POST")
-
+func BookingRoutes(router *mux.Router, bookings *handlers.BookingHandler, authenticate mux.MiddlewareFunc) {
 	subRouter := router.PathPrefix("/bookings").Subrouter()
-	subRouter.Use(middleware.AuthenticationMiddleware)
-	subRouter.HandleFunc("/{id}", handlers.GetBookingHandler).Methods("GET")
-	subRouter.HandleFunc("/{id}", handlers.UpdateBookingHandler).Methods("PUT")
-	subRouter.HandleFunc("/{id}", handlers.DeleteBookingHandler).Methods("DELETE")
-	subRouter.HandleFunc("/{id}/cancel", handlers.CancelBookingHandler).Methods("PUT")
-	subRouter.HandleFunc("/restaurants/{restaurantId}/bookings", handlers.GetBookingsForRestaurant).Methods("GET")
-	subRouter.HandleFunc("/restaurants/{restaurantId}/active-bookings", handlers.GetActiveBookingsForRestaurant).Methods("GET")
-	subRouter.HandleFunc("/users/{userId}/future-bookings", handlers.GetFutureBookingsForUser).Methods("GET")
-	subRouter.HandleFunc("/restaurants/{restaurantId}/past-bookings", handlers.GetPastBookingsForRestaurant).Methods("GET")
+	subRouter.Use(authenticate)
+	subRouter.HandleFunc("", bookings.CreateBookingHandler).Methods("POST")
+	subRouter.HandleFunc("/{id}", bookings.GetBookingHandler).Methods("GET")
+	subRouter.HandleFunc("/{id}", bookings.UpdateBookingHandler).Methods("PUT")
+	subRouter.HandleFunc("/{id}", bookings.PatchBookingHandler).Methods("PATCH")
+	subRouter.HandleFunc("/{id}", bookings.DeleteBookingHandler).Methods("DELETE")
+	subRouter.HandleFunc("/{id}/confirm", bookings.ConfirmBookingHandler).Methods("PUT")
+	subRouter.HandleFunc("/{id}/seat", bookings.SeatBookingHandler).Methods("PUT")
+	subRouter.HandleFunc("/{id}/complete", bookings.CompleteBookingHandler).Methods("PUT")
+	subRouter.HandleFunc("/{id}/no-show", bookings.NoShowBookingHandler).Methods("PUT")
+	subRouter.HandleFunc("/{id}/cancel", bookings.CancelBookingHandler).Methods("PUT")
+	subRouter.HandleFunc("/restaurants/{restaurantId}/bookings", bookings.GetBookingsForRestaurant).Methods("GET")
+	subRouter.HandleFunc("/restaurants/{restaurantId}/active-bookings", bookings.GetActiveBookingsForRestaurant).Methods("GET")
+	subRouter.HandleFunc("/users/{userId}/future-bookings", bookings.GetFutureBookingsForUser).Methods("GET")
+	subRouter.HandleFunc("/restaurants/{restaurantId}/past-bookings", bookings.GetPastBookingsForRestaurant).Methods("GET")
 }
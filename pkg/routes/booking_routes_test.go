@@ -0,0 +1,544 @@
+package routes_test
+
+import (
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/repository"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCreateBooking(t *testing.T) {
+	f := newFixture(t)
+	_, other := f.registerUser()
+	ctx := context.Background()
+
+	booking := func(change func(*dto.BookingRequest)) dto.BookingRequest {
+		b := dto.BookingRequest{UserID: f.userId, RestaurantID: f.restaurantId, Date: tomorrowAt(19), PartySize: 2}
+		if change != nil {
+			change(&b)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		body   interface{}
+		status int
+	}{
+		{"no token", "", booking(nil), http.StatusUnauthorized},
+		{"other user", other.AccessToken, booking(nil), http.StatusForbidden},
+		{"malformed body", f.user.AccessToken, "{", http.StatusBadRequest},
+		{"empty party", f.user.AccessToken, booking(func(b *dto.BookingRequest) { b.PartySize = 0 }), http.StatusBadRequest},
+		{"party too large", f.user.AccessToken, booking(func(b *dto.BookingRequest) { b.PartySize = models.MaxPartySize + 1 }), http.StatusBadRequest},
+		{"unknown occasion", f.user.AccessToken, booking(func(b *dto.BookingRequest) { b.Occasion = "wake" }), http.StatusBadRequest},
+		{"invalid contact phone", f.user.AccessToken, booking(func(b *dto.BookingRequest) { b.ContactPhone = "call me" }), http.StatusBadRequest},
+		{"end before start", f.user.AccessToken, booking(func(b *dto.BookingRequest) { b.EndDate = b.Date.Add(-1) }), http.StatusBadRequest},
+		{"date in the past", f.user.AccessToken, booking(func(b *dto.BookingRequest) { b.Date = time.Now().Add(-time.Hour) }), http.StatusBadRequest},
+		{"no date", f.user.AccessToken, booking(func(b *dto.BookingRequest) { b.Date = time.Time{} }), http.StatusBadRequest},
+		{"notes too long", f.user.AccessToken, booking(func(b *dto.BookingRequest) { b.SpecialRequests = strings.Repeat("x", 501) }), http.StatusBadRequest},
+		{"dietary notes too long", f.user.AccessToken, booking(func(b *dto.BookingRequest) { b.DietaryNotes = strings.Repeat("é", models.MaxBookingNoteLen+1) }), http.StatusBadRequest},
+		{"unknown restaurant", f.user.AccessToken, booking(func(b *dto.BookingRequest) { b.RestaurantID = primitive.NewObjectID() }), http.StatusBadRequest},
+		{"unknown guest", f.restaurant.AccessToken, booking(func(b *dto.BookingRequest) { b.UserID = primitive.NewObjectID() }), http.StatusBadRequest},
+		{"no table large enough", f.user.AccessToken, booking(func(b *dto.BookingRequest) { b.PartySize = 5 }), http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := f.with(t)
+			f.expect(f.do("POST", "/bookings", tt.token, tt.body), tt.status, nil)
+		})
+	}
+
+	bookingId := f.book(f.userId, f.restaurantId, f.user.AccessToken, tomorrowAt(19), 2)
+	created, err := f.store.Bookings.FindByID(ctx, bookingId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _ := f.store.Users.FindByID(ctx, f.userId)
+	if created.Status != models.BookingPending || len(created.History) != 1 {
+		t.Errorf("booking did not start pending: %+v", created)
+	}
+	if len(created.TableIDs) != 1 || created.TableIDs[0] != f.tableId {
+		t.Errorf("table was not allocated: %+v", created.TableIDs)
+	}
+	if created.ContactPhone != user.PhoneNumber {
+		t.Errorf("contact phone %q does not default to the account's %q", created.ContactPhone, user.PhoneNumber)
+	}
+
+	// The only table is now held, an overlapping booking has nowhere to go
+	f.expect(f.do("POST", "/bookings", f.user.AccessToken, booking(func(b *dto.BookingRequest) { b.Date = tomorrowAt(20) })), http.StatusConflict, nil)
+	// Restaurants may book on behalf of a guest once the table is free again
+	later := booking(func(b *dto.BookingRequest) { b.Date = tomorrowAt(21); b.ContactPhone = "+44 20 7946 0000" })
+	var id resource
+	f.expect(f.do("POST", "/bookings", f.restaurant.AccessToken, later), http.StatusCreated, &id)
+	if stored, _ := f.store.Bookings.FindByID(ctx, id.ID); stored.ContactPhone != "+442079460000" {
+		t.Errorf("expected the contact phone in E.164, got %q", stored.ContactPhone)
+	}
+}
+
+func TestCreateBookingLimitsItsLength(t *testing.T) {
+	f := newFixture(t)
+	request := dto.BookingRequest{UserID: f.userId, RestaurantID: f.restaurantId, Date: tomorrowAt(12), PartySize: 2}
+
+	// A booking cannot hold its table for years
+	for _, end := range []time.Time{request.Date.Add(models.MaxBookingDuration + time.Minute), request.Date.AddDate(5, 0, 0)} {
+		request.EndDate = end
+		var got dto.ErrorResponse
+		f.expect(f.do("POST", "/bookings", f.user.AccessToken, request), http.StatusBadRequest, &got)
+		if len(got.Fields) != 1 || got.Fields[0].Field != "endDate" {
+			t.Errorf("expected the end date to be refused, got %+v", got)
+		}
+	}
+
+	request.EndDate = request.Date.Add(models.MaxBookingDuration)
+	f.expect(f.do("POST", "/bookings", f.user.AccessToken, request), http.StatusCreated, nil)
+}
+
+func TestCreateBookingSharingAReservationSlot(t *testing.T) {
+	f := newFixture(t)
+	larger := f.addTable(f.restaurantId, f.restaurant.AccessToken, 6)
+	first := dto.BookingRequest{UserID: f.userId, RestaurantID: f.restaurantId, Date: tomorrowAt(18), EndDate: tomorrowAt(20).Add(2 * time.Minute), PartySize: 2}
+	f.expect(f.do("POST", "/bookings", f.user.AccessToken, first), http.StatusCreated, nil)
+
+	// Ending at 20:02 holds the table until 20:05, so a booking from 20:03 is seated at the other table
+	second := dto.BookingRequest{UserID: f.userId, RestaurantID: f.restaurantId, Date: tomorrowAt(20).Add(3 * time.Minute), PartySize: 2}
+	var created dto.BookingResponse
+	f.expect(f.do("POST", "/bookings", f.user.AccessToken, second), http.StatusCreated, &created)
+	if len(created.TableIDs) != 1 || created.TableIDs[0] != larger {
+		t.Errorf("expected the other table, got %+v", created.TableIDs)
+	}
+}
+
+func TestCreateBookingRespectsOpeningHours(t *testing.T) {
+	f := newFixture(t)
+	if err := f.store.Restaurants.UpdateHours(context.Background(), f.restaurantId, everyDay("12:00", "22:00")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		hour   int
+		minute int
+		status int
+	}{
+		{"before opening", 9, 0, http.StatusBadRequest},
+		{"off slot", 13, 10, http.StatusBadRequest},
+		{"after last seating", 21, 45, http.StatusBadRequest},
+		{"on a slot", 13, 30, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := f.with(t)
+			date := tomorrowAt(tt.hour).Add(time.Duration(tt.minute) * time.Minute)
+			f.expect(f.do("POST", "/bookings", f.user.AccessToken, dto.BookingRequest{
+				UserID: f.userId, RestaurantID: f.restaurantId, Date: date, PartySize: 2,
+			}), tt.status, nil)
+		})
+	}
+}
+
+func TestCreateBookingPastMidnight(t *testing.T) {
+	f := newFixture(t)
+	if err := f.store.Restaurants.UpdateHours(context.Background(), f.restaurantId, everyDay("18:00", "02:00")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		f.addTable(f.restaurantId, f.restaurant.AccessToken, 4)
+	}
+
+	tests := []struct {
+		name   string
+		hour   int
+		minute int
+		status int
+	}{
+		{"before opening", 17, 30, http.StatusBadRequest},
+		{"evening", 22, 0, http.StatusCreated},
+		{"after midnight", 0, 30, http.StatusCreated},
+		{"last seating", 1, 30, http.StatusCreated},
+		{"after last seating", 1, 45, http.StatusBadRequest},
+		{"after closing", 3, 0, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := f.with(t)
+			date := tomorrowAt(tt.hour).Add(time.Duration(tt.minute) * time.Minute)
+			f.expect(f.do("POST", "/bookings", f.user.AccessToken, dto.BookingRequest{
+				UserID: f.userId, RestaurantID: f.restaurantId, Date: date, PartySize: 2,
+			}), tt.status, nil)
+		})
+	}
+}
+
+// slowBookings takes its time to answer with the bookings overlapping a new one, the way a busy database would,
+// so concurrent requests all allocate before any of them writes
+type slowBookings struct {
+	repository.BookingRepository
+}
+
+func (s slowBookings) List(ctx context.Context, filter repository.BookingFilter) ([]models.Booking, error) {
+	bookings, err := s.BookingRepository.List(ctx, filter)
+	if !filter.EndsAfter.IsZero() {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return bookings, err
+}
+
+func TestCreateBookingConcurrently(t *testing.T) {
+	store := repository.NewMemoryStore()
+	store.Bookings = slowBookings{store.Bookings}
+	s := newTestServerOver(t, store)
+	userId, _ := s.registerUser()
+	restaurantId, restaurant := s.registerRestaurant()
+	s.addTable(restaurantId, restaurant.AccessToken, 4)
+	request := dto.BookingRequest{UserID: userId, RestaurantID: restaurantId, Date: tomorrowAt(19), PartySize: 2}
+
+	// Every request finds the single table free, only one of them may get it
+	const attempts = 8
+	statuses := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- s.do("POST", "/bookings", restaurant.AccessToken, request).Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusCreated] != 1 || counts[http.StatusConflict] != attempts-1 {
+		t.Errorf("expected one booking and %d conflicts, got %v", attempts-1, counts)
+	}
+}
+
+func TestGetBooking(t *testing.T) {
+	f := newFixture(t)
+	_, other := f.registerUser()
+	_, otherRestaurant := f.registerRestaurant()
+	bookingId := f.book(f.userId, f.restaurantId, f.user.AccessToken, tomorrowAt(19), 2)
+	path := "/bookings/" + bookingId.Hex()
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"guest", path, f.user.AccessToken, http.StatusOK},
+		{"restaurant", path, f.restaurant.AccessToken, http.StatusOK},
+		{"other user", path, other.AccessToken, http.StatusForbidden},
+		{"other restaurant", path, otherRestaurant.AccessToken, http.StatusForbidden},
+		{"malformed ID", "/bookings/nope", f.user.AccessToken, http.StatusBadRequest},
+		{"unknown booking", "/bookings/" + primitive.NewObjectID().Hex(), f.user.AccessToken, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := f.with(t)
+			f.expect(f.do("GET", tt.path, tt.token, nil), tt.status, nil)
+		})
+	}
+}
+
+func TestUpdateBooking(t *testing.T) {
+	f := newFixture(t)
+	otherUserId, other := f.registerUser()
+	bookingId := f.book(f.userId, f.restaurantId, f.user.AccessToken, tomorrowAt(19), 2)
+	path := "/bookings/" + bookingId.Hex()
+
+	update := dto.BookingRequest{UserID: f.userId, RestaurantID: f.restaurantId, Date: tomorrowAt(12), PartySize: 3, Occasion: "birthday"}
+	f.expect(f.do("PUT", path, other.AccessToken, update), http.StatusForbidden, nil)
+
+	// The guest and restaurant were checked when the booking was made and stay as they are
+	handedOver := update
+	handedOver.UserID = otherUserId
+	moved := update
+	moved.RestaurantID = primitive.NewObjectID()
+	for name, tt := range map[string]struct {
+		token string
+		body  dto.BookingRequest
+		field string
+	}{
+		"guest handing over":      {f.user.AccessToken, handedOver, "userId"},
+		"restaurant handing over": {f.restaurant.AccessToken, handedOver, "userId"},
+		"unknown guest":           {f.restaurant.AccessToken, func() dto.BookingRequest { b := update; b.UserID = primitive.NewObjectID(); return b }(), "userId"},
+		"moved restaurant":        {f.user.AccessToken, moved, "restaurantId"},
+	} {
+		var got dto.ErrorResponse
+		f.expect(f.do("PUT", path, tt.token, tt.body), http.StatusBadRequest, &got)
+		if len(got.Fields) != 1 || got.Fields[0].Field != tt.field {
+			t.Errorf("%s: expected %s to be refused, got %+v", name, tt.field, got)
+		}
+	}
+	tooLarge := update
+	tooLarge.PartySize = 6
+	f.expect(f.do("PUT", path, f.user.AccessToken, tooLarge), http.StatusConflict, nil)
+
+	// The booking may keep its own table when it moves
+	f.expect(f.do("PUT", path, f.user.AccessToken, update), http.StatusNoContent, nil)
+	var got dto.BookingResponse
+	f.expect(f.do("GET", path, f.user.AccessToken, nil), http.StatusOK, &got)
+	if got.PartySize != 3 || got.Occasion != "birthday" || !got.Date.Equal(tomorrowAt(12)) {
+		t.Errorf("update was not applied: %+v", got)
+	}
+	if got.Status != models.BookingPending || len(got.History) != 1 {
+		t.Errorf("update changed the state: %+v", got)
+	}
+
+	f.transition(bookingId, "confirm", f.restaurant.AccessToken)
+	f.transition(bookingId, "seat", f.restaurant.AccessToken)
+	f.expect(f.do("PUT", path, f.user.AccessToken, update), http.StatusConflict, nil)
+}
+
+func TestPatchBooking(t *testing.T) {
+	f := newFixture(t)
+	_, other := f.registerUser()
+	bookingId := f.book(f.userId, f.restaurantId, f.user.AccessToken, tomorrowAt(19), 2)
+	path := "/bookings/" + bookingId.Hex()
+	before, _ := f.store.Bookings.FindByID(context.Background(), bookingId)
+
+	f.expect(f.do("PATCH", path, other.AccessToken, map[string]interface{}{"partySize": 3}), http.StatusForbidden, nil)
+
+	tests := []struct {
+		name string
+		body interface{}
+	}{
+		{"owner", map[string]interface{}{"userId": primitive.NewObjectID()}},
+		{"restaurant", map[string]interface{}{"restaurantId": primitive.NewObjectID()}},
+		{"status", map[string]interface{}{"status": "confirmed"}},
+		{"unknown field", map[string]interface{}{"guests": 3}},
+		{"party too large", map[string]interface{}{"partySize": models.MaxPartySize + 1}},
+		{"empty date", map[string]interface{}{"date": time.Time{}}},
+		{"unknown occasion", map[string]interface{}{"occasion": "wake"}},
+		{"notes too long", map[string]interface{}{"specialRequests": strings.Repeat("x", models.MaxBookingNoteLen+1)}},
+		{"stay too long", map[string]interface{}{"endDate": tomorrowAt(19).AddDate(1, 0, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := f.with(t)
+			f.expect(f.do("PATCH", path, f.user.AccessToken, tt.body), http.StatusBadRequest, nil)
+		})
+	}
+
+	// Moving the booking keeps its guest, party and length
+	f.expect(f.do("PATCH", path, f.user.AccessToken, map[string]interface{}{"date": tomorrowAt(12)}), http.StatusNoContent, nil)
+	var got dto.BookingResponse
+	f.expect(f.do("GET", path, f.user.AccessToken, nil), http.StatusOK, &got)
+	if got.UserID != f.userId || got.RestaurantID != f.restaurantId || got.PartySize != 2 || got.ContactPhone != before.ContactPhone {
+		t.Errorf("patch changed more than the date: %+v", got)
+	}
+	if !got.Date.Equal(tomorrowAt(12)) || !got.EndDate.Equal(tomorrowAt(12).Add(before.EndDate.Sub(before.Date))) {
+		t.Errorf("booking was not moved: %+v", got)
+	}
+	if got.Status != models.BookingPending || len(got.History) != 1 {
+		t.Errorf("patch changed the state: %+v", got)
+	}
+
+	f.expect(f.do("PATCH", path, f.user.AccessToken, map[string]interface{}{"partySize": 6}), http.StatusConflict, nil)
+}
+
+// cancellingBookings lets the guest cancel every booking just before a change to it is written
+type cancellingBookings struct {
+	repository.BookingRepository
+}
+
+func (c cancellingBookings) Update(ctx context.Context, booking models.Booking) error {
+	change := models.StatusChange{From: booking.Status, To: models.BookingCancelledByGuest, At: time.Now(), ActorType: "user"}
+	if err := c.Transition(ctx, booking.ID, booking.Status, change); err != nil {
+		return err
+	}
+	return c.BookingRepository.Update(ctx, booking)
+}
+
+func TestUpdateBookingKeepsConcurrentTransition(t *testing.T) {
+	store := repository.NewMemoryStore()
+	store.Bookings = cancellingBookings{store.Bookings}
+	s := newTestServerOver(t, store)
+	userId, user := s.registerUser()
+	restaurantId, restaurant := s.registerRestaurant()
+	s.addTable(restaurantId, restaurant.AccessToken, 4)
+	bookingId := s.book(userId, restaurantId, user.AccessToken, tomorrowAt(19), 2)
+
+	s.expect(s.do("PATCH", "/bookings/"+bookingId.Hex(), user.AccessToken, map[string]interface{}{"partySize": 3}), http.StatusConflict, nil)
+	booking, err := store.Bookings.FindByID(context.Background(), bookingId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booking.Status != models.BookingCancelledByGuest || len(booking.History) != 2 || booking.PartySize != 2 {
+		t.Errorf("the cancellation was overwritten: %+v", booking)
+	}
+}
+
+func TestDeleteBooking(t *testing.T) {
+	f := newFixture(t)
+	_, other := f.registerUser()
+	bookingId := f.book(f.userId, f.restaurantId, f.user.AccessToken, tomorrowAt(19), 2)
+	path := "/bookings/" + bookingId.Hex()
+
+	f.expect(f.do("DELETE", path, other.AccessToken, nil), http.StatusForbidden, nil)
+	f.expect(f.do("DELETE", path, f.user.AccessToken, nil), http.StatusNoContent, nil)
+	f.expect(f.do("DELETE", path, f.user.AccessToken, nil), http.StatusNotFound, nil)
+}
+
+func TestBookingLifecycle(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  []string
+		action string
+		guest  bool
+		status int
+		want   models.BookingStatus
+	}{
+		{"restaurant confirms", nil, "confirm", false, http.StatusNoContent, models.BookingConfirmed},
+		{"guest cannot confirm", nil, "confirm", true, http.StatusForbidden, models.BookingPending},
+		{"restaurant seats", []string{"confirm"}, "seat", false, http.StatusNoContent, models.BookingSeated},
+		{"pending cannot be seated", nil, "seat", false, http.StatusConflict, models.BookingPending},
+		{"restaurant completes", []string{"confirm", "seat"}, "complete", false, http.StatusNoContent, models.BookingCompleted},
+		{"confirmed cannot complete", []string{"confirm"}, "complete", false, http.StatusConflict, models.BookingConfirmed},
+		{"restaurant records no-show", []string{"confirm"}, "no-show", false, http.StatusNoContent, models.BookingNoShow},
+		{"seated is no no-show", []string{"confirm", "seat"}, "no-show", false, http.StatusConflict, models.BookingSeated},
+		{"guest cancels", nil, "cancel", true, http.StatusNoContent, models.BookingCancelledByGuest},
+		{"restaurant cancels", []string{"confirm"}, "cancel", false, http.StatusNoContent, models.BookingCancelledByRestaurant},
+		{"seated cannot be cancelled", []string{"confirm", "seat"}, "cancel", true, http.StatusConflict, models.BookingSeated},
+		{"completed is final", []string{"confirm", "seat", "complete"}, "confirm", false, http.StatusConflict, models.BookingCompleted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			bookingId := f.book(f.userId, f.restaurantId, f.user.AccessToken, tomorrowAt(19), 2)
+			for _, action := range tt.setup {
+				f.transition(bookingId, action, f.restaurant.AccessToken)
+			}
+
+			token := f.restaurant.AccessToken
+			if tt.guest {
+				token = f.user.AccessToken
+			}
+			f.expect(f.do("PUT", "/bookings/"+bookingId.Hex()+"/"+tt.action, token, nil), tt.status, nil)
+
+			booking, err := f.store.Bookings.FindByID(context.Background(), bookingId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if booking.Status != tt.want {
+				t.Errorf("expected status %v, got %v", tt.want, booking.Status)
+			}
+			if len(booking.History) != len(tt.setup)+1+boolToInt(tt.status == http.StatusNoContent) {
+				t.Errorf("unexpected history %+v", booking.History)
+			}
+		})
+	}
+}
+
+func TestBookingLifecycleErrors(t *testing.T) {
+	f := newFixture(t)
+	_, other := f.registerUser()
+	bookingId := f.book(f.userId, f.restaurantId, f.user.AccessToken, tomorrowAt(19), 2)
+
+	f.expect(f.do("PUT", "/bookings/nope/confirm", f.restaurant.AccessToken, nil), http.StatusBadRequest, nil)
+	f.expect(f.do("PUT", "/bookings/"+primitive.NewObjectID().Hex()+"/confirm", f.restaurant.AccessToken, nil), http.StatusNotFound, nil)
+	f.expect(f.do("PUT", "/bookings/"+bookingId.Hex()+"/cancel", other.AccessToken, nil), http.StatusForbidden, nil)
+
+	// A cancelled booking frees its table
+	f.transition(bookingId, "cancel", f.user.AccessToken)
+	f.book(f.userId, f.restaurantId, f.user.AccessToken, tomorrowAt(19), 2)
+}
+
+func TestListBookings(t *testing.T) {
+	f := newFixture(t)
+	otherUserId, other := f.registerUser()
+	f.addTable(f.restaurantId, f.restaurant.AccessToken, 4)
+
+	active := f.book(f.userId, f.restaurantId, f.user.AccessToken, tomorrowAt(19), 2)
+	early := f.book(otherUserId, f.restaurantId, other.AccessToken, tomorrowAt(12), 2)
+	cancelled := f.book(f.userId, f.restaurantId, f.user.AccessToken, tomorrowAt(15), 2)
+	f.transition(cancelled, "cancel", f.user.AccessToken)
+
+	restaurantPath := "/bookings/restaurants/" + f.restaurantId.Hex()
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+		want   []primitive.ObjectID
+	}{
+		{"all bookings by date", restaurantPath + "/bookings", f.restaurant.AccessToken, http.StatusOK, []primitive.ObjectID{early, cancelled, active}},
+		{"active bookings", restaurantPath + "/active-bookings", f.restaurant.AccessToken, http.StatusOK, []primitive.ObjectID{early, active}},
+		{"past bookings", restaurantPath + "/past-bookings", f.restaurant.AccessToken, http.StatusOK, []primitive.ObjectID{cancelled}},
+		{"future bookings of the guest", "/bookings/users/" + f.userId.Hex() + "/future-bookings", f.user.AccessToken, http.StatusOK, []primitive.ObjectID{cancelled, active}},
+		{"guest reading restaurant bookings", restaurantPath + "/bookings", f.user.AccessToken, http.StatusForbidden, nil},
+		{"guest reading active bookings", restaurantPath + "/active-bookings", f.user.AccessToken, http.StatusForbidden, nil},
+		{"guest reading past bookings", restaurantPath + "/past-bookings", f.user.AccessToken, http.StatusForbidden, nil},
+		{"other guest's bookings", "/bookings/users/" + f.userId.Hex() + "/future-bookings", other.AccessToken, http.StatusForbidden, nil},
+		{"malformed restaurant ID", "/bookings/restaurants/nope/bookings", f.restaurant.AccessToken, http.StatusBadRequest, nil},
+		{"malformed user ID", "/bookings/users/nope/future-bookings", f.user.AccessToken, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := f.with(t)
+			var page dto.BookingPage
+			if tt.status != http.StatusOK {
+				f.expect(f.do("GET", tt.path, tt.token, nil), tt.status, nil)
+				return
+			}
+			f.expect(f.do("GET", tt.path, tt.token, nil), tt.status, &page)
+			bookings := page.Items
+			if len(bookings) != len(tt.want) {
+				t.Fatalf("expected %d bookings, got %d", len(tt.want), len(bookings))
+			}
+			for i, booking := range bookings {
+				if booking.ID != tt.want[i] {
+					t.Errorf("booking %d: expected %v, got %v", i, tt.want[i], booking.ID)
+				}
+			}
+		})
+	}
+
+	// Filters narrow the list and the next links page through what is left
+	var page dto.BookingPage
+	f.expect(f.do("GET", restaurantPath+"/bookings?status=cancelled_by_guest", f.restaurant.AccessToken, nil), http.StatusOK, &page)
+	if len(page.Items) != 1 || page.Items[0].ID != cancelled {
+		t.Errorf("expected the cancelled booking, got %+v", page.Items)
+	}
+	from := url.QueryEscape(tomorrowAt(14).Format(time.RFC3339))
+	f.expect(f.do("GET", restaurantPath+"/bookings?order=desc&from="+from, f.restaurant.AccessToken, nil), http.StatusOK, &page)
+	if len(page.Items) != 2 || page.Items[0].ID != active || page.Items[1].ID != cancelled {
+		t.Errorf("expected the bookings from 14:00 latest first, got %+v", page.Items)
+	}
+
+	var ids []primitive.ObjectID
+	for next := restaurantPath + "/bookings?limit=2"; next != ""; next = page.Next {
+		page = dto.BookingPage{}
+		f.expect(f.do("GET", next, f.restaurant.AccessToken, nil), http.StatusOK, &page)
+		for _, booking := range page.Items {
+			ids = append(ids, booking.ID)
+		}
+	}
+	if len(ids) != 3 || ids[0] != early || ids[1] != cancelled || ids[2] != active {
+		t.Errorf("expected every booking once in date order, got %v", ids)
+	}
+
+	// Active bookings cannot be asked for cancelled ones
+	for _, query := range []string{"/active-bookings?status=cancelled_by_guest", "/bookings?status=lost", "/bookings?sort=name", "/bookings?to=tomorrow"} {
+		f.expect(f.do("GET", restaurantPath+query, f.restaurant.AccessToken, nil), http.StatusBadRequest, nil)
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"book-and-rate/pkg/handlers"
+
+	"github.com/gorilla/mux"
+)
+
+// HealthPaths are the probe endpoints, they are left out of the request log
+var HealthPaths = []string{"/healthz", "/readyz", "/version"}
+
+func HealthRoutes(router *mux.Router, health *handlers.HealthHandler) {
+	router.HandleFunc("/healthz", health.LivenessHandler).Methods("GET")
+	router.HandleFunc("/readyz", health.ReadinessHandler).Methods("GET")
+	router.HandleFunc("/version", health.VersionHandler).Methods("GET")
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"book-and-rate/pkg/handlers"
+
+	"github.com/gorilla/mux"
+)
+
+func PasswordResetRoutes(router *mux.Router, resets *handlers.PasswordResetHandler) {
+	router.HandleFunc("/users/password/forgot", resets.ForgotUserPasswordHandler).Methods("POST")
+	router.HandleFunc("/users/password/reset", resets.ResetUserPasswordHandler).Methods("POST")
+	router.HandleFunc("/restaurants/password/forgot", resets.ForgotRestaurantPasswordHandler).Methods("POST")
+	router.HandleFunc("/restaurants/password/reset", resets.ResetRestaurantPasswordHandler).Methods("POST")
+}
@@ -0,0 +1,100 @@
+package routes_test
+
+import (
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/utils"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const newPassword = "n3w-s3cret-password"
+
+func TestUserPasswordReset(t *testing.T) {
+	s := newTestServer(t)
+	userId, tokens := s.registerUser()
+	user, _ := s.store.Users.FindByID(context.Background(), userId)
+
+	// Unknown numbers get the same answer as registered ones
+	s.expect(s.do("POST", "/users/password/forgot", "", map[string]string{"phone": nextPhone()}), http.StatusAccepted, nil)
+	s.expect(s.do("POST", "/users/password/forgot", "", map[string]string{"phone": "not a phone"}), http.StatusBadRequest, nil)
+
+	s.expect(s.do("POST", "/users/password/forgot", "", map[string]string{"phone": user.PhoneNumber}), http.StatusAccepted, nil)
+	token, ok := s.lastTexted(user.PhoneNumber, resetToken)
+	if !ok {
+		t.Fatal("no reset token was texted")
+	}
+	reset, err := s.store.Resets.Latest(context.Background(), "user", userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset.TokenHash == token {
+		t.Error("expected the reset token to be stored hashed")
+	}
+
+	s.expect(s.do("POST", "/users/password/reset", "", map[string]string{"token": "wrong", "password": newPassword}), http.StatusBadRequest, nil)
+	s.expect(s.do("POST", "/users/password/reset", "", map[string]string{"token": token}), http.StatusBadRequest, nil)
+	// A user token does not reset a restaurant password
+	s.expect(s.do("POST", "/restaurants/password/reset", "", map[string]string{"token": token, "password": newPassword}), http.StatusBadRequest, nil)
+
+	s.expect(s.do("POST", "/users/password/reset", "", map[string]string{"token": token, "password": newPassword}), http.StatusNoContent, nil)
+	s.expect(s.do("POST", "/users/password/reset", "", map[string]string{"token": token, "password": "again"}), http.StatusBadRequest, nil)
+
+	s.expect(s.do("POST", "/users/login", "", dto.LoginRequest{Phone: user.PhoneNumber, Password: testPassword}), http.StatusUnauthorized, nil)
+	s.expect(s.do("POST", "/users/login", "", dto.LoginRequest{Phone: user.PhoneNumber, Password: newPassword}), http.StatusOK, nil)
+
+	// Sessions started with the old password are over
+	s.expect(s.do("POST", "/refresh-token", "", map[string]string{"refreshToken": tokens.RefreshToken}), http.StatusUnauthorized, nil)
+}
+
+func TestRestaurantPasswordReset(t *testing.T) {
+	s := newTestServer(t)
+	restaurantId, tokens := s.registerRestaurant()
+	restaurant, _ := s.store.Restaurants.FindByID(context.Background(), restaurantId)
+
+	s.expect(s.do("POST", "/restaurants/password/forgot", "", map[string]string{"phone": restaurant.Phone}), http.StatusAccepted, nil)
+	token, ok := s.lastTexted(restaurant.Phone, resetToken)
+	if !ok {
+		t.Fatal("no reset token was texted")
+	}
+
+	s.expect(s.do("POST", "/users/password/reset", "", map[string]string{"token": token, "password": newPassword}), http.StatusBadRequest, nil)
+	s.expect(s.do("POST", "/restaurants/password/reset", "", map[string]string{"token": token, "password": newPassword}), http.StatusNoContent, nil)
+
+	s.expect(s.do("POST", "/restaurants/login", "", dto.LoginRequest{Phone: restaurant.Phone, Password: newPassword}), http.StatusOK, nil)
+	s.expect(s.do("POST", "/refresh-token", "", map[string]string{"refreshToken": tokens.RefreshToken}), http.StatusUnauthorized, nil)
+}
+
+func TestPasswordResetCooldown(t *testing.T) {
+	s := newTestServer(t)
+	userId, _ := s.registerUser()
+	user, _ := s.store.Users.FindByID(context.Background(), userId)
+
+	s.expect(s.do("POST", "/users/password/forgot", "", map[string]string{"phone": user.PhoneNumber}), http.StatusAccepted, nil)
+	first, _ := s.lastTexted(user.PhoneNumber, resetToken)
+
+	// Asking again straight away answers the same but texts nothing
+	s.expect(s.do("POST", "/users/password/forgot", "", map[string]string{"phone": user.PhoneNumber}), http.StatusAccepted, nil)
+	if latest, _ := s.lastTexted(user.PhoneNumber, resetToken); latest != first {
+		t.Error("expected no second token during the cooldown")
+	}
+}
+
+func TestExpiredPasswordReset(t *testing.T) {
+	s := newTestServer(t)
+	userId, _ := s.registerUser()
+
+	expired := models.PasswordReset{
+		TokenHash:     utils.HashToken("expired-token"),
+		AccountID:     userId,
+		PrincipalType: "user",
+		CreatedAt:     time.Now().Add(-2 * time.Hour),
+		ExpiresAt:     time.Now().Add(-time.Hour),
+	}
+	if err := s.store.Resets.Create(context.Background(), expired); err != nil {
+		t.Fatal(err)
+	}
+	s.expect(s.do("POST", "/users/password/reset", "", map[string]string{"token": "expired-token", "password": newPassword}), http.StatusBadRequest, nil)
+}
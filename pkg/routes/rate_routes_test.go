@@ -0,0 +1,339 @@
+package routes_test
+
+import (
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/repository"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type averageRating struct {
+	AverageRating float64 `json:"averageRating"`
+	Count         int     `json:"count"`
+}
+
+func TestCreateRate(t *testing.T) {
+	f := newFixture(t)
+	_, other := f.registerUser()
+	completed := f.completedBooking(tomorrowAt(12))
+	pending := f.book(f.userId, f.restaurantId, f.user.AccessToken, tomorrowAt(19), 2)
+
+	tests := []struct {
+		name   string
+		token  string
+		body   interface{}
+		status int
+	}{
+		{"no token", "", dto.RateRequest{BookingID: completed, Rating: 4}, http.StatusUnauthorized},
+		{"malformed body", f.user.AccessToken, "{", http.StatusBadRequest},
+		{"rating too low", f.user.AccessToken, dto.RateRequest{BookingID: completed, Rating: 0}, http.StatusBadRequest},
+		{"rating too high", f.user.AccessToken, dto.RateRequest{BookingID: completed, Rating: 6}, http.StatusBadRequest},
+		{"restaurant rating itself", f.restaurant.AccessToken, dto.RateRequest{BookingID: completed, Rating: 5}, http.StatusForbidden},
+		{"someone else's booking", other.AccessToken, dto.RateRequest{BookingID: completed, Rating: 1}, http.StatusForbidden},
+		{"missing booking", f.user.AccessToken, dto.RateRequest{Rating: 4}, http.StatusBadRequest},
+		{"unknown booking", f.user.AccessToken, dto.RateRequest{BookingID: primitive.NewObjectID(), Rating: 4}, http.StatusBadRequest},
+		{"booking not completed", f.user.AccessToken, dto.RateRequest{BookingID: pending, Rating: 4}, http.StatusConflict},
+		{"trailing brace", f.user.AccessToken, fmt.Sprintf(`{"bookingId":%q,"rating":4}}`, completed.Hex()), http.StatusBadRequest},
+		{"second value", f.user.AccessToken, fmt.Sprintf(`{"bookingId":%q,"rating":4} {}`, completed.Hex()), http.StatusBadRequest},
+		{"completed booking", f.user.AccessToken, dto.RateRequest{BookingID: completed, Rating: 4, Comment: "Lovely"}, http.StatusCreated},
+		{"booking already rated", f.user.AccessToken, dto.RateRequest{BookingID: completed, Rating: 5}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := f.with(t)
+			f.expect(f.do("POST", "/rates", tt.token, tt.body), tt.status, nil)
+		})
+	}
+
+	rates, err := f.store.Rates.List(context.Background(), repository.RateFilter{RestaurantID: f.restaurantId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 1 || rates[0].UserID != f.userId || rates[0].RestaurantID != f.restaurantId {
+		t.Errorf("rate was not tied to the guest and restaurant: %+v", rates)
+	}
+}
+
+func TestCreateRateConcurrently(t *testing.T) {
+	f := newFixture(t)
+	request := dto.RateRequest{BookingID: f.completedBooking(tomorrowAt(12)), Rating: 4}
+
+	const attempts = 8
+	statuses := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- f.do("POST", "/rates", f.user.AccessToken, request).Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusCreated] != 1 || counts[http.StatusConflict] != attempts-1 {
+		t.Errorf("expected one rate and %d conflicts, got %v", attempts-1, counts)
+	}
+
+	// Only the stored rate counts towards the summary
+	var got averageRating
+	f.expect(f.do("GET", "/rates/restaurants/"+f.restaurantId.Hex()+"/average-rating", f.user.AccessToken, nil), http.StatusOK, &got)
+	if got.Count != 1 || got.AverageRating != 4 {
+		t.Errorf("expected a single rating of 4, got %+v", got)
+	}
+}
+
+func TestRateMaintainsRatingSummary(t *testing.T) {
+	f := newFixture(t)
+	average := "/rates/restaurants/" + f.restaurantId.Hex() + "/average-rating"
+
+	var got averageRating
+	f.expect(f.do("GET", average, f.user.AccessToken, nil), http.StatusOK, &got)
+	if got.Count != 0 || got.AverageRating != 0 {
+		t.Errorf("unexpected average before any rate: %+v", got)
+	}
+
+	var first, second resource
+	f.expect(f.do("POST", "/rates", f.user.AccessToken, dto.RateRequest{BookingID: f.completedBooking(tomorrowAt(10)), Rating: 5}), http.StatusCreated, &first)
+	f.expect(f.do("POST", "/rates", f.user.AccessToken, dto.RateRequest{BookingID: f.completedBooking(tomorrowAt(13)), Rating: 2}), http.StatusCreated, &second)
+	f.expect(f.do("GET", average, f.user.AccessToken, nil), http.StatusOK, &got)
+	if got.Count != 2 || got.AverageRating != 3.5 {
+		t.Errorf("expected 2 rates averaging 3.5, got %+v", got)
+	}
+
+	f.expect(f.do("PUT", "/rates/"+second.ID.Hex(), f.user.AccessToken, dto.RateUpdateRequest{Rating: 3}), http.StatusNoContent, nil)
+	f.expect(f.do("GET", average, f.user.AccessToken, nil), http.StatusOK, &got)
+	if got.Count != 2 || got.AverageRating != 4 {
+		t.Errorf("expected 2 rates averaging 4 after the update, got %+v", got)
+	}
+
+	f.expect(f.do("DELETE", "/rates/"+first.ID.Hex(), f.user.AccessToken, nil), http.StatusNoContent, nil)
+	f.expect(f.do("GET", average, f.user.AccessToken, nil), http.StatusOK, &got)
+	if got.Count != 1 || got.AverageRating != 3 {
+		t.Errorf("expected 1 rate of 3 after the delete, got %+v", got)
+	}
+
+	restaurant, _ := f.store.Restaurants.FindByID(context.Background(), f.restaurantId)
+	summary := restaurant.RatingSummary
+	if summary == nil || summary.Histogram["3"] != 1 || summary.Histogram["5"] != 0 || summary.Histogram["2"] != 0 || summary.LastRatedAt == nil {
+		t.Errorf("unexpected rating summary %+v", summary)
+	}
+
+	f.expect(f.do("GET", "/rates/restaurants/nope/average-rating", f.user.AccessToken, nil), http.StatusBadRequest, nil)
+	f.expect(f.do("GET", "/rates/restaurants/"+primitive.NewObjectID().Hex()+"/average-rating", f.user.AccessToken, nil), http.StatusNotFound, nil)
+}
+
+// failingSummaries fails every rating summary change while fail is set
+type failingSummaries struct {
+	repository.RestaurantRepository
+	fail *atomic.Bool
+}
+
+func (f failingSummaries) ApplyRatingChange(ctx context.Context, id primitive.ObjectID, added, removed int, ratedAt *time.Time) error {
+	if f.fail.Load() {
+		return errors.New("summary unavailable")
+	}
+	return f.RestaurantRepository.ApplyRatingChange(ctx, id, added, removed, ratedAt)
+}
+
+func TestRateUndoneWhenSummaryFails(t *testing.T) {
+	store := repository.NewMemoryStore()
+	fail := new(atomic.Bool)
+	store.Restaurants = failingSummaries{store.Restaurants, fail}
+	f := newFixtureOver(t, store)
+	average := "/rates/restaurants/" + f.restaurantId.Hex() + "/average-rating"
+	rated := f.completedBooking(tomorrowAt(10))
+	var created resource
+	f.expect(f.do("POST", "/rates", f.user.AccessToken, dto.RateRequest{BookingID: rated, Rating: 4}), http.StatusCreated, &created)
+	path := "/rates/" + created.ID.Hex()
+
+	fail.Store(true)
+	unrated := f.completedBooking(tomorrowAt(13))
+	f.expect(f.do("POST", "/rates", f.user.AccessToken, dto.RateRequest{BookingID: unrated, Rating: 1}), http.StatusInternalServerError, nil)
+	f.expect(f.do("PUT", path, f.user.AccessToken, dto.RateUpdateRequest{Rating: 2}), http.StatusInternalServerError, nil)
+	f.expect(f.do("DELETE", path, f.user.AccessToken, nil), http.StatusInternalServerError, nil)
+	fail.Store(false)
+
+	// Every write was undone, so the rates still agree with the summary
+	rates, err := store.Rates.List(context.Background(), repository.RateFilter{RestaurantID: f.restaurantId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 1 || rates[0].ID != created.ID || rates[0].Rating != 4 {
+		t.Errorf("expected only the first rate of 4, got %+v", rates)
+	}
+	var got averageRating
+	f.expect(f.do("GET", average, f.user.AccessToken, nil), http.StatusOK, &got)
+	if got.Count != 1 || got.AverageRating != 4 {
+		t.Errorf("expected a single rating of 4, got %+v", got)
+	}
+
+	// The booking whose rate was undone can still be rated
+	f.expect(f.do("POST", "/rates", f.user.AccessToken, dto.RateRequest{BookingID: unrated, Rating: 1}), http.StatusCreated, nil)
+}
+
+func TestChangeRateConcurrently(t *testing.T) {
+	f := newFixture(t)
+	average := "/rates/restaurants/" + f.restaurantId.Hex() + "/average-rating"
+	var created resource
+	f.expect(f.do("POST", "/rates", f.user.AccessToken, dto.RateRequest{BookingID: f.completedBooking(tomorrowAt(12)), Rating: 1}), http.StatusCreated, &created)
+	path := "/rates/" + created.ID.Hex()
+
+	// Concurrent updates either win or are told the rate changed, and the summary follows the winners
+	const attempts = 8
+	statuses := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(rating int) {
+			defer wg.Done()
+			statuses <- f.do("PUT", path, f.user.AccessToken, dto.RateUpdateRequest{Rating: rating}).Code
+		}(2 + i%4)
+	}
+	wg.Wait()
+	close(statuses)
+	for status := range statuses {
+		if status != http.StatusNoContent && status != http.StatusConflict {
+			t.Errorf("expected an update or a conflict, got %d", status)
+		}
+	}
+
+	var rate dto.RateResponse
+	f.expect(f.do("GET", path, f.user.AccessToken, nil), http.StatusOK, &rate)
+	var got averageRating
+	f.expect(f.do("GET", average, f.user.AccessToken, nil), http.StatusOK, &got)
+	if got.Count != 1 || got.AverageRating != float64(rate.Rating) {
+		t.Errorf("expected a single rating of %d, got %+v", rate.Rating, got)
+	}
+
+	// Only one of concurrent deletes removes the rate, the others find it gone
+	statuses = make(chan int, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- f.do("DELETE", path, f.user.AccessToken, nil).Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusNoContent] != 1 || counts[http.StatusNotFound] != attempts-1 {
+		t.Errorf("expected one delete and %d not found, got %v", attempts-1, counts)
+	}
+	f.expect(f.do("GET", average, f.user.AccessToken, nil), http.StatusOK, &got)
+	if got.Count != 0 {
+		t.Errorf("expected no rating after the delete, got %+v", got)
+	}
+}
+
+func TestGetUpdateDeleteRate(t *testing.T) {
+	f := newFixture(t)
+	_, other := f.registerUser()
+	var created resource
+	f.expect(f.do("POST", "/rates", f.user.AccessToken, dto.RateRequest{BookingID: f.completedBooking(tomorrowAt(12)), Rating: 4}), http.StatusCreated, &created)
+	path := "/rates/" + created.ID.Hex()
+
+	var rate dto.RateResponse
+	f.expect(f.do("GET", path, other.AccessToken, nil), http.StatusOK, &rate)
+	if rate.Rating != 4 {
+		t.Errorf("unexpected rate %+v", rate)
+	}
+	f.expect(f.do("GET", "/rates/nope", f.user.AccessToken, nil), http.StatusBadRequest, nil)
+	f.expect(f.do("GET", "/rates/"+primitive.NewObjectID().Hex(), f.user.AccessToken, nil), http.StatusNotFound, nil)
+
+	f.expect(f.do("PUT", path, other.AccessToken, dto.RateUpdateRequest{Rating: 1}), http.StatusForbidden, nil)
+	f.expect(f.do("PUT", path, f.user.AccessToken, dto.RateUpdateRequest{Rating: 9}), http.StatusBadRequest, nil)
+	f.expect(f.do("PUT", "/rates/"+primitive.NewObjectID().Hex(), f.user.AccessToken, dto.RateUpdateRequest{Rating: 1}), http.StatusNotFound, nil)
+
+	// Comments are limited in characters, not bytes
+	f.expect(f.do("PUT", path, f.user.AccessToken, dto.RateUpdateRequest{Rating: 4, Comment: strings.Repeat("é", models.MaxRateCommentLen)}), http.StatusNoContent, nil)
+	f.expect(f.do("PUT", path, f.user.AccessToken, dto.RateUpdateRequest{Rating: 4, Comment: strings.Repeat("é", models.MaxRateCommentLen+1)}), http.StatusBadRequest, nil)
+
+	// The author and booking cannot be changed through an update
+	f.expect(f.do("PUT", path, f.user.AccessToken, dto.RateRequest{Rating: 2, BookingID: primitive.NewObjectID()}), http.StatusBadRequest, nil)
+	f.expect(f.do("PUT", path, f.user.AccessToken, dto.RateUpdateRequest{Rating: 2, Comment: "Cold soup"}), http.StatusNoContent, nil)
+	f.expect(f.do("GET", path, f.user.AccessToken, nil), http.StatusOK, &rate)
+	if rate.Rating != 2 || rate.Comment != "Cold soup" || rate.UserID != f.userId || rate.RestaurantID != f.restaurantId {
+		t.Errorf("unexpected rate after update %+v", rate)
+	}
+
+	// A partial update keeps the fields it leaves out
+	f.expect(f.do("PATCH", path, f.user.AccessToken, map[string]string{"comment": "Warm soup"}), http.StatusNoContent, nil)
+	f.expect(f.do("GET", path, f.user.AccessToken, nil), http.StatusOK, &rate)
+	if rate.Rating != 2 || rate.Comment != "Warm soup" {
+		t.Errorf("unexpected rate after patch %+v", rate)
+	}
+	f.expect(f.do("PATCH", path, f.user.AccessToken, map[string]interface{}{"rating": 0}), http.StatusBadRequest, nil)
+	f.expect(f.do("PATCH", path, f.user.AccessToken, map[string]interface{}{"bookingId": primitive.NewObjectID()}), http.StatusBadRequest, nil)
+	f.expect(f.do("PATCH", path, other.AccessToken, map[string]interface{}{"rating": 1}), http.StatusForbidden, nil)
+
+	f.expect(f.do("DELETE", path, other.AccessToken, nil), http.StatusForbidden, nil)
+	f.expect(f.do("DELETE", path, f.user.AccessToken, nil), http.StatusNoContent, nil)
+	f.expect(f.do("DELETE", path, f.user.AccessToken, nil), http.StatusNotFound, nil)
+}
+
+func TestListRates(t *testing.T) {
+	f := newFixture(t)
+	for i, hour := range []int{10, 13, 16} {
+		f.expect(f.do("POST", "/rates", f.user.AccessToken, dto.RateRequest{BookingID: f.completedBooking(tomorrowAt(hour)), Rating: i + 1}), http.StatusCreated, nil)
+	}
+
+	path := "/rates/restaurants/" + f.restaurantId.Hex() + "/rates"
+	var page dto.RatePage
+	f.expect(f.do("GET", path, f.user.AccessToken, nil), http.StatusOK, &page)
+	if len(page.Items) != 3 || page.Items[0].Rating != 3 || page.Next != "" {
+		t.Errorf("expected the 3 rates newest first, got %+v", page)
+	}
+	f.expect(f.do("GET", "/rates/restaurants/nope/rates", f.user.AccessToken, nil), http.StatusBadRequest, nil)
+
+	f.expect(f.do("GET", path+"?sort=rating&order=asc&minRating=2", f.user.AccessToken, nil), http.StatusOK, &page)
+	if len(page.Items) != 2 || page.Items[0].Rating != 2 || page.Items[1].Rating != 3 {
+		t.Errorf("expected the rates of at least 2 by rating, got %+v", page.Items)
+	}
+
+	// Following the next links visits every rate once
+	var ratings []int
+	for next := path + "?sort=rating&limit=1"; next != ""; next = page.Next {
+		page = dto.RatePage{}
+		f.expect(f.do("GET", next, f.user.AccessToken, nil), http.StatusOK, &page)
+		for _, rate := range page.Items {
+			ratings = append(ratings, rate.Rating)
+		}
+	}
+	if fmt.Sprint(ratings) != "[3 2 1]" {
+		t.Errorf("expected the pages to hold ratings 3, 2 and 1, got %v", ratings)
+	}
+
+	for _, query := range []string{"limit=0", "limit=101", "sort=comment", "order=up", "minRating=6", "from=yesterday", "after=nope"} {
+		f.expect(f.do("GET", path+"?"+query, f.user.AccessToken, nil), http.StatusBadRequest, nil)
+	}
+
+	var rates []dto.RateResponse
+
+	f.expect(f.do("GET", "/rates/recent?limit=2", f.user.AccessToken, nil), http.StatusOK, &rates)
+	if len(rates) != 2 || rates[0].Rating != 3 || rates[1].Rating != 2 {
+		t.Errorf("expected the 2 most recent rates, got %+v", rates)
+	}
+	f.expect(f.do("GET", "/rates/recent?limit=zero", f.user.AccessToken, nil), http.StatusOK, &rates)
+	if len(rates) != 3 {
+		t.Errorf("an invalid limit should fall back to the default, got %d rates", len(rates))
+	}
+}
@@ -2,9 +2,13 @@ package routes
 
 import (
 	"book-and-rate/pkg/handlers"
+	"net/http"
+
 	"github.com/gorilla/mux"
 )
 
-func RefreshTokenRoutes(router *mux.Router) {
-	router.HandleFunc("/refresh-token", handlers.RefreshTokenHandler).Methods("POST")
+func RefreshTokenRoutes(router *mux.Router, tokens *handlers.TokenHandler, authenticate mux.MiddlewareFunc) {
+	router.HandleFunc("/refresh-token", tokens.RefreshTokenHandler).Methods("POST")
+	router.HandleFunc("/logout", tokens.LogoutHandler).Methods("POST")
+	router.Handle("/logout-all", authenticate(http.HandlerFunc(tokens.LogoutAllHandler))).Methods("POST")
 }
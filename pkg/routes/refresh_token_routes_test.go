@@ -0,0 +1,120 @@
+package routes_test
+
+import (
+	"book-and-rate/pkg/dto"
+	"context"
+	"net/http"
+	"testing"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func TestRefreshToken(t *testing.T) {
+	s := newTestServer(t)
+	userId, tokens := s.registerUser()
+
+	s.expect(s.do("POST", "/refresh-token", "", "{"), http.StatusBadRequest, nil)
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{"not-a-token"}), http.StatusUnauthorized, nil)
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{tokens.AccessToken}), http.StatusUnauthorized, nil)
+
+	var rotated tokenPair
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{tokens.RefreshToken}), http.StatusOK, &rotated)
+	if rotated.RefreshToken == tokens.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	s.expect(s.do("GET", "/users/"+userId.Hex(), rotated.AccessToken, nil), http.StatusOK, nil)
+
+	var again tokenPair
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{rotated.RefreshToken}), http.StatusOK, &again)
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	s := newTestServer(t)
+	_, tokens := s.registerUser()
+
+	var rotated tokenPair
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{tokens.RefreshToken}), http.StatusOK, &rotated)
+
+	// Replaying the used token signals theft, so the token the thief or victim rotated into dies too
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{tokens.RefreshToken}), http.StatusUnauthorized, nil)
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{rotated.RefreshToken}), http.StatusUnauthorized, nil)
+}
+
+func TestLogout(t *testing.T) {
+	s := newTestServer(t)
+	userId, tokens := s.registerUser()
+	user, _ := s.store.Users.FindByID(context.Background(), userId)
+	other := s.login("/users/login", user.PhoneNumber)
+
+	s.expect(s.do("POST", "/logout", "", "{"), http.StatusBadRequest, nil)
+	s.expect(s.do("POST", "/logout", "", refreshRequest{tokens.AccessToken}), http.StatusUnauthorized, nil)
+	s.expect(s.do("POST", "/logout", "", refreshRequest{tokens.RefreshToken}), http.StatusNoContent, nil)
+
+	// Only the session that logged out is affected
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{tokens.RefreshToken}), http.StatusUnauthorized, nil)
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{other.RefreshToken}), http.StatusOK, nil)
+}
+
+func TestLogoutAll(t *testing.T) {
+	s := newTestServer(t)
+	userId, tokens := s.registerUser()
+	user, _ := s.store.Users.FindByID(context.Background(), userId)
+	other := s.login("/users/login", user.PhoneNumber)
+
+	s.expect(s.do("POST", "/logout-all", "", nil), http.StatusUnauthorized, nil)
+	s.expect(s.do("POST", "/logout-all", tokens.RefreshToken, nil), http.StatusUnauthorized, nil)
+	s.expect(s.do("POST", "/logout-all", tokens.AccessToken, nil), http.StatusNoContent, nil)
+
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{tokens.RefreshToken}), http.StatusUnauthorized, nil)
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{other.RefreshToken}), http.StatusUnauthorized, nil)
+}
+
+func TestDeletedAccountCannotRefresh(t *testing.T) {
+	s := newTestServer(t)
+	userId, user := s.registerUser()
+	restaurantId, restaurant := s.registerRestaurant()
+
+	// Deleting an account ends its sessions
+	s.expect(s.do("DELETE", "/users/"+userId.Hex(), user.AccessToken, nil), http.StatusNoContent, nil)
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{user.RefreshToken}), http.StatusUnauthorized, nil)
+	s.expect(s.do("DELETE", "/restaurants/"+restaurantId.Hex(), restaurant.AccessToken, nil), http.StatusNoContent, nil)
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{restaurant.RefreshToken}), http.StatusUnauthorized, nil)
+
+	// Tokens that outlived their account, as when it was deleted some other way, are refused too
+	otherId, other := s.registerUser()
+	if err := s.store.Users.Delete(context.Background(), otherId); err != nil {
+		t.Fatal(err)
+	}
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{other.RefreshToken}), http.StatusUnauthorized, nil)
+}
+
+func TestPasswordChangeEndsSessions(t *testing.T) {
+	s := newTestServer(t)
+	userId, user := s.registerUser()
+	restaurantId, restaurant := s.registerRestaurant()
+	const newPassword = "a-new-password"
+
+	// Other changes leave the sessions alone
+	s.expect(s.do("PATCH", "/users/"+userId.Hex(), user.AccessToken, map[string]string{"lastName": "Byron"}), http.StatusNoContent, nil)
+	var refreshed tokenPair
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{user.RefreshToken}), http.StatusOK, &refreshed)
+
+	// Whoever knew the old password is logged out with it
+	s.expect(s.do("PATCH", "/users/"+userId.Hex(), refreshed.AccessToken, map[string]string{"password": newPassword}), http.StatusNoContent, nil)
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{refreshed.RefreshToken}), http.StatusUnauthorized, nil)
+
+	stored, err := s.store.Restaurants.FindByID(context.Background(), restaurantId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := dto.RestaurantUpdateRequest{Name: stored.Name, Address: stored.Address, Phone: stored.Phone, Password: newPassword}
+	s.expect(s.do("PUT", "/restaurants/"+restaurantId.Hex(), restaurant.AccessToken, update), http.StatusNoContent, nil)
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{restaurant.RefreshToken}), http.StatusUnauthorized, nil)
+
+	// A login with the new password starts a session that refreshes
+	var login tokenPair
+	s.expect(s.do("POST", "/restaurants/login", "", dto.LoginRequest{Phone: stored.Phone, Password: newPassword}), http.StatusOK, &login)
+	s.expect(s.do("POST", "/refresh-token", "", refreshRequest{login.RefreshToken}), http.StatusOK, nil)
+}
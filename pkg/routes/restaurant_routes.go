@@ -3,16 +3,25 @@ package routes
 import (
 	"book-and-rate/pkg/handlers"
 	middleware "book-and-rate/pkg/middlewares"
+	"net/http"
+
 	"github.com/gorilla/mux"
 )
 
-func RestaurantRoutes(router *mux.Router) {
-	router.HandleFunc("/restaurants", handlers.CreateRestaurantHandler).Methods("POST")
-	router.HandleFunc("/restaurants/login", handlers.LoginRestaurantHandler).Methods("POST")
+func RestaurantRoutes(router *mux.Router, restaurants *handlers.RestaurantHandler, authenticate mux.MiddlewareFunc) {
+	router.HandleFunc("/restaurants", restaurants.CreateRestaurantHandler).Methods("POST")
+	router.HandleFunc("/restaurants/login", restaurants.LoginRestaurantHandler).Methods("POST")
+	router.HandleFunc("/restaurants/{id}/hours", restaurants.GetOpeningHoursHandler).Methods("GET")
 	subRouter := router.PathPrefix("/restaurants").Subrouter()
-	subRouter.Use(middleware.AuthenticationMiddleware)
-	subRouter.HandleFunc("", handlers.GetRestaurantsHandler).Methods("GET")
-	subRouter.HandleFunc("/{id}", handlers.GetRestaurantHandler).Methods("GET")
-	subRouter.HandleFunc("/{id}", handlers.UpdateRestaurantHandler).Methods("PUT")
-	subRouter.HandleFunc("/{id}", handlers.DeleteRestaurantHandler).Methods("DELETE")
+	subRouter.Use(authenticate)
+	subRouter.HandleFunc("", restaurants.GetRestaurantsHandler).Methods("GET")
+	subRouter.HandleFunc("/{id}", restaurants.GetRestaurantHandler).Methods("GET")
+	subRouter.HandleFunc("/{id}", restaurants.UpdateRestaurantHandler).Methods("PUT")
+	subRouter.HandleFunc("/{id}", restaurants.PatchRestaurantHandler).Methods("PATCH")
+	subRouter.HandleFunc("/{id}", restaurants.DeleteRestaurantHandler).Methods("DELETE")
+	subRouter.Handle("/{id}/hours", middleware.AdminMiddleware(http.HandlerFunc(restaurants.UpdateOpeningHoursHandler))).Methods("PUT")
+	subRouter.HandleFunc("/{restaurantId}/tables", restaurants.CreateTableHandler).Methods("POST")
+	subRouter.HandleFunc("/{restaurantId}/tables", restaurants.GetTablesHandler).Methods("GET")
+	subRouter.HandleFunc("/{restaurantId}/tables/{tableId}", restaurants.UpdateTableHandler).Methods("PUT")
+	subRouter.HandleFunc("/{restaurantId}/tables/{tableId}", restaurants.DeleteTableHandler).Methods("DELETE")
 }
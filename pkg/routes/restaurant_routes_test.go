@@ -0,0 +1,264 @@
+package routes_test
+
+import (
+	"book-and-rate/pkg/dto"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCreateAndLoginRestaurant(t *testing.T) {
+	s := newTestServer(t)
+
+	s.expect(s.do("POST", "/restaurants", "", "{"), http.StatusBadRequest, nil)
+	s.expect(s.do("POST", "/restaurants/login", "", dto.LoginRequest{Phone: "+31000", Password: testPassword}), http.StatusUnauthorized, nil)
+
+	restaurantId, tokens := s.registerRestaurant()
+	restaurant, err := s.store.Restaurants.FindByID(context.Background(), restaurantId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restaurant.Password == testPassword {
+		t.Error("password was stored in plain text")
+	}
+	s.expect(s.do("POST", "/restaurants/login", "", dto.LoginRequest{Phone: restaurant.Phone, Password: "wrong"}), http.StatusUnauthorized, nil)
+
+	// The number is registered once, however it is written
+	formatted := restaurant.Phone[:3] + " " + restaurant.Phone[3:]
+	s.expect(s.do("POST", "/restaurants", "", map[string]string{"name": "Copycat", "phone": formatted, "password": testPassword}), http.StatusConflict, nil)
+	s.expect(s.do("POST", "/restaurants", "", map[string]string{"name": "Nowhere", "phone": "12", "password": testPassword}), http.StatusBadRequest, nil)
+	s.login("/restaurants/login", formatted)
+
+	var got dto.RestaurantResponse
+	s.expect(s.do("GET", "/restaurants/"+restaurantId.Hex(), tokens.AccessToken, nil), http.StatusOK, &got)
+	if got.Name != "Chez Test" {
+		t.Errorf("unexpected restaurant %+v", got)
+	}
+}
+
+func TestCreateRestaurantRejectsManagedFields(t *testing.T) {
+	s := newTestServer(t)
+
+	for field, value := range map[string]interface{}{
+		"hours":         everyDay("10:00", "22:00"),
+		"ratingSummary": map[string]interface{}{"count": 100, "mean": 5, "bayesianScore": 5},
+	} {
+		var got dto.ErrorResponse
+		s.expect(s.do("POST", "/restaurants", "", map[string]interface{}{
+			"name":     "Sneaky",
+			"phone":    nextPhone(),
+			"password": testPassword,
+			field:      value,
+		}), http.StatusBadRequest, &got)
+		if len(got.Fields) != 1 || got.Fields[0].Field != field {
+			t.Errorf("expected %s to be refused, got %+v", field, got)
+		}
+	}
+}
+
+func TestGetRestaurants(t *testing.T) {
+	s := newTestServer(t)
+	firstId, tokens := s.registerRestaurant()
+	secondId, _ := s.registerRestaurant()
+	ctx := context.Background()
+	if err := s.store.Restaurants.ApplyRatingChange(ctx, secondId, 5, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.Restaurants.ApplyRatingChange(ctx, firstId, 1, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	s.expect(s.do("GET", "/restaurants", "", nil), http.StatusUnauthorized, nil)
+
+	var page dto.RestaurantPage
+	rec := s.do("GET", "/restaurants", tokens.AccessToken, nil)
+	s.expect(rec, http.StatusOK, &page)
+	if len(page.Items) != 2 || page.Next != "" {
+		t.Fatalf("expected 2 restaurants on a single page, got %+v", page)
+	}
+	if strings.Contains(strings.ToLower(rec.Body.String()), "password") {
+		t.Errorf("password hashes were returned: %s", rec.Body.String())
+	}
+
+	s.expect(s.do("GET", "/restaurants?sort=rating", tokens.AccessToken, nil), http.StatusOK, &page)
+	if len(page.Items) != 2 || page.Items[0].ID != secondId || page.Items[1].ID != firstId {
+		t.Errorf("restaurants are not sorted by rating: %+v", page.Items)
+	}
+	s.expect(s.do("GET", "/restaurants?minRating=4", tokens.AccessToken, nil), http.StatusOK, &page)
+	if len(page.Items) != 1 || page.Items[0].ID != secondId {
+		t.Errorf("expected only the restaurant rated 5, got %+v", page.Items)
+	}
+
+	// Following the next links visits every restaurant once, in order
+	thirdId, _ := s.registerRestaurant()
+	var ids []primitive.ObjectID
+	next := "/restaurants?sort=rating&limit=1"
+	for next != "" {
+		page = dto.RestaurantPage{}
+		s.expect(s.do("GET", next, tokens.AccessToken, nil), http.StatusOK, &page)
+		if len(page.Items) > 1 {
+			t.Fatalf("expected at most 1 restaurant per page, got %d", len(page.Items))
+		}
+		for _, restaurant := range page.Items {
+			ids = append(ids, restaurant.ID)
+		}
+		next = page.Next
+	}
+	if len(ids) != 3 || ids[0] != secondId || ids[1] != firstId || ids[2] != thirdId {
+		t.Errorf("expected the rated restaurants first and the unrated one last, got %v", ids)
+	}
+
+	// A cursor only continues the order it was made for
+	s.expect(s.do("GET", "/restaurants?sort=name&limit=1", tokens.AccessToken, nil), http.StatusOK, &page)
+	after := page.Next[strings.Index(page.Next, "after="):]
+	s.expect(s.do("GET", "/restaurants?sort=rating&"+after, tokens.AccessToken, nil), http.StatusBadRequest, nil)
+	for _, query := range []string{"limit=0", "sort=bogus", "order=x", "after=%21", "maxRating=9"} {
+		s.expect(s.do("GET", "/restaurants?"+query, tokens.AccessToken, nil), http.StatusBadRequest, nil)
+	}
+}
+
+func TestGetRestaurant(t *testing.T) {
+	s := newTestServer(t)
+	_, tokens := s.registerRestaurant()
+
+	s.expect(s.do("GET", "/restaurants/nope", tokens.AccessToken, nil), http.StatusBadRequest, nil)
+	s.expect(s.do("GET", "/restaurants/"+primitive.NewObjectID().Hex(), tokens.AccessToken, nil), http.StatusNotFound, nil)
+}
+
+func TestUpdateRestaurant(t *testing.T) {
+	s := newTestServer(t)
+	restaurantId, owner := s.registerRestaurant()
+	_, other := s.registerRestaurant()
+	_, user := s.registerUser()
+	path := "/restaurants/" + restaurantId.Hex()
+	ctx := context.Background()
+
+	if err := s.store.Restaurants.UpdateHours(ctx, restaurantId, everyDay("10:00", "22:00")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.Restaurants.ApplyRatingChange(ctx, restaurantId, 4, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := s.store.Restaurants.FindByID(ctx, restaurantId)
+
+	update := dto.RestaurantRequest{Name: "Renamed", Address: "2 Test Street", Phone: before.Phone}
+	s.expect(s.do("PUT", path, other.AccessToken, update), http.StatusForbidden, nil)
+	s.expect(s.do("PUT", path, user.AccessToken, update), http.StatusForbidden, nil)
+	s.expect(s.do("PUT", path, owner.AccessToken, "{"), http.StatusBadRequest, nil)
+	s.expect(s.do("PUT", path, owner.AccessToken, update), http.StatusNoContent, nil)
+
+	after, _ := s.store.Restaurants.FindByID(ctx, restaurantId)
+	if after.Name != "Renamed" || after.Address != "2 Test Street" {
+		t.Errorf("update was not applied: %+v", after)
+	}
+	if after.Hours == nil || after.RatingSummary == nil || after.RatingSummary.Count != 1 {
+		t.Errorf("update touched the hours or rating summary: %+v", after)
+	}
+
+	// Leaving the password out keeps the current one
+	s.login("/restaurants/login", before.Phone)
+
+	s.expect(s.do("PATCH", path, user.AccessToken, map[string]string{"address": "3 Test Street"}), http.StatusForbidden, nil)
+	s.expect(s.do("PATCH", path, owner.AccessToken, map[string]interface{}{"ratingSummary": nil}), http.StatusBadRequest, nil)
+	s.expect(s.do("PATCH", path, owner.AccessToken, map[string]string{"name": ""}), http.StatusBadRequest, nil)
+	s.expect(s.do("PATCH", path, owner.AccessToken, map[string]string{"address": "3 Test Street"}), http.StatusNoContent, nil)
+	patched, _ := s.store.Restaurants.FindByID(ctx, restaurantId)
+	if patched.Address != "3 Test Street" || patched.Name != "Renamed" || patched.Phone != before.Phone {
+		t.Errorf("patch changed more than the address: %+v", patched)
+	}
+	s.login("/restaurants/login", before.Phone)
+}
+
+func TestDeleteRestaurant(t *testing.T) {
+	s := newTestServer(t)
+	restaurantId, owner := s.registerRestaurant()
+	_, other := s.registerRestaurant()
+	path := "/restaurants/" + restaurantId.Hex()
+
+	s.expect(s.do("DELETE", path, other.AccessToken, nil), http.StatusForbidden, nil)
+	s.expect(s.do("DELETE", path, owner.AccessToken, nil), http.StatusNoContent, nil)
+	s.expect(s.do("DELETE", path, owner.AccessToken, nil), http.StatusNotFound, nil)
+}
+
+func TestOpeningHours(t *testing.T) {
+	s := newTestServer(t)
+	restaurantId, owner := s.registerRestaurant()
+	admin := s.loginAdmin()
+	path := "/restaurants/" + restaurantId.Hex() + "/hours"
+
+	s.expect(s.do("GET", path, "", nil), http.StatusNotFound, nil)
+	s.expect(s.do("GET", "/restaurants/nope/hours", "", nil), http.StatusBadRequest, nil)
+	s.expect(s.do("GET", "/restaurants/"+primitive.NewObjectID().Hex()+"/hours", "", nil), http.StatusNotFound, nil)
+
+	hours := everyDay("10:00", "22:00")
+	invalid := everyDay("22:00", "22:00")
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		body   interface{}
+		status int
+	}{
+		{"no token", path, "", hours, http.StatusUnauthorized},
+		{"restaurant owner", path, owner.AccessToken, hours, http.StatusForbidden},
+		{"malformed body", path, admin.AccessToken, "{", http.StatusBadRequest},
+		{"closing when opening", path, admin.AccessToken, invalid, http.StatusBadRequest},
+		{"closing past midnight", path, admin.AccessToken, everyDay("18:00", "02:00"), http.StatusNoContent},
+		{"unknown timezone", path, admin.AccessToken, dto.OpeningHours{Timezone: "Mars/Olympus"}, http.StatusBadRequest},
+		{"unknown restaurant", "/restaurants/" + primitive.NewObjectID().Hex() + "/hours", admin.AccessToken, hours, http.StatusNotFound},
+		{"administrator", path, admin.AccessToken, hours, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := s.with(t)
+			s.expect(s.do("PUT", tt.path, tt.token, tt.body), tt.status, nil)
+		})
+	}
+
+	var got dto.OpeningHours
+	s.expect(s.do("GET", path, "", nil), http.StatusOK, &got)
+	if len(got.Weekly) != 7 || got.SlotInterval != 30 {
+		t.Errorf("unexpected opening hours %+v", got)
+	}
+}
+
+func TestTables(t *testing.T) {
+	s := newTestServer(t)
+	restaurantId, owner := s.registerRestaurant()
+	_, other := s.registerRestaurant()
+	path := "/restaurants/" + restaurantId.Hex() + "/tables"
+
+	s.expect(s.do("POST", path, other.AccessToken, dto.TableRequest{Seats: 2}), http.StatusForbidden, nil)
+	s.expect(s.do("POST", path, owner.AccessToken, dto.TableRequest{Seats: 0}), http.StatusBadRequest, nil)
+	s.expect(s.do("POST", path, owner.AccessToken, "{"), http.StatusBadRequest, nil)
+
+	tableId := s.addTable(restaurantId, owner.AccessToken, 2)
+	s.addTable(restaurantId, owner.AccessToken, 6)
+
+	var tables []dto.TableResponse
+	s.expect(s.do("GET", path, other.AccessToken, nil), http.StatusForbidden, nil)
+	s.expect(s.do("GET", path, owner.AccessToken, nil), http.StatusOK, &tables)
+	if len(tables) != 2 {
+		t.Fatalf("expected 2 tables, got %d", len(tables))
+	}
+
+	tablePath := path + "/" + tableId.Hex()
+	s.expect(s.do("PUT", tablePath, owner.AccessToken, dto.TableRequest{Seats: -1}), http.StatusBadRequest, nil)
+	s.expect(s.do("PUT", tablePath, other.AccessToken, dto.TableRequest{Seats: 4}), http.StatusForbidden, nil)
+	s.expect(s.do("PUT", path+"/"+primitive.NewObjectID().Hex(), owner.AccessToken, dto.TableRequest{Seats: 4}), http.StatusNotFound, nil)
+	s.expect(s.do("PUT", tablePath, owner.AccessToken, dto.TableRequest{Name: "Window", Seats: 4, Combinable: true}), http.StatusNoContent, nil)
+
+	s.expect(s.do("GET", path, owner.AccessToken, nil), http.StatusOK, &tables)
+	for _, table := range tables {
+		if table.ID == tableId && (table.Seats != 4 || table.Name != "Window" || !table.Combinable) {
+			t.Errorf("update was not applied: %+v", table)
+		}
+	}
+
+	s.expect(s.do("DELETE", tablePath, other.AccessToken, nil), http.StatusForbidden, nil)
+	s.expect(s.do("DELETE", tablePath, owner.AccessToken, nil), http.StatusNoContent, nil)
+	s.expect(s.do("DELETE", tablePath, owner.AccessToken, nil), http.StatusNotFound, nil)
+}
@@ -0,0 +1,332 @@
+package routes_test
+
+import (
+	"book-and-rate/pkg/config"
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/repository"
+	"book-and-rate/pkg/server"
+	"book-and-rate/pkg/sms"
+	"book-and-rate/pkg/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "s3cret-password"
+
+// adminID is listed as an administrator in the configuration of the test server
+var adminID = primitive.NewObjectID()
+
+// testServer is the full API over an in-memory store
+type testServer struct {
+	t       *testing.T
+	store   *repository.Store
+	handler http.Handler
+	// smsFile receives the text messages the server sends
+	smsFile string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newTestServerOver(t, repository.NewMemoryStore())
+}
+
+// newTestServerOver builds the test server over the store, for tests that wrap some of its repositories
+func newTestServerOver(t *testing.T, store *repository.Store) *testServer {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.JwtSecret = "test-jwt-secret-0123456789abcdefgh"
+	cfg.BcryptCost = bcrypt.MinCost
+	cfg.Admins = []string{adminID.Hex()}
+	cfg.SMS = config.SMSConfig{Sender: config.SMSSenderFile, File: filepath.Join(t.TempDir(), "sms.jsonl")}
+
+	srv := server.New(cfg, store, log.New(io.Discard, "", 0))
+	return &testServer{t: t, store: store, handler: srv.Handler(), smsFile: cfg.SMS.File}
+}
+
+// with returns the server reporting to t, for use inside subtests
+func (s *testServer) with(t *testing.T) *testServer {
+	c := *s
+	c.t = t
+	return &c
+}
+
+// do sends a request through the router, body is encoded as JSON unless it is a string
+func (s *testServer) do(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = bytes.NewBufferString(b)
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatalf("encoding request body: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect fails the test unless the response has the status, and decodes the body into v when given
+func (s *testServer) expect(rec *httptest.ResponseRecorder, status int, v interface{}) {
+	s.t.Helper()
+
+	if rec.Code != status {
+		s.t.Fatalf("expected status %d, got %d: %s", status, rec.Code, rec.Body.String())
+	}
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			s.t.Fatalf("decoding response %q: %v", rec.Body.String(), err)
+		}
+	}
+}
+
+// resource is the part of a created resource the tests need
+type resource struct {
+	ID primitive.ObjectID `json:"id"`
+}
+
+type tokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+var phoneCounter int64
+
+// nextPhone returns a phone number no other account of the run uses
+func nextPhone() string {
+	return fmt.Sprintf("+3161%07d", atomic.AddInt64(&phoneCounter, 1))
+}
+
+// registerUser signs a new guest up, logs them in and verifies their phone number
+func (s *testServer) registerUser() (primitive.ObjectID, tokenPair) {
+	s.t.Helper()
+
+	userId, phone, tokens := s.registerUnverifiedUser()
+	s.verifyPhone(phone, tokens.AccessToken)
+	return userId, tokens
+}
+
+// registerUnverifiedUser signs a new guest up and logs them in, leaving their phone number unverified
+func (s *testServer) registerUnverifiedUser() (primitive.ObjectID, string, tokenPair) {
+	s.t.Helper()
+
+	phone := nextPhone()
+	var created resource
+	s.expect(s.do("POST", "/users", "", map[string]string{
+		"firstName":   "Ada",
+		"lastName":    "Lovelace",
+		"phoneNumber": phone,
+		"password":    testPassword,
+	}), http.StatusCreated, &created)
+
+	return created.ID, phone, s.login("/users/login", phone)
+}
+
+// verifyPhone requests a verification code and confirms it with the code texted to phone
+func (s *testServer) verifyPhone(phone, token string) {
+	s.t.Helper()
+
+	s.expect(s.do("POST", "/users/verify/start", token, nil), http.StatusAccepted, nil)
+	s.expect(s.do("POST", "/users/verify/confirm", token, map[string]string{"code": s.lastCode(phone)}), http.StatusNoContent, nil)
+}
+
+// verificationCode and resetToken find the secret in the text messages the server sends
+var (
+	verificationCode = regexp.MustCompile(`verification code is (\d+)`)
+	resetToken       = regexp.MustCompile(`reset token is ([\w-]+)`)
+)
+
+// lastCode returns the verification code most recently texted to phone
+func (s *testServer) lastCode(phone string) string {
+	s.t.Helper()
+
+	code, ok := s.lastTexted(phone, verificationCode)
+	if !ok {
+		s.t.Fatalf("no verification code was texted to %s", phone)
+	}
+	return code
+}
+
+// lastTexted returns the first group of pattern in the latest message to phone that matches it
+func (s *testServer) lastTexted(phone string, pattern *regexp.Regexp) (string, bool) {
+	s.t.Helper()
+
+	messages, err := sms.ReadFile(s.smsFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false
+	}
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].To != phone {
+			continue
+		}
+		if match := pattern.FindStringSubmatch(messages[i].Body); match != nil {
+			return match[1], true
+		}
+	}
+	return "", false
+}
+
+// registerRestaurant signs a new restaurant up and logs it in
+func (s *testServer) registerRestaurant() (primitive.ObjectID, tokenPair) {
+	s.t.Helper()
+
+	phone := nextPhone()
+	var created resource
+	s.expect(s.do("POST", "/restaurants", "", map[string]string{
+		"name":     "Chez Test",
+		"address":  "1 Test Street",
+		"phone":    phone,
+		"password": testPassword,
+	}), http.StatusCreated, &created)
+
+	return created.ID, s.login("/restaurants/login", phone)
+}
+
+// loginAdmin seeds the administrator listed in the configuration and logs them in
+func (s *testServer) loginAdmin() tokenPair {
+	s.t.Helper()
+
+	hashed, err := utils.NewPasswordHasher(bcrypt.MinCost).Hash(testPassword)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	phone := nextPhone()
+	admin := models.User{ID: adminID, FirstName: "Admin", PhoneNumber: phone, Password: hashed}
+	if err := s.store.Users.Create(context.Background(), &admin); err != nil {
+		s.t.Fatal(err)
+	}
+	return s.login("/users/login", phone)
+}
+
+func (s *testServer) login(path, phone string) tokenPair {
+	s.t.Helper()
+
+	var tokens tokenPair
+	s.expect(s.do("POST", path, "", dto.LoginRequest{Phone: phone, Password: testPassword}), http.StatusOK, &tokens)
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		s.t.Fatalf("login returned incomplete tokens: %+v", tokens)
+	}
+	return tokens
+}
+
+// addTable adds a table to the restaurant's inventory
+func (s *testServer) addTable(restaurantId primitive.ObjectID, token string, seats int) primitive.ObjectID {
+	s.t.Helper()
+
+	var created resource
+	s.expect(s.do("POST", "/restaurants/"+restaurantId.Hex()+"/tables", token, dto.TableRequest{Name: "T", Seats: seats}), http.StatusCreated, &created)
+	return created.ID
+}
+
+// book creates a booking for the guest and returns its ID
+func (s *testServer) book(userId, restaurantId primitive.ObjectID, token string, date time.Time, partySize int) primitive.ObjectID {
+	s.t.Helper()
+
+	var created resource
+	s.expect(s.do("POST", "/bookings", token, dto.BookingRequest{
+		UserID:       userId,
+		RestaurantID: restaurantId,
+		Date:         date,
+		PartySize:    partySize,
+	}), http.StatusCreated, &created)
+	return created.ID
+}
+
+// transition moves a booking through a lifecycle endpoint, failing the test on anything but 204
+func (s *testServer) transition(bookingId primitive.ObjectID, action, token string) {
+	s.t.Helper()
+	s.expect(s.do("PUT", "/bookings/"+bookingId.Hex()+"/"+action, token, nil), http.StatusNoContent, nil)
+}
+
+// fixture is a guest and a restaurant with a single four seat table
+type fixture struct {
+	*testServer
+	userId       primitive.ObjectID
+	user         tokenPair
+	restaurantId primitive.ObjectID
+	restaurant   tokenPair
+	tableId      primitive.ObjectID
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	return newFixtureOver(t, repository.NewMemoryStore())
+}
+
+// newFixtureOver returns a fixture backed by store, for tests that wrap its repositories
+func newFixtureOver(t *testing.T, store *repository.Store) *fixture {
+	t.Helper()
+
+	s := newTestServerOver(t, store)
+	f := &fixture{testServer: s}
+	f.userId, f.user = s.registerUser()
+	f.restaurantId, f.restaurant = s.registerRestaurant()
+	f.tableId = s.addTable(f.restaurantId, f.restaurant.AccessToken, 4)
+	return f
+}
+
+func (f *fixture) with(t *testing.T) *fixture {
+	c := *f
+	c.testServer = f.testServer.with(t)
+	return &c
+}
+
+// completedBooking books the fixture's table and runs the booking through to completed
+func (f *fixture) completedBooking(date time.Time) primitive.ObjectID {
+	f.t.Helper()
+
+	bookingId := f.book(f.userId, f.restaurantId, f.user.AccessToken, date, 2)
+	for _, action := range []string{"confirm", "seat", "complete"} {
+		f.transition(bookingId, action, f.restaurant.AccessToken)
+	}
+	return bookingId
+}
+
+// tomorrowAt returns the hour of tomorrow in UTC, safely in the future and on a slot boundary
+func tomorrowAt(hour int) time.Time {
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+	return time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), hour, 0, 0, 0, time.UTC)
+}
+
+// everyDay is a schedule open from open to closing, "HH:MM" in UTC, every day of the week
+func everyDay(open, closing string) models.OpeningHours {
+	hours := models.OpeningHours{SlotInterval: 30}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		hours.Weekly = append(hours.Weekly, models.DayHours{
+			Weekday: day,
+			Periods: []models.ServicePeriod{{Open: open, Close: closing}},
+		})
+	}
+	return hours
+}
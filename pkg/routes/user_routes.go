@@ -2,17 +2,17 @@ package routes
 
 import (
 	"book-and-rate/pkg/handlers"
-	middleware "book-and-rate/pkg/middlewares"
 
 	"github.com/gorilla/mux"
 )
 
-func UserRoutes(router *mux.Router) {
-	router.HandleFunc("/users", handlers.CreateUserHandler).Methods("POST")
-	router.HandleFunc("/users/login", handlers.LoginUserHandler).Methods("POST")
+func UserRoutes(router *mux.Router, users *handlers.UserHandler, authenticate mux.MiddlewareFunc) {
+	router.HandleFunc("/users", users.CreateUserHandler).Methods("POST")
+	router.HandleFunc("/users/login", users.LoginUserHandler).Methods("POST")
 	subRouter := router.PathPrefix("/users").Subrouter()
-	subRouter.Use(middleware.AuthenticationMiddleware)
-	subRouter.HandleFunc("/{id}", handlers.GetUserHandler).Methods("GET")
-	subRouter.HandleFunc("/{id}", handlers.UpdateUserHandler).Methods("PUT")
-	subRouter.HandleFunc("/{id}", handlers.DeleteUserHandler).Methods("DELETE")
+	subRouter.Use(authenticate)
+	subRouter.HandleFunc("/{id}", users.GetUserHandler).Methods("GET")
+	subRouter.HandleFunc("/{id}", users.UpdateUserHandler).Methods("PUT")
+	subRouter.HandleFunc("/{id}", users.PatchUserHandler).Methods("PATCH")
+	subRouter.HandleFunc("/{id}", users.DeleteUserHandler).Methods("DELETE")
 }
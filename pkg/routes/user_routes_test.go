@@ -0,0 +1,222 @@
+package routes_test
+
+import (
+	"book-and-rate/pkg/dto"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCreateUser(t *testing.T) {
+	s := newTestServer(t)
+
+	s.expect(s.do("POST", "/users", "", "{not json"), http.StatusBadRequest, nil)
+
+	rec := s.do("POST", "/users", "", dto.UserRequest{FirstName: "Ada", LastName: "Lovelace", PhoneNumber: nextPhone(), Password: testPassword})
+	var created dto.UserResponse
+	s.expect(rec, http.StatusCreated, &created)
+	if rec.Header().Get("Location") != "/users/"+created.ID.Hex() {
+		t.Errorf("unexpected Location %q", rec.Header().Get("Location"))
+	}
+	if created.FirstName != "Ada" || created.LastName != "Lovelace" {
+		t.Errorf("unexpected user %+v", created)
+	}
+	stored, err := s.store.Users.FindByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Password == testPassword {
+		t.Error("password was stored in plain text")
+	}
+
+	userId, tokens := s.registerUser()
+	rec = s.do("GET", "/users/"+userId.Hex(), tokens.AccessToken, nil)
+	var user dto.UserResponse
+	s.expect(rec, http.StatusOK, &user)
+	if user.FirstName != "Ada" || user.LastName != "Lovelace" {
+		t.Errorf("unexpected user %+v", user)
+	}
+	if strings.Contains(strings.ToLower(rec.Body.String()), "password") {
+		t.Errorf("the password hash was returned: %s", rec.Body.String())
+	}
+}
+
+func TestCreateUserPhone(t *testing.T) {
+	s := newTestServer(t)
+	signUp := func(phone string) map[string]string {
+		return map[string]string{"firstName": "Ada", "phoneNumber": phone, "password": testPassword}
+	}
+
+	var created resource
+	s.expect(s.do("POST", "/users", "", signUp("0031 6 5555 0001")), http.StatusCreated, &created)
+	user, err := s.store.Users.FindByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.PhoneNumber != "+31655550001" {
+		t.Errorf("expected the phone number in E.164, got %q", user.PhoneNumber)
+	}
+
+	tests := []struct {
+		name   string
+		phone  string
+		status int
+	}{
+		{"same number formatted differently", "+31 (6) 5555-0001", http.StatusConflict},
+		{"missing phone", "", http.StatusBadRequest},
+		{"no country code", "06 5555 0002", http.StatusBadRequest},
+		{"letters", "+31 6 CALL ME", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := s.with(t)
+			s.expect(s.do("POST", "/users", "", signUp(tt.phone)), tt.status, nil)
+		})
+	}
+
+	// Any formatting of the number logs in
+	s.login("/users/login", "+31 6 5555 0001")
+}
+
+func TestLoginUser(t *testing.T) {
+	s := newTestServer(t)
+	userId, _ := s.registerUser()
+	user, err := s.store.Users.FindByID(context.Background(), userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		body   interface{}
+		status int
+	}{
+		{"valid credentials", dto.LoginRequest{Phone: user.PhoneNumber, Password: testPassword}, http.StatusOK},
+		{"wrong password", dto.LoginRequest{Phone: user.PhoneNumber, Password: "wrong"}, http.StatusUnauthorized},
+		{"unknown phone", dto.LoginRequest{Phone: "+310000000000", Password: testPassword}, http.StatusUnauthorized},
+		{"malformed body", "{", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := s.with(t)
+			s.expect(s.do("POST", "/users/login", "", tt.body), tt.status, nil)
+		})
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	s := newTestServer(t)
+	userId, owner := s.registerUser()
+	_, other := s.registerUser()
+	_, restaurant := s.registerRestaurant()
+	admin := s.loginAdmin()
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"owner", "/users/" + userId.Hex(), owner.AccessToken, http.StatusOK},
+		{"administrator", "/users/" + userId.Hex(), admin.AccessToken, http.StatusOK},
+		{"other user", "/users/" + userId.Hex(), other.AccessToken, http.StatusForbidden},
+		{"restaurant", "/users/" + userId.Hex(), restaurant.AccessToken, http.StatusForbidden},
+		{"no token", "/users/" + userId.Hex(), "", http.StatusUnauthorized},
+		{"invalid token", "/users/" + userId.Hex(), "not-a-token", http.StatusUnauthorized},
+		{"refresh token", "/users/" + userId.Hex(), owner.RefreshToken, http.StatusUnauthorized},
+		{"malformed ID", "/users/nope", owner.AccessToken, http.StatusBadRequest},
+		{"unknown user", "/users/" + primitive.NewObjectID().Hex(), admin.AccessToken, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := s.with(t)
+			s.expect(s.do("GET", tt.path, tt.token, nil), tt.status, nil)
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	s := newTestServer(t)
+	userId, owner := s.registerUser()
+	_, other := s.registerUser()
+	path := "/users/" + userId.Hex()
+
+	s.expect(s.do("PUT", path, other.AccessToken, map[string]string{"firstName": "Mallory"}), http.StatusForbidden, nil)
+	s.expect(s.do("PUT", path, owner.AccessToken, "{"), http.StatusBadRequest, nil)
+
+	before, _ := s.store.Users.FindByID(context.Background(), userId)
+	s.expect(s.do("PUT", path, owner.AccessToken, map[string]string{
+		"firstName":   "Grace",
+		"lastName":    "Hopper",
+		"phoneNumber": before.PhoneNumber,
+	}), http.StatusNoContent, nil)
+
+	var user dto.UserResponse
+	s.expect(s.do("GET", path, owner.AccessToken, nil), http.StatusOK, &user)
+	if user.FirstName != "Grace" || user.LastName != "Hopper" {
+		t.Errorf("update was not applied: %+v", user)
+	}
+
+	// Leaving the password out keeps the current one
+	s.login("/users/login", before.PhoneNumber)
+
+	takenId, _ := s.registerUser()
+	takenUser, _ := s.store.Users.FindByID(context.Background(), takenId)
+	s.expect(s.do("PUT", path, owner.AccessToken, map[string]string{"firstName": "Grace", "phoneNumber": takenUser.PhoneNumber}), http.StatusConflict, nil)
+	s.expect(s.do("PUT", path, owner.AccessToken, map[string]string{"firstName": "Grace", "phoneNumber": "not a phone"}), http.StatusBadRequest, nil)
+}
+
+func TestDeleteUser(t *testing.T) {
+	s := newTestServer(t)
+	userId, owner := s.registerUser()
+	_, other := s.registerUser()
+	path := "/users/" + userId.Hex()
+
+	s.expect(s.do("DELETE", path, other.AccessToken, nil), http.StatusForbidden, nil)
+	s.expect(s.do("DELETE", path, owner.AccessToken, nil), http.StatusNoContent, nil)
+	s.expect(s.do("DELETE", path, owner.AccessToken, nil), http.StatusNotFound, nil)
+	s.expect(s.do("GET", path, owner.AccessToken, nil), http.StatusNotFound, nil)
+}
+
+func TestPatchUser(t *testing.T) {
+	s := newTestServer(t)
+	userId, owner := s.registerUser()
+	_, other := s.registerUser()
+	path := "/users/" + userId.Hex()
+	before, _ := s.store.Users.FindByID(context.Background(), userId)
+
+	s.expect(s.do("PATCH", path, other.AccessToken, map[string]string{"lastName": "Byron"}), http.StatusForbidden, nil)
+
+	tests := []struct {
+		name string
+		body interface{}
+	}{
+		{"malformed body", "{"},
+		{"immutable ID", map[string]string{"id": primitive.NewObjectID().Hex()}},
+		{"verification", map[string]interface{}{"phoneVerified": false}},
+		{"unknown field", map[string]string{"nickname": "Ada"}},
+		{"blank first name", map[string]string{"firstName": " "}},
+		{"blank password", map[string]string{"password": ""}},
+		{"invalid phone", map[string]string{"phoneNumber": "not a phone"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := s.with(t)
+			s.expect(s.do("PATCH", path, owner.AccessToken, tt.body), http.StatusBadRequest, nil)
+		})
+	}
+
+	// Only the last name changes, the rest of the account is left as it was
+	s.expect(s.do("PATCH", path, owner.AccessToken, map[string]string{"lastName": "Byron"}), http.StatusNoContent, nil)
+	user, _ := s.store.Users.FindByID(context.Background(), userId)
+	if user.LastName != "Byron" || user.FirstName != before.FirstName || user.PhoneNumber != before.PhoneNumber || !user.PhoneVerified() {
+		t.Errorf("patch changed more than the last name: %+v", user)
+	}
+	s.login("/users/login", before.PhoneNumber)
+
+	s.expect(s.do("PATCH", path, owner.AccessToken, map[string]string{"password": newPassword}), http.StatusNoContent, nil)
+	s.expect(s.do("POST", "/users/login", "", dto.LoginRequest{Phone: before.PhoneNumber, Password: newPassword}), http.StatusOK, nil)
+}
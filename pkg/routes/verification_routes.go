@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"book-and-rate/pkg/handlers"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func VerificationRoutes(router *mux.Router, verification *handlers.VerificationHandler, authenticate mux.MiddlewareFunc) {
+	router.Handle("/users/verify/start", authenticate(http.HandlerFunc(verification.StartVerificationHandler))).Methods("POST")
+	router.Handle("/users/verify/confirm", authenticate(http.HandlerFunc(verification.ConfirmVerificationHandler))).Methods("POST")
+}
@@ -0,0 +1,222 @@
+package routes_test
+
+import (
+	"book-and-rate/pkg/config"
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/sms"
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPhoneVerification(t *testing.T) {
+	s := newTestServer(t)
+	userId, phone, tokens := s.registerUnverifiedUser()
+
+	s.expect(s.do("POST", "/users/verify/confirm", tokens.AccessToken, map[string]string{"code": "123456"}), http.StatusBadRequest, nil)
+	s.expect(s.do("POST", "/users/verify/start", tokens.AccessToken, nil), http.StatusAccepted, nil)
+	code := s.lastCode(phone)
+
+	verification, err := s.store.Verifications.FindByUser(context.Background(), userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verification.CodeHash == code || verification.CodeHash == "" {
+		t.Errorf("expected the code to be stored hashed, got %q", verification.CodeHash)
+	}
+
+	// Asking again straight away is refused until the cooldown passes
+	rec := s.do("POST", "/users/verify/start", tokens.AccessToken, nil)
+	s.expect(rec, http.StatusTooManyRequests, nil)
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+
+	s.expect(s.do("POST", "/users/verify/confirm", tokens.AccessToken, map[string]string{"code": wrongCode(code)}), http.StatusBadRequest, nil)
+	s.expect(s.do("POST", "/users/verify/confirm", tokens.AccessToken, map[string]string{"code": code}), http.StatusNoContent, nil)
+
+	user, err := s.store.Users.FindByID(context.Background(), userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.PhoneVerified() {
+		t.Error("expected the phone number to be verified")
+	}
+
+	s.expect(s.do("POST", "/users/verify/confirm", tokens.AccessToken, map[string]string{"code": code}), http.StatusConflict, nil)
+	s.expect(s.do("POST", "/users/verify/start", tokens.AccessToken, nil), http.StatusConflict, nil)
+}
+
+func TestPhoneVerificationAttemptLimit(t *testing.T) {
+	s := newTestServer(t)
+	_, phone, tokens := s.registerUnverifiedUser()
+
+	s.expect(s.do("POST", "/users/verify/start", tokens.AccessToken, nil), http.StatusAccepted, nil)
+	code := s.lastCode(phone)
+
+	for i := 0; i < 5; i++ {
+		s.expect(s.do("POST", "/users/verify/confirm", tokens.AccessToken, map[string]string{"code": wrongCode(code)}), http.StatusBadRequest, nil)
+	}
+	// Once the attempts are used up even the right code is refused
+	s.expect(s.do("POST", "/users/verify/confirm", tokens.AccessToken, map[string]string{"code": code}), http.StatusTooManyRequests, nil)
+}
+
+func TestPhoneVerificationExpiredCode(t *testing.T) {
+	s := newTestServer(t)
+	userId, phone, tokens := s.registerUnverifiedUser()
+
+	s.expect(s.do("POST", "/users/verify/start", tokens.AccessToken, nil), http.StatusAccepted, nil)
+	code := s.lastCode(phone)
+
+	verification, _ := s.store.Verifications.FindByUser(context.Background(), userId)
+	verification.SentAt = time.Now().Add(-time.Hour)
+	verification.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := s.store.Verifications.Save(context.Background(), verification); err != nil {
+		t.Fatal(err)
+	}
+	s.expect(s.do("POST", "/users/verify/confirm", tokens.AccessToken, map[string]string{"code": code}), http.StatusBadRequest, nil)
+
+	// A new code can be requested and replaces the expired one
+	s.verifyPhone(phone, tokens.AccessToken)
+}
+
+func TestPhoneVerificationConcurrentStarts(t *testing.T) {
+	s := newTestServer(t)
+	_, phone, tokens := s.registerUnverifiedUser()
+
+	const attempts = 8
+	statuses := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- s.do("POST", "/users/verify/start", tokens.AccessToken, nil).Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusAccepted] != 1 || counts[http.StatusTooManyRequests] != attempts-1 {
+		t.Errorf("expected one code and %d refusals, got %v", attempts-1, counts)
+	}
+
+	messages, err := sms.ReadFile(s.smsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := 0
+	for _, message := range messages {
+		if message.To == phone {
+			sent++
+		}
+	}
+	if sent != 1 {
+		t.Errorf("expected a single text message, got %d", sent)
+	}
+}
+
+func TestPhoneVerificationDailyCap(t *testing.T) {
+	s := newTestServer(t)
+	userId, _, tokens := s.registerUnverifiedUser()
+	ctx := context.Background()
+
+	// rewind moves the verification in progress back in time, as if its codes were sent earlier
+	rewind := func(by time.Duration) {
+		verification, err := s.store.Verifications.FindByUser(ctx, userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verification.SentAt = verification.SentAt.Add(-by)
+		verification.WindowStart = verification.WindowStart.Add(-by)
+		if err := s.store.Verifications.Save(ctx, verification); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Waiting out the cooldown each time only goes as far as the daily cap
+	maxSends := config.Default().Verification.MaxSendsPerDay
+	for i := 0; i < maxSends; i++ {
+		s.expect(s.do("POST", "/users/verify/start", tokens.AccessToken, nil), http.StatusAccepted, nil)
+		rewind(time.Hour)
+	}
+	rec := s.do("POST", "/users/verify/start", tokens.AccessToken, nil)
+	s.expect(rec, http.StatusTooManyRequests, nil)
+	if wait, _ := strconv.Atoi(rec.Header().Get("Retry-After")); wait < int(time.Hour.Seconds()) {
+		t.Errorf("expected to wait for the window to pass, got Retry-After %q", rec.Header().Get("Retry-After"))
+	}
+
+	// Once the first code of the window is a day old, codes are sent again
+	rewind(models.VerificationSendWindow)
+	s.expect(s.do("POST", "/users/verify/start", tokens.AccessToken, nil), http.StatusAccepted, nil)
+	if verification, _ := s.store.Verifications.FindByUser(ctx, userId); verification.Sends != 1 {
+		t.Errorf("expected a new window counting one code, got %+v", verification)
+	}
+}
+
+func TestPhoneVerificationAccess(t *testing.T) {
+	s := newTestServer(t)
+	_, restaurant := s.registerRestaurant()
+
+	s.expect(s.do("POST", "/users/verify/start", "", nil), http.StatusUnauthorized, nil)
+	s.expect(s.do("POST", "/users/verify/start", restaurant.AccessToken, nil), http.StatusForbidden, nil)
+	s.expect(s.do("POST", "/users/verify/confirm", restaurant.AccessToken, map[string]string{"code": "123456"}), http.StatusForbidden, nil)
+}
+
+func TestChangingPhoneNeedsVerification(t *testing.T) {
+	s := newTestServer(t)
+	userId, tokens := s.registerUser()
+	path := "/users/" + userId.Hex()
+
+	// Keeping the number keeps it verified
+	before, _ := s.store.Users.FindByID(context.Background(), userId)
+	s.expect(s.do("PUT", path, tokens.AccessToken, map[string]string{"firstName": "Ada", "phoneNumber": before.PhoneNumber}), http.StatusNoContent, nil)
+	if user, _ := s.store.Users.FindByID(context.Background(), userId); !user.PhoneVerified() {
+		t.Fatal("expected the unchanged number to stay verified")
+	}
+
+	newPhone := nextPhone()
+	s.expect(s.do("PUT", path, tokens.AccessToken, map[string]string{"firstName": "Ada", "phoneNumber": newPhone}), http.StatusNoContent, nil)
+	if user, _ := s.store.Users.FindByID(context.Background(), userId); user.PhoneVerified() {
+		t.Fatal("expected the new number to need verification")
+	}
+
+	// Clients cannot mark the number verified themselves
+	s.expect(s.do("PUT", path, tokens.AccessToken, map[string]interface{}{"firstName": "Ada", "phoneNumber": newPhone, "phoneVerifiedAt": time.Now()}), http.StatusBadRequest, nil)
+	if user, _ := s.store.Users.FindByID(context.Background(), userId); user.PhoneVerified() {
+		t.Fatal("expected the client supplied verification to be refused")
+	}
+}
+
+func TestUnverifiedUserCannotBook(t *testing.T) {
+	s := newTestServer(t)
+	userId, phone, user := s.registerUnverifiedUser()
+	restaurantId, restaurant := s.registerRestaurant()
+	s.addTable(restaurantId, restaurant.AccessToken, 4)
+	s.addTable(restaurantId, restaurant.AccessToken, 4)
+
+	booking := dto.BookingRequest{UserID: userId, RestaurantID: restaurantId, Date: tomorrowAt(19), PartySize: 2}
+	s.expect(s.do("POST", "/bookings", user.AccessToken, booking), http.StatusForbidden, nil)
+
+	// The restaurant taking the booking for the guest is not held back
+	s.book(userId, restaurantId, restaurant.AccessToken, tomorrowAt(19), 2)
+
+	s.verifyPhone(phone, user.AccessToken)
+	s.book(userId, restaurantId, user.AccessToken, tomorrowAt(19), 2)
+}
+
+// wrongCode returns a code of the same length that differs from code
+func wrongCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
@@ -0,0 +1,71 @@
+package scheduling
+
+import (
+	"book-and-rate/pkg/models"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BusyTables collects the tables held by the given bookings
+func BusyTables(bookings []models.Booking) map[primitive.ObjectID]bool {
+	busy := make(map[primitive.ObjectID]bool)
+	for _, booking := range bookings {
+		for _, tableId := range booking.TableIDs {
+			busy[tableId] = true
+		}
+	}
+	return busy
+}
+
+// AllocateTables picks free tables that can seat the party.
+// The single table with the fewest spare seats wins; when no table is big enough,
+// combinable tables of one zone are joined, preferring the fewest tables and then the fewest spare seats.
+func AllocateTables(tables []models.Table, busy map[primitive.ObjectID]bool, partySize int) ([]primitive.ObjectID, bool) {
+	if partySize <= 0 {
+		return nil, false
+	}
+
+	var best *models.Table
+	zones := make(map[string][]models.Table)
+	for i := range tables {
+		table := tables[i]
+		if busy[table.ID] || table.Seats <= 0 {
+			continue
+		}
+		if table.Seats >= partySize && (best == nil || table.Seats < best.Seats) {
+			best = &tables[i]
+		}
+		if table.Combinable {
+			zones[table.Zone] = append(zones[table.Zone], table)
+		}
+	}
+	if best != nil {
+		return []primitive.ObjectID{best.ID}, true
+	}
+
+	var combination []primitive.ObjectID
+	bestSeats := 0
+	for _, candidates := range zones {
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].Seats > candidates[j].Seats })
+
+		var ids []primitive.ObjectID
+		seats := 0
+		for _, table := range candidates {
+			ids = append(ids, table.ID)
+			seats += table.Seats
+			if seats >= partySize {
+				break
+			}
+		}
+		if seats < partySize {
+			continue
+		}
+		if combination == nil || len(ids) < len(combination) || (len(ids) == len(combination) && seats < bestSeats) {
+			combination = ids
+			bestSeats = seats
+		}
+	}
+
+	return combination, combination != nil
+}
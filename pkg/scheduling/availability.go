@@ -0,0 +1,82 @@
+package scheduling
+
+import (
+	"book-and-rate/pkg/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Slot is a bookable start time together with the tables that would be allocated
+type Slot struct {
+	Time     time.Time
+	TableIDs []primitive.ObjectID
+}
+
+// ParseDay resolves a "YYYY-MM-DD" date to midnight in the restaurant's timezone
+func ParseDay(hours models.OpeningHours, date string) (time.Time, error) {
+	loc, err := location(hours)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.ParseInLocation(dateLayout, date, loc)
+}
+
+// AvailableSlots lists the slots of the day containing day at which the party can be seated.
+// Bookings are expected to hold their tables, slots before now are skipped.
+func AvailableSlots(hours models.OpeningHours, tables []models.Table, bookings []models.Booking, day time.Time, partySize int, now time.Time) ([]Slot, error) {
+	slots, err := Slots(hours, day)
+	if err != nil {
+		return nil, err
+	}
+
+	var available []Slot
+	for _, slot := range slots {
+		if slot.Before(now) {
+			continue
+		}
+
+		end := slot.Add(models.DefaultBookingDuration)
+		tableIds, ok := AllocateTables(tables, BusyTables(Overlapping(bookings, slot, end)), partySize)
+		if ok {
+			available = append(available, Slot{Time: slot, TableIDs: tableIds})
+		}
+	}
+	return available, nil
+}
+
+// AvailableSlotsBetween lists the slots from start to end at which the party can be seated, from every day the window
+// touches, including the day before for its periods running past midnight
+func AvailableSlotsBetween(hours models.OpeningHours, tables []models.Table, bookings []models.Booking, start, end time.Time, partySize int, now time.Time) ([]Slot, error) {
+	loc, err := location(hours)
+	if err != nil {
+		return nil, err
+	}
+	first := start.In(loc).AddDate(0, 0, -1)
+	first = time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc)
+
+	var available []Slot
+	for day := first; !day.After(end); day = day.AddDate(0, 0, 1) {
+		slots, err := AvailableSlots(hours, tables, bookings, day, partySize, now)
+		if err != nil {
+			return nil, err
+		}
+		for _, slot := range slots {
+			if !slot.Time.Before(start) && !slot.Time.After(end) {
+				available = append(available, slot)
+			}
+		}
+	}
+	return available, nil
+}
+
+// Overlapping keeps the bookings that share part of the [start, end) window
+func Overlapping(bookings []models.Booking, start, end time.Time) []models.Booking {
+	var overlapping []models.Booking
+	for _, booking := range bookings {
+		if booking.Date.Before(end) && booking.End().After(start) {
+			overlapping = append(overlapping, booking)
+		}
+	}
+	return overlapping
+}
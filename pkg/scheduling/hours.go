@@ -0,0 +1,215 @@
+package scheduling
+
+import (
+	"book-and-rate/pkg/models"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+var (
+	ErrClosed          = errors.New("restaurant is closed at the requested time")
+	ErrOffSlot         = errors.New("requested time is not on a booking slot")
+	ErrPastLastSeating = errors.New("requested time is after the last seating")
+)
+
+// Period is a service period resolved to concrete times on a given day
+type Period struct {
+	Open        time.Time
+	Close       time.Time
+	LastSeating time.Time
+}
+
+// ValidateHours checks that every period and exception of the schedule is well formed
+func ValidateHours(hours models.OpeningHours) error {
+	if _, err := location(hours); err != nil {
+		return err
+	}
+	if hours.SlotInterval < 0 {
+		return errors.New("slot interval must be positive")
+	}
+
+	for _, day := range hours.Weekly {
+		if day.Weekday < time.Sunday || day.Weekday > time.Saturday {
+			return fmt.Errorf("invalid weekday %d", day.Weekday)
+		}
+		if err := validatePeriods(day.Periods, slotInterval(hours)); err != nil {
+			return fmt.Errorf("%s: %w", day.Weekday, err)
+		}
+	}
+
+	for _, exception := range hours.Exceptions {
+		if _, err := time.Parse(dateLayout, exception.Date); err != nil {
+			return fmt.Errorf("invalid exception date %q", exception.Date)
+		}
+		if err := validatePeriods(exception.Periods, slotInterval(hours)); err != nil {
+			return fmt.Errorf("%s: %w", exception.Date, err)
+		}
+	}
+
+	return nil
+}
+
+func validatePeriods(periods []models.ServicePeriod, interval int) error {
+	day := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for _, servicePeriod := range periods {
+		if _, err := resolvePeriod(servicePeriod, day, interval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PeriodsOn returns the service periods of the day containing t, in the restaurant's timezone.
+// Exception dates replace the weekly schedule for that day. A period closing past midnight belongs to the day it opens on.
+func PeriodsOn(hours models.OpeningHours, t time.Time) ([]Period, error) {
+	loc, err := location(hours)
+	if err != nil {
+		return nil, err
+	}
+	t = t.In(loc)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+
+	servicePeriods, found := exceptionPeriods(hours, day)
+	if !found {
+		for _, weekly := range hours.Weekly {
+			if weekly.Weekday == day.Weekday() {
+				servicePeriods = append(servicePeriods, weekly.Periods...)
+			}
+		}
+	}
+
+	periods := make([]Period, 0, len(servicePeriods))
+	for _, servicePeriod := range servicePeriods {
+		period, err := resolvePeriod(servicePeriod, day, slotInterval(hours))
+		if err != nil {
+			return nil, err
+		}
+		periods = append(periods, period)
+	}
+	return periods, nil
+}
+
+// Slots lists the bookable start times of the day containing t
+func Slots(hours models.OpeningHours, t time.Time) ([]time.Time, error) {
+	periods, err := PeriodsOn(hours, t)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(slotInterval(hours)) * time.Minute
+	var slots []time.Time
+	for _, period := range periods {
+		for slot := period.Open; !slot.After(period.LastSeating); slot = slot.Add(interval) {
+			slots = append(slots, slot)
+		}
+	}
+	return slots, nil
+}
+
+// CheckBookable reports why a booking cannot start at t, or nil when it can.
+// Only the start is checked: the last seating is how late a booking may begin, and like any meal it may run past closing.
+func CheckBookable(hours models.OpeningHours, t time.Time) error {
+	// The periods of the day before may still be running past midnight
+	periods, err := PeriodsOn(hours, t.AddDate(0, 0, -1))
+	if err != nil {
+		return err
+	}
+	today, err := PeriodsOn(hours, t)
+	if err != nil {
+		return err
+	}
+	periods = append(periods, today...)
+
+	interval := time.Duration(slotInterval(hours)) * time.Minute
+	for _, period := range periods {
+		if t.Before(period.Open) || !t.Before(period.Close) {
+			continue
+		}
+		if t.After(period.LastSeating) {
+			return ErrPastLastSeating
+		}
+		if t.Sub(period.Open)%interval != 0 {
+			return ErrOffSlot
+		}
+		return nil
+	}
+	return ErrClosed
+}
+
+func exceptionPeriods(hours models.OpeningHours, day time.Time) ([]models.ServicePeriod, bool) {
+	date := day.Format(dateLayout)
+	for _, exception := range hours.Exceptions {
+		if exception.Date != date {
+			continue
+		}
+		if exception.Closed {
+			return nil, true
+		}
+		return exception.Periods, true
+	}
+	return nil, false
+}
+
+// resolvePeriod places a service period on day; the last seating defaults to one slot before closing.
+// A period closing at or before the time it opens runs past midnight, so 18:00-02:00 closes on the next day.
+func resolvePeriod(servicePeriod models.ServicePeriod, day time.Time, interval int) (Period, error) {
+	open, err := clockTime(day, servicePeriod.Open)
+	if err != nil {
+		return Period{}, err
+	}
+	closing, err := clockTime(day, servicePeriod.Close)
+	if err != nil {
+		return Period{}, err
+	}
+	if closing.Equal(open) {
+		return Period{}, fmt.Errorf("period %s-%s closes when it opens", servicePeriod.Open, servicePeriod.Close)
+	}
+	if closing.Before(open) {
+		closing, _ = clockTime(day.AddDate(0, 0, 1), servicePeriod.Close)
+	}
+
+	lastSeating := closing.Add(-time.Duration(interval) * time.Minute)
+	if servicePeriod.LastSeating != "" {
+		lastSeating, err = clockTime(day, servicePeriod.LastSeating)
+		if err != nil {
+			return Period{}, err
+		}
+		if lastSeating.Before(open) {
+			lastSeating, _ = clockTime(day.AddDate(0, 0, 1), servicePeriod.LastSeating)
+		}
+	}
+	if lastSeating.Before(open) || !lastSeating.Before(closing) {
+		return Period{}, fmt.Errorf("last seating %s is outside period %s-%s", servicePeriod.LastSeating, servicePeriod.Open, servicePeriod.Close)
+	}
+
+	return Period{Open: open, Close: closing, LastSeating: lastSeating}, nil
+}
+
+func clockTime(day time.Time, clock string) (time.Time, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), parsed.Hour(), parsed.Minute(), 0, 0, day.Location()), nil
+}
+
+func slotInterval(hours models.OpeningHours) int {
+	if hours.SlotInterval <= 0 {
+		return models.DefaultSlotInterval
+	}
+	return hours.SlotInterval
+}
+
+func location(hours models.OpeningHours) (*time.Location, error) {
+	if hours.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(hours.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q", hours.Timezone)
+	}
+	return loc, nil
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"book-and-rate/pkg/dto"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/repository"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// unreachableRestaurants fails every lookup the way the Mongo store does when the database is down
+type unreachableRestaurants struct {
+	repository.RestaurantRepository
+}
+
+func (unreachableRestaurants) FindByID(ctx context.Context, id primitive.ObjectID) (models.Restaurant, error) {
+	return models.Restaurant{}, fmt.Errorf("%w: server selection error: context deadline exceeded, current topology: { Type: Unknown }", repository.ErrUnavailable)
+}
+
+// errorResponse sends the request and decodes the error envelope, failing unless the status matches
+func errorResponse(t *testing.T, handler http.Handler, req *http.Request, status int) (dto.ErrorResponse, *httptest.ResponseRecorder) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != status {
+		t.Fatalf("%s %s: expected status %d, got %d: %s", req.Method, req.URL.Path, status, rec.Code, rec.Body.String())
+	}
+	if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("%s %s: expected a JSON error, got %q", req.Method, req.URL.Path, contentType)
+	}
+	var body dto.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s %s: decoding %q: %v", req.Method, req.URL.Path, rec.Body.String(), err)
+	}
+	if body.RequestID == "" || body.RequestID != rec.Header().Get("X-Request-ID") {
+		t.Errorf("%s %s: request ID %q does not match the header %q", req.Method, req.URL.Path, body.RequestID, rec.Header().Get("X-Request-ID"))
+	}
+	return body, rec
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	handler := New(testConfig(), repository.NewMemoryStore(), log.New(io.Discard, "", 0)).Handler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		code   string
+	}{
+		{"unknown restaurant", "GET", "/restaurants/" + primitive.NewObjectID().Hex() + "/hours", http.StatusNotFound, "not_found"},
+		{"unknown endpoint", "GET", "/nowhere", http.StatusNotFound, "not_found"},
+		{"unsupported method", "DELETE", "/healthz", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"missing token", "GET", "/restaurants", http.StatusUnauthorized, "unauthorized"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := errorResponse(t, handler, httptest.NewRequest(tt.method, tt.path, nil), tt.status)
+			if body.Code != tt.code || body.Message == "" {
+				t.Errorf("unexpected error %+v", body)
+			}
+		})
+	}
+
+	body, _ := errorResponse(t, handler, httptest.NewRequest("GET", "/restaurants/nope/hours", nil), http.StatusBadRequest)
+	if body.Code != "validation_failed" || len(body.Fields) != 1 || body.Fields[0].Field != "id" {
+		t.Errorf("expected the ID to be reported as the invalid field, got %+v", body)
+	}
+
+	body, _ = errorResponse(t, handler, httptest.NewRequest("POST", "/users", strings.NewReader("{not json")), http.StatusBadRequest)
+	if body.Code != "bad_request" || strings.Contains(body.Message, "invalid character") {
+		t.Errorf("expected a plain malformed body error, got %+v", body)
+	}
+
+	signUp := `{"firstName": "Ada", "phoneNumber": "0612345678", "password": "s3cret-password"}`
+	body, _ = errorResponse(t, handler, httptest.NewRequest("POST", "/users", strings.NewReader(signUp)), http.StatusBadRequest)
+	if body.Code != "validation_failed" || len(body.Fields) != 1 || body.Fields[0].Field != "phoneNumber" {
+		t.Errorf("expected the phone number to be reported as the invalid field, got %+v", body)
+	}
+}
+
+func TestErrorRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	handler := New(testConfig(), repository.NewMemoryStore(), log.New(&logs, "", 0)).Handler()
+
+	req := httptest.NewRequest("GET", "/nowhere", nil)
+	req.Header.Set("X-Request-ID", "client-trace-42")
+	body, _ := errorResponse(t, handler, req, http.StatusNotFound)
+	if body.RequestID != "client-trace-42" {
+		t.Errorf("expected the client's request ID to be kept, got %q", body.RequestID)
+	}
+	if !strings.Contains(logs.String(), "client-trace-42") {
+		t.Errorf("expected the request ID in the logs, got %q", logs.String())
+	}
+
+	req = httptest.NewRequest("GET", "/nowhere", nil)
+	req.Header.Set("X-Request-ID", "not\nsafe to log")
+	body, _ = errorResponse(t, handler, req, http.StatusNotFound)
+	if body.RequestID == "" || strings.Contains(body.RequestID, "safe") {
+		t.Errorf("expected a malformed request ID to be replaced, got %q", body.RequestID)
+	}
+}
+
+func TestErrorUnavailableStorage(t *testing.T) {
+	store := repository.NewMemoryStore()
+	store.Restaurants = unreachableRestaurants{store.Restaurants}
+	handler := New(testConfig(), store, log.New(io.Discard, "", 0)).Handler()
+
+	// An outage is not a missing restaurant, and its details stay in the logs
+	body, _ := errorResponse(t, handler, httptest.NewRequest("GET", "/restaurants/"+primitive.NewObjectID().Hex()+"/hours", nil), http.StatusServiceUnavailable)
+	if body.Code != "service_unavailable" {
+		t.Errorf("unexpected error %+v", body)
+	}
+	for _, internal := range []string{"repository", "server selection", "topology"} {
+		if strings.Contains(body.Message, internal) {
+			t.Errorf("the error exposes internal details: %q", body.Message)
+		}
+	}
+}
+
+func TestErrorValidation(t *testing.T) {
+	cfg := testConfig()
+	cfg.Server.MaxBodyBytes = 256
+	handler := New(cfg, repository.NewMemoryStore(), log.New(io.Discard, "", 0)).Handler()
+
+	// Every invalid field is reported at once
+	body, _ := errorResponse(t, handler, httptest.NewRequest("POST", "/users", strings.NewReader(`{"firstName": " ", "password": "short"}`)), http.StatusBadRequest)
+	var fields []string
+	for _, field := range body.Fields {
+		fields = append(fields, field.Field)
+	}
+	if body.Code != "validation_failed" || strings.Join(fields, ",") != "firstName,phoneNumber,password" {
+		t.Errorf("expected the name, phone number and password to be reported, got %+v", body)
+	}
+
+	body, _ = errorResponse(t, handler, httptest.NewRequest("POST", "/users", strings.NewReader(`{"firstName": "Ada", "admin": true}`)), http.StatusBadRequest)
+	if len(body.Fields) != 1 || body.Fields[0].Field != "admin" {
+		t.Errorf("expected the unknown field to be reported, got %+v", body)
+	}
+
+	body, _ = errorResponse(t, handler, httptest.NewRequest("POST", "/users/login", strings.NewReader(`{"phone": "+31612345678"} {"phone": "+31612345679"}`)), http.StatusBadRequest)
+	if body.Code != "bad_request" {
+		t.Errorf("expected a second JSON value to be refused, got %+v", body)
+	}
+
+	huge := `{"firstName": "` + strings.Repeat("a", 512) + `"}`
+	body, _ = errorResponse(t, handler, httptest.NewRequest("POST", "/users", strings.NewReader(huge)), http.StatusRequestEntityTooLarge)
+	if body.Code != "payload_too_large" {
+		t.Errorf("unexpected error %+v", body)
+	}
+}
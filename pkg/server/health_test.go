@@ -0,0 +1,116 @@
+package server
+
+import (
+	"book-and-rate/pkg/repository"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, handler http.Handler, path string, status int, v interface{}) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	if rec.Code != status {
+		t.Fatalf("GET %s: expected status %d, got %d: %s", path, status, rec.Code, rec.Body.String())
+	}
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("GET %s: decoding %q: %v", path, rec.Body.String(), err)
+		}
+	}
+}
+
+// pendingMigrations is a database that answers but has not been migrated
+type pendingMigrations struct{}
+
+func (pendingMigrations) Ping(ctx context.Context) error { return nil }
+func (pendingMigrations) CheckMigrations(ctx context.Context) error {
+	return errors.New("1 migrations are pending")
+}
+
+type healthStatus struct {
+	Status string
+	Checks map[string]string
+}
+
+func TestProbes(t *testing.T) {
+	var logs bytes.Buffer
+	srv := New(testConfig(), repository.NewMemoryStore(), log.New(&logs, "", 0))
+	handler := srv.Handler()
+
+	var status healthStatus
+	get(t, handler, "/healthz", http.StatusOK, &status)
+	if status.Status != "ok" {
+		t.Errorf("expected liveness ok, got %+v", status)
+	}
+
+	get(t, handler, "/readyz", http.StatusOK, &status)
+	if status.Status != "ready" || status.Checks["database"] != "ok" || status.Checks["migrations"] != "ok" || len(status.Checks) != 2 {
+		t.Errorf("expected ready, got %+v", status)
+	}
+
+	store := repository.NewMemoryStore()
+	store.Database = pendingMigrations{}
+	var failures bytes.Buffer
+	get(t, New(testConfig(), store, log.New(&failures, "", 0)).Handler(), "/readyz", http.StatusServiceUnavailable, &status)
+	if status.Status != "unavailable" || status.Checks["migrations"] != "unavailable" || status.Checks["database"] != "ok" {
+		t.Errorf("expected the pending migrations to fail readiness, got %+v", status)
+	}
+	// The probe is public, the reason a check failed only goes to the log
+	if !strings.Contains(failures.String(), "1 migrations are pending") {
+		t.Errorf("expected the failed check to be logged, got %q", failures.String())
+	}
+
+	var info struct {
+		GoVersion string `json:"goVersion"`
+	}
+	get(t, handler, "/version", http.StatusOK, &info)
+	if info.GoVersion == "" {
+		t.Error("version is missing the Go version")
+	}
+
+	logs.Reset()
+	get(t, handler, "/healthz", http.StatusOK, nil)
+	get(t, handler, "/readyz", http.StatusOK, nil)
+	get(t, handler, "/version", http.StatusOK, nil)
+	if logs.Len() != 0 {
+		t.Errorf("probes should not be logged, got %q", logs.String())
+	}
+
+	get(t, handler, "/restaurants/nope/hours", http.StatusBadRequest, nil)
+	if !strings.Contains(logs.String(), "GET /restaurants/nope/hours 400") {
+		t.Errorf("expected the request to be logged, got %q", logs.String())
+	}
+}
+
+func TestCORS(t *testing.T) {
+	cfg := testConfig()
+	cfg.CORSOrigins = []string{"https://app.example"}
+	handler := New(cfg, repository.NewMemoryStore(), log.New(io.Discard, "", 0)).Handler()
+
+	preflight := httptest.NewRequest("OPTIONS", "/bookings", nil)
+	preflight.Header.Set("Origin", "https://app.example")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, preflight)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example" {
+		t.Errorf("expected the preflight to be allowed, got %d %v", rec.Code, rec.Header())
+	}
+
+	other := httptest.NewRequest("GET", "/healthz", nil)
+	other.Header.Set("Origin", "https://evil.example")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, other)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected no CORS headers for another origin, got %v", rec.Header())
+	}
+}
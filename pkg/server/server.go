@@ -0,0 +1,168 @@
+package server
+
+import (
+	"book-and-rate/pkg/apierror"
+	"book-and-rate/pkg/auth"
+	"book-and-rate/pkg/config"
+	"book-and-rate/pkg/db"
+	"book-and-rate/pkg/handlers"
+	middleware "book-and-rate/pkg/middlewares"
+	"book-and-rate/pkg/notify"
+	"book-and-rate/pkg/repository"
+	"book-and-rate/pkg/routes"
+	"book-and-rate/pkg/sms"
+	"book-and-rate/pkg/utils"
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	httpSwagger "github.com/swaggo/http-swagger"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Server owns everything the service needs to run, it is built once at startup and hands its dependencies to the handlers
+type Server struct {
+	config   config.Config
+	client   *mongo.Client
+	store    *repository.Store
+	logger   *log.Logger
+	signer   *auth.TokenService
+	hasher   *utils.PasswordHasher
+	notifier notify.Notifier
+	router   *mux.Router
+}
+
+// New builds the server over a store, the server does not own a database connection
+func New(cfg config.Config, store *repository.Store, logger *log.Logger) *Server {
+	s := &Server{
+		config:   cfg,
+		store:    store,
+		logger:   logger,
+		signer:   auth.NewTokenService(cfg),
+		hasher:   utils.NewPasswordHasher(cfg.BcryptCost),
+		notifier: notify.NewSMSNotifier(sms.New(cfg.SMS, logger)),
+		router:   mux.NewRouter(),
+	}
+	s.routes()
+	return s
+}
+
+// Connect opens the MongoDB connection of the configuration and builds the server over it
+func Connect(ctx context.Context, cfg config.Config, logger *log.Logger) (*Server, error) {
+	client, err := db.Connect(ctx, cfg.MongoDbUrl)
+	if err != nil {
+		return nil, err
+	}
+	logger.Println("Connected to MongoDB!")
+
+	s := New(cfg, repository.NewMongoStore(client.Database(cfg.DatabaseName)), logger)
+	s.client = client
+	return s, nil
+}
+
+func (s *Server) routes() {
+	authenticate := middleware.AuthenticationMiddleware(s.signer)
+
+	s.router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apierror.NotFound(w, r, "No such endpoint")
+	})
+	s.router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "The endpoint does not support "+r.Method)
+	})
+
+	s.router.PathPrefix("/swagger").Handler(httpSwagger.WrapHandler)
+
+	routes.HealthRoutes(s.router, handlers.NewHealthHandler(s.readinessChecks(), s.logger))
+	routes.UserRoutes(s.router, handlers.NewUserHandler(s.store.Users, s.store.Tokens, s.signer, s.hasher, s.logger), authenticate)
+	routes.VerificationRoutes(s.router, handlers.NewVerificationHandler(s.store.Users, s.store.Verifications, s.notifier, s.config.Verification, s.logger), authenticate)
+	routes.PasswordResetRoutes(s.router, handlers.NewPasswordResetHandler(s.store.Resets, s.store.Users, s.store.Restaurants, s.store.Tokens, s.hasher, s.notifier, s.config.PasswordReset, s.logger))
+	routes.RestaurantRoutes(s.router, handlers.NewRestaurantHandler(s.store.Restaurants, s.store.Tokens, s.signer, s.hasher, s.logger), authenticate)
+	routes.BookingRoutes(s.router, handlers.NewBookingHandler(s.store.Bookings, s.store.Restaurants, s.store.Users, s.logger), authenticate)
+	routes.RateRoutes(s.router, handlers.NewRateHandler(s.store.Rates, s.store.Bookings, s.store.Restaurants, s.logger), authenticate)
+	routes.AvailabilityRoutes(s.router, handlers.NewAvailabilityHandler(s.store.Restaurants, s.store.Bookings, s.logger))
+	routes.RefreshTokenRoutes(s.router, handlers.NewTokenHandler(s.store.Tokens, s.store.Users, s.store.Restaurants, s.signer, s.logger), authenticate)
+}
+
+// Handler returns the router serving the API, with every request tagged with an ID, all but the probes logged
+// and request bodies limited to the configured size
+func (s *Server) Handler() http.Handler {
+	handler := middleware.BodyLimitMiddleware(s.config.Server.MaxBodyBytes)(s.router)
+	handler = middleware.CORSMiddleware(s.config.CORSOrigins)(handler)
+	handler = middleware.LoggingMiddleware(s.logger, routes.HealthPaths...)(handler)
+	return middleware.RequestIDMiddleware(handler)
+}
+
+func (s *Server) readinessChecks() []handlers.ReadinessCheck {
+	return []handlers.ReadinessCheck{
+		{Name: "database", Check: s.store.Database.Ping},
+		{Name: "migrations", Check: s.store.Database.CheckMigrations},
+	}
+}
+
+// Run serves the API on the configured address, over TLS when a certificate is configured, along with the background workers.
+// Once ctx is cancelled the server stops accepting connections, lets in-flight requests finish within the shutdown timeout,
+// then stops the workers and finally closes the database connection.
+func (s *Server) Run(ctx context.Context) error {
+	cfg := s.config.Server
+	httpServer := &http.Server{
+		Addr:         cfg.Address,
+		Handler:      s.Handler(),
+		ReadTimeout:  time.Duration(cfg.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.IdleTimeout),
+		ErrorLog:     s.logger,
+	}
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	workers := s.startWorkers(workersCtx)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if cfg.TLS() {
+			s.logger.Printf("Server is running on %s with TLS", cfg.Address)
+			serveErr <- httpServer.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			s.logger.Printf("Server is running on %s", cfg.Address)
+			serveErr <- httpServer.ListenAndServe()
+		}
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+		s.logger.Printf("Server failed: %v", err)
+	case <-ctx.Done():
+		s.logger.Printf("Shutting down, draining requests for up to %v", time.Duration(cfg.ShutdownTimeout))
+		drainCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+		err = httpServer.Shutdown(drainCtx)
+		cancel()
+		if err != nil {
+			s.logger.Printf("Error draining requests: %v", err)
+		}
+	}
+
+	stopWorkers()
+	workers.Wait()
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if closeErr := s.close(closeCtx); closeErr != nil {
+		s.logger.Printf("Error disconnecting from MongoDB: %v", closeErr)
+		if err == nil {
+			err = closeErr
+		}
+	}
+
+	s.logger.Println("Server stopped")
+	return err
+}
+
+// close releases the database connection, if the server owns one
+func (s *Server) close(ctx context.Context) error {
+	if s.client == nil {
+		return nil
+	}
+	return s.client.Disconnect(ctx)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"book-and-rate/pkg/config"
+	"book-and-rate/pkg/models"
+	"book-and-rate/pkg/repository"
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func testConfig() config.Config {
+	cfg := config.Default()
+	cfg.JwtSecret = "test-jwt-secret-0123456789abcdefgh"
+	cfg.Server.Address = "127.0.0.1:0"
+	return cfg
+}
+
+func TestRunStopsWhenCancelled(t *testing.T) {
+	srv := New(testConfig(), repository.NewMemoryStore(), log.New(io.Discard, "", 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(ctx) }()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after its context was cancelled")
+	}
+}
+
+func TestRunReportsListenErrors(t *testing.T) {
+	cfg := testConfig()
+	cfg.Server.Address = "not-an-address"
+	srv := New(cfg, repository.NewMemoryStore(), log.New(io.Discard, "", 0))
+
+	if err := srv.Run(context.Background()); err == nil {
+		t.Fatal("expected an error for an invalid listen address")
+	}
+}
+
+func TestPurgeExpiredTokens(t *testing.T) {
+	store := repository.NewMemoryStore()
+	srv := New(testConfig(), store, log.New(io.Discard, "", 0))
+	ctx := context.Background()
+
+	now := time.Now()
+	for jti, expiresAt := range map[string]time.Time{"expired": now.Add(-time.Minute), "live": now.Add(time.Hour)} {
+		if err := store.Tokens.Create(ctx, models.RefreshToken{TokenID: jti, ExpiresAt: expiresAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := srv.purgeExpiredTokens(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Tokens.FindByTokenID(ctx, "expired"); err != repository.ErrNotFound {
+		t.Errorf("expected the expired token to be deleted, got %v", err)
+	}
+	if _, err := store.Tokens.FindByTokenID(ctx, "live"); err != nil {
+		t.Errorf("expected the live token to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// worker is a background job the server runs every interval until it stops
+type worker struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+func (s *Server) workers() []worker {
+	return []worker{
+		{name: "purge expired refresh tokens", interval: time.Duration(s.config.TokenPurgeInterval), run: s.purgeExpiredTokens},
+	}
+}
+
+// startWorkers runs every worker in its own goroutine, cancelling ctx stops them and the returned WaitGroup tells when they are done.
+// A run in progress is allowed to finish, so a worker never stops halfway through a job.
+func (s *Server) startWorkers(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+
+	for _, w := range s.workers() {
+		wg.Add(1)
+		go func(w worker) {
+			defer wg.Done()
+
+			ticker := time.NewTicker(w.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := w.run(context.WithoutCancel(ctx)); err != nil {
+						s.logger.Printf("Worker %q failed: %v", w.name, err)
+					}
+				}
+			}
+		}(w)
+	}
+	return &wg
+}
+
+func (s *Server) purgeExpiredTokens(ctx context.Context) error {
+	deleted, err := s.store.Tokens.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		s.logger.Printf("Purged %d expired refresh tokens", deleted)
+	}
+	return nil
+}
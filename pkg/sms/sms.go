@@ -0,0 +1,99 @@
+// Package sms delivers text messages. Production deployments plug a provider in behind Sender;
+// the senders here only record messages, for development and tests.
+package sms
+
+import (
+	"book-and-rate/pkg/config"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sender delivers a text message to a phone number in E.164 format
+type Sender interface {
+	Send(ctx context.Context, to, body string) error
+}
+
+// New returns the sender selected by the configuration
+func New(cfg config.SMSConfig, logger *log.Logger) Sender {
+	if cfg.Sender == config.SMSSenderFile {
+		return NewFileSender(cfg.File)
+	}
+	return NewLogSender(logger)
+}
+
+// LogSender writes messages to the log, anyone reading the log can read the codes sent
+type LogSender struct {
+	logger *log.Logger
+}
+
+func NewLogSender(logger *log.Logger) *LogSender {
+	return &LogSender{logger: logger}
+}
+
+func (s *LogSender) Send(ctx context.Context, to, body string) error {
+	s.logger.Printf("SMS to %s: %s", to, body)
+	return nil
+}
+
+// Message is a text message as written by FileSender
+type Message struct {
+	To     string    `json:"to"`
+	Body   string    `json:"body"`
+	SentAt time.Time `json:"sentAt"`
+}
+
+// FileSender appends every message to a file as a line of JSON
+type FileSender struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileSender(path string) *FileSender {
+	return &FileSender{path: path}
+}
+
+func (s *FileSender) Send(ctx context.Context, to, body string) error {
+	line, err := json.Marshal(Message{To: to, Body: body, SentAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening SMS file: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("writing SMS file: %w", err)
+	}
+	return file.Close()
+}
+
+// ReadFile returns the messages a FileSender wrote to path, oldest first
+func ReadFile(path string) ([]Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var messages []Message
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var message Message
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			return nil, fmt.Errorf("reading SMS file %s: %w", path, err)
+		}
+		messages = append(messages, message)
+	}
+	return messages, scanner.Err()
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// GenerateCode returns a random numeric one-time code of the given number of digits
+func GenerateCode(digits int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
+
+// HashCode hashes a one-time code, salted so equal codes sent to different accounts hash differently.
+// Codes are short lived and attempts are limited, so a fast hash is enough where passwords need bcrypt.
+func HashCode(code, salt string) string {
+	sum := sha256.Sum256([]byte(salt + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// CompareCode reports whether code hashes to hash, in constant time
+func CompareCode(hash, code, salt string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashCode(code, salt))) == 1
+}
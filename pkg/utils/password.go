@@ -2,12 +2,22 @@ package utils
 
 import "golang.org/x/crypto/bcrypt"
 
-// HashPassword hashes the given password using bcrypt
-func HashPassword(password string) (string, error) {
-    bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+// PasswordHasher hashes new passwords with bcrypt at the work factor it was built with
+type PasswordHasher struct {
+    cost int
+}
+
+func NewPasswordHasher(cost int) *PasswordHasher {
+    return &PasswordHasher{cost: cost}
+}
+
+// Hash hashes the given password using bcrypt
+func (h *PasswordHasher) Hash(password string) (string, error) {
+    bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
     return string(bytes), err
 }
 
+// ComparePasswords checks a password against a hash, the hash records the work factor it was made with
 func ComparePasswords(hashedPwd string, plainPwd string) error {
     return bcrypt.CompareHashAndPassword([]byte(hashedPwd), []byte(plainPwd))
-}
\ No newline at end of file
+}
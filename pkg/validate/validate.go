@@ -0,0 +1,192 @@
+// Package validate checks decoded request bodies against the rules declared in the `validate` tags of their fields,
+// so every request type states its own constraints next to its fields.
+//
+// Rules are separated by commas:
+//
+//	required   the field must be set, a string must not be blank
+//	min=N      a string must have at least N characters, a slice N items, a number must be at least N
+//	max=N      the same as min, as an upper bound
+//	oneof=a b  a string must be one of the space separated values
+//	future     a time must be later than now
+//
+// Rules other than required are not checked on a field that was left out, meaning a nil pointer or a zero value,
+// so optional fields only need their bounds.
+package validate
+
+import (
+	"book-and-rate/pkg/models"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Struct checks v, a struct or a pointer to one, and returns models.FieldErrors naming every field that breaks a rule,
+// or nil when all of them pass. Fields are named by their JSON names and the fields of nested structs and slices of
+// structs are checked too, named like weekly[0].periods[1].open.
+func Struct(v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs models.FieldErrors
+	checkStruct(value, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func checkStruct(value reflect.Value, prefix string, errs *models.FieldErrors) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			checkStruct(value.Field(i), prefix, errs)
+			continue
+		}
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+
+		path := prefix + name
+		if tag := field.Tag.Get("validate"); tag != "" {
+			if message := check(value.Field(i), tag); message != "" {
+				*errs = append(*errs, models.FieldError{Field: path, Message: message})
+				continue
+			}
+		}
+		checkNested(value.Field(i), path, errs)
+	}
+}
+
+// checkNested descends into struct fields and slices of structs
+func checkNested(value reflect.Value, path string, errs *models.FieldErrors) {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	switch {
+	case value.Kind() == reflect.Struct && value.Type() != timeType:
+		checkStruct(value, path+".", errs)
+	case value.Kind() == reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			checkNested(value.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+// check applies the rules of tag to the field and returns the message of the first one it breaks
+func check(value reflect.Value, tag string) string {
+	set := true
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			set = false
+		} else {
+			value = value.Elem()
+		}
+	} else {
+		set = !isEmpty(value)
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "required" {
+			if !set {
+				return "is required"
+			}
+			continue
+		}
+		if !set {
+			continue
+		}
+
+		var message string
+		switch name {
+		case "min":
+			message = checkBound(value, param, true)
+		case "max":
+			message = checkBound(value, param, false)
+		case "oneof":
+			message = checkOneOf(value, strings.Fields(param))
+		case "future":
+			if at, ok := value.Interface().(time.Time); ok && !at.After(time.Now()) {
+				message = "must be in the future"
+			}
+		default:
+			panic("validate: unknown rule " + strconv.Quote(rule))
+		}
+		if message != "" {
+			return message
+		}
+	}
+	return ""
+}
+
+func isEmpty(value reflect.Value) bool {
+	if value.Kind() == reflect.String {
+		return strings.TrimSpace(value.String()) == ""
+	}
+	return value.IsZero()
+}
+
+func checkBound(value reflect.Value, param string, lower bool) string {
+	bound, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		panic("validate: bound " + strconv.Quote(param) + " is not a number")
+	}
+
+	var n float64
+	verb, unit := "be", ""
+	switch value.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(value.String())), " characters"
+	case reflect.Slice, reflect.Map:
+		n, verb, unit = float64(value.Len()), "have", " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = value.Float()
+	default:
+		panic("validate: min and max do not apply to " + value.Type().String())
+	}
+
+	if lower && n < float64(bound) {
+		return fmt.Sprintf("must %s at least %d%s", verb, bound, unit)
+	}
+	if !lower && n > float64(bound) {
+		return fmt.Sprintf("must %s at most %d%s", verb, bound, unit)
+	}
+	return ""
+}
+
+func checkOneOf(value reflect.Value, options []string) string {
+	for _, option := range options {
+		if value.String() == option {
+			return ""
+		}
+	}
+	return "must be one of " + strings.Join(options, ", ")
+}
+
+// jsonName is the name of the field in the JSON body
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
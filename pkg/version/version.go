@@ -0,0 +1,40 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Commit and BuildTime are set at link time:
+//
+//	go build -ldflags "-X book-and-rate/pkg/version.Commit=$(git rev-parse HEAD) -X book-and-rate/pkg/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+//
+// When they are not, Get falls back to the VCS stamp the Go toolchain embeds in the binary.
+var (
+	Commit    string
+	BuildTime string
+)
+
+// Info describes the running build
+type Info struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the build information of the running binary, unknown fields are left empty
+func Get() Info {
+	info := Info{Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+	return info
+}